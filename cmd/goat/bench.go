@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/monshunter/goat/pkg/log"
+
+	"github.com/monshunter/goat/pkg/config"
+	"github.com/monshunter/goat/pkg/goat"
+	"github.com/spf13/cobra"
+)
+
+func benchCmd() *cobra.Command {
+	opts := goat.BenchOptions{}
+	cmd := &cobra.Command{
+		Use:   "bench [flags]",
+		Short: "Measure the runtime overhead of the instrumentation",
+		Long: `The bench command is used to measure the runtime overhead of the instrumentation on the benchmarks of a package.
+
+The project is copied to a temporary directory, the benchmarks of the package are run once without instrumentation,
+then once for each granularity and data type with every line of the package tracked. The source tree is not modified.
+The maxTrackPointsPerFunc and maxTrackPointsInLoops budget of goat.yaml is applied as "goat track" would.
+
+Options:
+  --package <dir>               Directory of the package with the benchmarks (default: ".")
+  --bench <regexp>              Benchmarks to run (default: ".")
+  --benchtime <t>               Run time of each benchmark, as go test -benchtime (default: go test default)
+  --count <n>                   Number of runs of each benchmark, averaged (default: 1)
+  --granularity <granularity>   Granularity to benchmark, can be repeated (default: line, patch, scope, func)
+  --data-type <dataType>        Data type to benchmark, can be repeated (default: bool, count, average)
+  --format <format>             Output format (text, json) (default: "text")
+
+Examples:
+  goat bench --package pkg/parser
+  goat bench --package pkg/parser --bench BenchmarkParse --count 5
+  goat bench --package pkg/parser --granularity func --data-type count --format json`,
+		Args: cobra.ExactArgs(0),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := os.Stat(config.ConfigYaml); os.IsNotExist(err) {
+				return fmt.Errorf("config file %s not found", config.ConfigYaml)
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.LoadConfig(config.ConfigYaml)
+			if err != nil {
+				log.Errorf("Failed to load config: %v", err)
+				return err
+			}
+			return goat.NewBenchExecutor(cfg, opts, os.Stdout).Run()
+		},
+	}
+	cmd.Flags().StringVar(&opts.Package, "package", ".", "directory of the package with the benchmarks")
+	cmd.Flags().StringVar(&opts.Bench, "bench", ".", "benchmarks to run")
+	cmd.Flags().StringVar(&opts.BenchTime, "benchtime", "", "run time of each benchmark, as go test -benchtime")
+	cmd.Flags().IntVar(&opts.Count, "count", 1, "number of runs of each benchmark, averaged")
+	cmd.Flags().StringArrayVar(&opts.Granularities, "granularity", nil, "granularity to benchmark, can be repeated (default: all)")
+	cmd.Flags().StringArrayVar(&opts.DataTypes, "data-type", nil, "data type to benchmark, can be repeated (default: all)")
+	cmd.Flags().StringVar(&opts.Format, "format", "text", "output format (text, json)")
+	return cmd
+}
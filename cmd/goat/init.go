@@ -23,19 +23,29 @@ Options:
   --new <newBranch>                     New branch for comparison target (default: "HEAD"), valid values: [commit hash, branch name, tag name, "", HEAD]
   --app-name <appName>                  Application name (default: current directory name)
   --app-version <appVersion>            Application version (default: current commit short hash)
-  --granularity <granularity>           Granularity (line, patch, scope, func) (default: "patch")
+  --granularity <granularity>           Granularity (line, patch, scope, func, condition) (default: "patch")
   --diff-precision <diffPrecision>      Diff precision (1~3) (default: 1)
   --threads <threads>                   Number of threads (default: 1)
   --race                                Enable race detection (default: false)
+  --build-tag <buildTag>                Build tag guarding the tracking runtime (default: "", always compiled in)
   --goat-package-name <packageName>     Goat package name (default: "goat")
   --goat-package-alias <packageAlias>   Goat package alias (default: "goat")
   --goat-package-path <packagePath>     Goat package path (default: "goat")
   --ignores <ignores>                   Comma-separated list of files/dirs to ignore
+  --includes <includes>                 Comma-separated list of glob patterns of files to track (default: all)
+  --excludes <excludes>                 Comma-separated list of glob patterns of files to skip, "!" prefix re-includes
   --main-entries <entries>              Comma-separated list of relative paths to main packages from project root (e.g., 'cmd/server,cmd/client' or '*' for all)
   --printer-config-mode <mode>          Printer config mode, list of (none, useSpaces, tabIndent, sourcePos, rawFormat) (default: "useSpaces,tabIndent")
   --printer-config-tabwidth <tabwidth>  Printer config tabwidth (default: 8)
   --printer-config-indent <indent>      Printer config indent (default: 0)
-  --data-type <dataType>                Data type (bool, count) (default: "bool")
+  --data-type <dataType>                Data type (bool, count, average) (default: "bool")
+  --timestamps                          Record the first-hit and last-hit times of each track point (default: false)
+  --counter-layout <layout>             Memory layout of the counters (packed, padded, sharded) (default: "packed")
+  --counter-bits <bits>                 Width of the counters (32, 64) (default: 32)
+  --max-track-points-per-func <n>       Maximum tracking points of a function before falling back to a coarser granularity (default: 0, unlimited)
+  --max-track-points-in-loops <n>       Maximum tracking points in the loop bodies of a function (default: 0, unlimited)
+  --loop-placement <placement>          Placement of the tracking points in loop bodies (hoist, body) (default: "hoist")
+  --implicit-branches                   Track the implicit branches of the changed if and switch statements (default: false)
   --skip-nested-modules                 Skip directories containing go.mod files (default: true)
   --force                               Force overwrite existing goat.yaml file
 
@@ -43,7 +53,10 @@ Examples:
   goat init --old master --new "release-1.32"
   goat init --app-name "my-app" --app-version "2.0.0" --granularity func
   goat init --threads 4 --race
+  goat init --build-tag goat
+  goat init --granularity line --max-track-points-per-func 20 --max-track-points-in-loops 2
   goat init --ignores ".git,.idea,node_modules"
+  goat init --includes "pkg/**,cmd/**" --excludes "**/*.pb.go,!pkg/api/keep.pb.go"
   goat init --main-entries "cmd/app,cmd/worker"
   goat init --printer-config-mode "useSpaces,tabIndent" --printer-config-tabwidth 4 --printer-config-indent 2
   goat init --force                     Force overwrite existing goat.yaml file`,
@@ -79,15 +92,25 @@ Examples:
 			diffPrecision, _ := cmd.Flags().GetInt("diff-precision")
 			threads, _ := cmd.Flags().GetInt("threads")
 			race, _ := cmd.Flags().GetBool("race")
+			buildTag, _ := cmd.Flags().GetString("build-tag")
 			goatPackageName, _ := cmd.Flags().GetString("goat-package-name")
 			goatPackageAlias, _ := cmd.Flags().GetString("goat-package-alias")
 			goatPackagePath, _ := cmd.Flags().GetString("goat-package-path")
 			ignoresStr, _ := cmd.Flags().GetString("ignores")
+			includesStr, _ := cmd.Flags().GetString("includes")
+			excludesStr, _ := cmd.Flags().GetString("excludes")
 			mainEntriesStr, _ := cmd.Flags().GetString("main-entries")
 			printerConfigModeStr, _ := cmd.Flags().GetString("printer-config-mode")
 			printerConfigTabwidth, _ := cmd.Flags().GetInt("printer-config-tabwidth")
 			printerConfigIndent, _ := cmd.Flags().GetInt("printer-config-indent")
 			dataTypeStr, _ := cmd.Flags().GetString("data-type")
+			timestamps, _ := cmd.Flags().GetBool("timestamps")
+			counterLayout, _ := cmd.Flags().GetString("counter-layout")
+			counterBits, _ := cmd.Flags().GetInt("counter-bits")
+			maxTrackPointsPerFunc, _ := cmd.Flags().GetInt("max-track-points-per-func")
+			maxTrackPointsInLoops, _ := cmd.Flags().GetInt("max-track-points-in-loops")
+			loopPlacement, _ := cmd.Flags().GetString("loop-placement")
+			implicitBranches, _ := cmd.Flags().GetBool("implicit-branches")
 			skipNestedModules, _ := cmd.Flags().GetBool("skip-nested-modules")
 
 			// process ignore file list
@@ -96,6 +119,17 @@ Examples:
 			if ignoresStr != "" {
 				ignores = strings.Split(ignoresStr, ",")
 			}
+			// process glob rules
+			var includes []string
+			includesStr = strings.TrimSpace(includesStr)
+			if includesStr != "" {
+				includes = strings.Split(includesStr, ",")
+			}
+			var excludes []string
+			excludesStr = strings.TrimSpace(excludesStr)
+			if excludesStr != "" {
+				excludes = strings.Split(excludesStr, ",")
+			}
 			// process main package list
 			var mainEntries []string
 			if mainEntriesStr != "" {
@@ -125,7 +159,10 @@ Examples:
 				GoatPackagePath:       goatPackagePath,
 				Threads:               threads,
 				Race:                  race,
+				BuildTag:              buildTag,
 				Ignores:               ignores,
+				Includes:              includes,
+				Excludes:              excludes,
 				MainEntries:           mainEntries,
 				Granularity:           granularity,
 				DiffPrecision:         diffPrecision,
@@ -133,6 +170,13 @@ Examples:
 				PrinterConfigTabwidth: printerConfigTabwidth,
 				PrinterConfigIndent:   printerConfigIndent,
 				DataType:              dataTypeStr,
+				Timestamps:            timestamps,
+				CounterLayout:         counterLayout,
+				CounterBits:           counterBits,
+				MaxTrackPointsPerFunc: maxTrackPointsPerFunc,
+				MaxTrackPointsInLoops: maxTrackPointsInLoops,
+				LoopPlacement:         loopPlacement,
+				ImplicitBranches:      implicitBranches,
 				SkipNestedModules:     skipNestedModules,
 			}
 
@@ -158,19 +202,29 @@ Examples:
 	cmd.Flags().String("new", "HEAD", "New branch for comparison target, valid values: [commit hash, branch name, tag name, '', HEAD], newBranch must be the same as the current HEAD")
 	cmd.Flags().String("app-name", "", "Application name")
 	cmd.Flags().String("app-version", "", "Application version")
-	cmd.Flags().String("granularity", "patch", "Granularity (line, patch, scope, func)")
+	cmd.Flags().String("granularity", "patch", "Granularity (line, patch, scope, func, condition)")
 	cmd.Flags().Int("diff-precision", 1, "Diff precision (1~3)")
 	cmd.Flags().Int("threads", 1, "Number of threads")
 	cmd.Flags().Bool("race", false, "Enable race detection")
+	cmd.Flags().String("build-tag", "", "Build tag guarding the tracking runtime, empty means always compiled in")
 	cmd.Flags().String("goat-package-name", "goat", "Goat package name")
 	cmd.Flags().String("goat-package-alias", "goat", "Goat package alias")
 	cmd.Flags().String("goat-package-path", "goat", "Goat package path")
 	cmd.Flags().String("ignores", "", "Comma-separated list of files/dirs to ignore")
+	cmd.Flags().String("includes", "", "Comma-separated list of glob patterns of files to track")
+	cmd.Flags().String("excludes", "", "Comma-separated list of glob patterns of files to skip, '!' prefix re-includes")
 	cmd.Flags().String("main-entries", "", "Comma-separated list of relative paths to main packages from project root (e.g., 'cmd/server,cmd/client' or '*' for all)")
 	cmd.Flags().String("printer-config-mode", "useSpaces,tabIndent", "Printer config mode, list of (none, useSpaces, tabIndent, sourcePos, rawFormat)")
 	cmd.Flags().Int("printer-config-tabwidth", 8, "Printer config tabwidth")
 	cmd.Flags().Int("printer-config-indent", 0, "Printer config indent")
-	cmd.Flags().String("data-type", "bool", "Data type (bool, count)")
+	cmd.Flags().String("data-type", "bool", "Data type (bool, count, average)")
+	cmd.Flags().Bool("timestamps", false, "Record the first-hit and last-hit times of each track point")
+	cmd.Flags().String("counter-layout", config.CounterLayoutPacked, "Memory layout of the counters (packed, padded, sharded)")
+	cmd.Flags().Int("counter-bits", 32, "Width of the counters (32, 64)")
+	cmd.Flags().Int("max-track-points-per-func", 0, "Maximum tracking points of a function before falling back to a coarser granularity, 0 means unlimited")
+	cmd.Flags().Int("max-track-points-in-loops", 0, "Maximum tracking points in the loop bodies of a function, 0 means unlimited")
+	cmd.Flags().String("loop-placement", config.LoopPlacementHoist, "Placement of the tracking points in loop bodies (hoist, body)")
+	cmd.Flags().Bool("implicit-branches", false, "Track the implicit branches of the changed if and switch statements")
 	cmd.Flags().Bool("skip-nested-modules", true, "Skip sub directories containing go.mod files")
 	cmd.Flags().Bool("force", false, "Force overwrite existing goat.yaml file")
 
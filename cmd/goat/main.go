@@ -28,8 +28,8 @@ func main() {
 			// Set the verbose mode for the log
 			log.SetVerbose(verbose)
 
-			// Skip project checks for help or version commands
-			if cmd.Name() == "help" || cmd.Name() == "version" {
+			// Skip project checks for help, version or report commands
+			if cmd.Name() == "help" || cmd.Name() == "version" || cmd.Name() == "report" {
 				return nil
 			}
 
@@ -63,6 +63,8 @@ func main() {
 	rootCmd.AddCommand(trackCmd())
 	rootCmd.AddCommand(patchCmd())
 	rootCmd.AddCommand(cleanCmd())
+	rootCmd.AddCommand(reportCmd())
+	rootCmd.AddCommand(benchCmd())
 	rootCmd.AddCommand(versionCmd())
 
 	if err := rootCmd.Execute(); err != nil {
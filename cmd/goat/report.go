@@ -0,0 +1,45 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/monshunter/goat/pkg/goat"
+	"github.com/spf13/cobra"
+)
+
+func reportCmd() *cobra.Command {
+	opts := goat.ReportOptions{}
+	cmd := &cobra.Command{
+		Use:   "report [flags]",
+		Short: "Report the coverage of test sessions",
+		Long: `The report command is used to show the track points hit in the test sessions of a running application.
+
+A session is started with "POST /session/<name>/start" and stopped with "POST /session/<name>/stop"
+on the tracking service of the application.
+
+Options:
+  --addr <addr>                 Address of the tracking service (default: "127.0.0.1:57005")
+  --unix-socket <path>          Unix socket of the tracking service, used instead of --addr
+  --token <token>               Bearer token of the tracking service (default: $GOAT_TOKEN)
+  --session <name>              Session to report, can be repeated (default: all sessions)
+  --format <format>             Output format (text, json) (default: "text")
+  --timeout <timeout>           Timeout of each request (default: 10s)
+
+Examples:
+  goat report
+  goat report --session login --session checkout
+  goat report --addr 10.0.0.5:57005 --token s3cret --format json`,
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return goat.NewReportExecutor(opts, os.Stdout).Run()
+		},
+	}
+	cmd.Flags().StringVar(&opts.Addr, "addr", "127.0.0.1:57005", "address of the tracking service")
+	cmd.Flags().StringVar(&opts.UnixSocket, "unix-socket", "", "unix socket of the tracking service, used instead of --addr")
+	cmd.Flags().StringVar(&opts.Token, "token", os.Getenv("GOAT_TOKEN"), "bearer token of the tracking service")
+	cmd.Flags().StringArrayVar(&opts.Sessions, "session", nil, "session to report, can be repeated (default: all sessions)")
+	cmd.Flags().StringVar(&opts.Format, "format", "text", "output format (text, json)")
+	cmd.Flags().DurationVar(&opts.Timeout, "timeout", 10*time.Second, "timeout of each request")
+	return cmd
+}
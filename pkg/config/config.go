@@ -29,6 +29,11 @@ func init() {
 
 const goatGeneratedFile = "goat_generated.go"
 
+const goatGeneratedStubFile = "goat_generated_stub.go"
+
+// buildTagRegexp is the regexp of a valid build tag
+var buildTagRegexp = regexp.MustCompile(`^[A-Za-z0-9_.]+$`)
+
 const (
 	// Track generate comment, which is used to mark the generate of the track
 	TrackGenerateComment = "// +goat:generate"
@@ -50,6 +55,28 @@ const (
 	TrackUserComment = "// +goat:user"
 )
 
+const (
+	// Ignore directive, placed in the doc comment of a function declaration
+	// to exclude the function from tracking
+	IgnoreDirective = "//goat:ignore"
+	// Ignore start directive, which is used to mark the start of a block excluded from tracking
+	IgnoreStartDirective = "//goat:ignore-start"
+	// Ignore end directive, which is used to mark the end of a block excluded from tracking
+	IgnoreEndDirective = "//goat:ignore-end"
+	// Ignore file directive, placed at the top of a file to exclude the whole file from tracking
+	IgnoreFileDirective = "//goat:ignore-file"
+	// Name directive, placed directly before a statement to name and tag
+	// the tracking point generated for it, e.g. "//goat:name refund-flow tags=payments,critical"
+	NameDirective = "//goat:name"
+	// Hoisted tag, which tags the tracking points hoisted out of loop bodies
+	HoistedTag = "hoisted"
+	// Condition tags, which tag the tracking points of the condition operands evaluated true and false
+	ConditionTrueTag  = "condition-true"
+	ConditionFalseTag = "condition-false"
+	// Implicit branch tag, which tags the tracking points of the implicit branches of the changed conditions
+	ImplicitBranchTag = "implicit-branch"
+)
+
 var (
 	// Track insert regexp, which is used to match the insert comment
 	TrackInsertRegexp = regexp.MustCompile(`(?m)^\s*` + regexp.QuoteMeta(TrackInsertComment) + `[^\n]*\n`)
@@ -80,13 +107,16 @@ const (
 	GranularityScope
 	// GranularityFunc is the func granularity
 	GranularityFunc
+	// GranularityCondition is the line granularity with the operands of the changed && and || conditions tracked
+	GranularityCondition
 )
 
 const (
-	GranularityLineStr  = "line"
-	GranularityPatchStr = "patch"
-	GranularityScopeStr = "scope"
-	GranularityFuncStr  = "func"
+	GranularityLineStr      = "line"
+	GranularityPatchStr     = "patch"
+	GranularityScopeStr     = "scope"
+	GranularityFuncStr      = "func"
+	GranularityConditionStr = "condition"
 )
 
 // PrinterConfigMode is the mode of the printer config
@@ -144,6 +174,8 @@ func ToGranularity(s string) (Granularity, error) {
 		return GranularityFunc, nil
 	case GranularityScopeStr:
 		return GranularityScope, nil
+	case GranularityConditionStr:
+		return GranularityCondition, nil
 	default:
 		return 0, fmt.Errorf("invalid granularity: %s", s)
 	}
@@ -152,7 +184,7 @@ func ToGranularity(s string) (Granularity, error) {
 // IsValid checks if the granularity is valid
 func (g Granularity) IsValid() bool {
 	switch g {
-	case GranularityLine, GranularityPatch, GranularityFunc, GranularityScope:
+	case GranularityLine, GranularityPatch, GranularityFunc, GranularityScope, GranularityCondition:
 		return true
 	default:
 		return false
@@ -161,7 +193,8 @@ func (g Granularity) IsValid() bool {
 
 // String returns the string representation of the granularity
 func (g Granularity) String() string {
-	return []string{GranularityLineStr, GranularityPatchStr, GranularityFuncStr, GranularityScopeStr}[g-1]
+	return []string{GranularityLineStr, GranularityPatchStr, GranularityScopeStr, GranularityFuncStr,
+		GranularityConditionStr}[g-1]
 }
 
 // Int returns the integer representation of the granularity
@@ -189,6 +222,39 @@ func (g Granularity) IsScope() bool {
 	return g == GranularityScope
 }
 
+// IsCondition checks if the granularity is condition
+func (g Granularity) IsCondition() bool {
+	return g == GranularityCondition
+}
+
+// Coarser returns the next coarser granularity, condition falls back to line
+func (g Granularity) Coarser() Granularity {
+	if g.IsCondition() {
+		return GranularityLine
+	}
+	return g + 1
+}
+
+// Counter layouts of the tracking runtime
+const (
+	// CounterLayoutPacked stores the counters in one array, the smallest layout
+	CounterLayoutPacked = "packed"
+	// CounterLayoutPadded pads each counter to a cache line, avoiding false sharing between track points
+	CounterLayoutPadded = "padded"
+	// CounterLayoutSharded spreads each counter over shards merged at read time,
+	// avoiding contention on a single hot track point
+	CounterLayoutSharded = "sharded"
+)
+
+// Placements of the tracking points in loop bodies
+const (
+	// LoopPlacementHoist hoists the tracking points of a loop body without branching
+	// to a single point right before the loop
+	LoopPlacementHoist = "hoist"
+	// LoopPlacementBody keeps the tracking points in the loop body, evaluated on every iteration
+	LoopPlacementBody = "body"
+)
+
 // DataType is the type of the data
 type DataType int
 
@@ -199,6 +265,9 @@ const (
 	DataTypeBool // default
 	// DataTypeCount is the count type
 	DataTypeCount
+	// DataTypeAverage is the average type, which counts the calls of the function
+	// and records their cumulative duration, it requires the func granularity
+	DataTypeAverage
 )
 
 // String returns the string representation of the data type
@@ -209,7 +278,7 @@ func (d DataType) String() string {
 // IsValid checks if the data type is valid
 func (d DataType) IsValid() bool {
 	switch d {
-	case DataTypeBool, DataTypeCount:
+	case DataTypeBool, DataTypeCount, DataTypeAverage:
 		return true
 	default:
 		return false
@@ -218,8 +287,9 @@ func (d DataType) IsValid() bool {
 
 // dataTypeNames is the names of the data types
 var dataTypeNames = []string{
-	DataTypeBool:  "bool",
-	DataTypeCount: "count",
+	DataTypeBool:    "bool",
+	DataTypeCount:   "count",
+	DataTypeAverage: "average",
 }
 
 // Int returns the integer representation of the data type
@@ -233,6 +303,8 @@ func GetDataType(s string) (DataType, error) {
 		return DataTypeBool, nil
 	case "count":
 		return DataTypeCount, nil
+	case "average":
+		return DataTypeAverage, nil
 	default:
 		return DataTypeBool, fmt.Errorf("invalid data type: %s", s)
 	}
@@ -250,6 +322,12 @@ type Config struct {
 	NewBranch string `yaml:"newBranch"` // valid values: [commit hash, branch name, tag name, "", HEAD]
 	// Files or directories to ignore
 	Ignores []string `yaml:"ignores"`
+	// Glob patterns of files to track, empty means all files
+	Includes []string `yaml:"includes"`
+	// Glob patterns of files to skip, a leading "!" re-includes the matched files
+	Excludes []string `yaml:"excludes"`
+	// Per-path overrides of the granularity and data type
+	Overrides []Override `yaml:"overrides"`
 	// Goat package name
 	GoatPackageName string `yaml:"goatPackageName"`
 	// Goat package alias
@@ -257,13 +335,15 @@ type Config struct {
 	// Goat package path
 	GoatPackagePath string `yaml:"goatPackagePath"`
 	// Granularity
-	Granularity string `yaml:"granularity"` // line, block, scope, func
+	Granularity string `yaml:"granularity"` // line, block, scope, func, condition
 	// Diff precision
 	DiffPrecision int `yaml:"diffPrecision"` // valid values: 1~3
 	// Threads
 	Threads int `yaml:"threads"` // 1~128
 	// Race
 	Race bool `yaml:"race"` // true, false
+	// Build tag guarding the tracking runtime, empty means always compiled in
+	BuildTag string `yaml:"buildTag"` // e.g. goat
 	// Main packages to track
 	MainEntries []string `yaml:"mainEntries"`
 	// Printer config
@@ -278,6 +358,20 @@ type Config struct {
 	printerConfig *printer.Config `yaml:"-"`
 	// Data type
 	DataType string `yaml:"dataType"` // default: bool
+	// Record the first-hit and last-hit Unix times of each track point
+	Timestamps bool `yaml:"timestamps"` // default: false
+	// Memory layout of the counters
+	CounterLayout string `yaml:"counterLayout"` // packed, padded, sharded, default: packed
+	// Width of the counters
+	CounterBits int `yaml:"counterBits"` // 32, 64, default: 32
+	// Maximum tracking points of a function, functions above it fall back to a coarser granularity
+	MaxTrackPointsPerFunc int `yaml:"maxTrackPointsPerFunc"` // default: 0, unlimited
+	// Maximum tracking points in the loop bodies of a function, functions above it fall back to a coarser granularity
+	MaxTrackPointsInLoops int `yaml:"maxTrackPointsInLoops"` // default: 0, unlimited
+	// Placement of the tracking points in loop bodies
+	LoopPlacement string `yaml:"loopPlacement"` // hoist, body, default: hoist
+	// Track the implicit branches of the changed conditions, after an if without else whose branches don't fall through, and in a default case
+	ImplicitBranches bool `yaml:"implicitBranches"` // default: false
 	// Verbose output
 	Verbose bool `yaml:"verbose"` // default: false
 	// Skip sub directories containing go.mod files
@@ -382,23 +476,62 @@ func (c *Config) Validate() error {
 	}
 	c.DataType = dt.String()
 
+	if c.CounterLayout == "" {
+		c.CounterLayout = CounterLayoutPacked
+	}
+	switch c.CounterLayout {
+	case CounterLayoutPacked, CounterLayoutPadded, CounterLayoutSharded:
+	default:
+		return fmt.Errorf("invalid counter layout: %s", c.CounterLayout)
+	}
+
+	if c.CounterBits == 0 {
+		c.CounterBits = 32
+	}
+	if c.CounterBits != 32 && c.CounterBits != 64 {
+		return fmt.Errorf("invalid counter bits: %d", c.CounterBits)
+	}
+
+	if c.LoopPlacement == "" {
+		c.LoopPlacement = LoopPlacementHoist
+	}
+	if err := validateLoopPlacement(c.LoopPlacement); err != nil {
+		return err
+	}
+
+	if c.MaxTrackPointsPerFunc < 0 {
+		return fmt.Errorf("invalid max track points per func: %d", c.MaxTrackPointsPerFunc)
+	}
+	if c.MaxTrackPointsInLoops < 0 {
+		return fmt.Errorf("invalid max track points in loops: %d", c.MaxTrackPointsInLoops)
+	}
+
+	if err := c.validateRules(); err != nil {
+		return err
+	}
+
+	if c.BuildTag != "" && !buildTagRegexp.MatchString(c.BuildTag) {
+		return fmt.Errorf("invalid build tag: %s", c.BuildTag)
+	}
+
 	// Default to skipping nested modules for safety and simplicity
 	// Note: This field defaults to true for safety, but we only set it if it wasn't
 	// explicitly configured by the user. Since we can't distinguish between
 	// "not set" and "explicitly set to false" in YAML, we'll leave the user's
 	// choice intact if they've set it via command line or config file.
 
-	// ignore goat_generated.go
-	goatFile := c.GoatGeneratedFile()
-	found := false
-	for _, file := range c.Ignores {
-		if file == goatFile {
-			found = true
-			break
+	// ignore goat_generated.go and goat_generated_stub.go
+	for _, goatFile := range []string{c.GoatGeneratedFile(), c.GoatGeneratedStubFile()} {
+		found := false
+		for _, file := range c.Ignores {
+			if file == goatFile {
+				found = true
+				break
+			}
+		}
+		if !found {
+			c.Ignores = append(c.Ignores, goatFile)
 		}
-	}
-	if !found {
-		c.Ignores = append(c.Ignores, goatFile)
 	}
 
 	// Initialize project root for caching
@@ -437,6 +570,17 @@ func (c *Config) GoatGeneratedFile() string {
 	return filepath.Join(c.GoatPackagePath, goatGeneratedFile)
 }
 
+// GoatGeneratedStubFile returns the goat generated stub file path,
+// the stub is compiled in place of the generated file when the build tag is not set
+func (c *Config) GoatGeneratedStubFile() string {
+	return filepath.Join(c.GoatPackagePath, goatGeneratedStubFile)
+}
+
+// IsGoatGeneratedFile checks if the path is the goat generated file or its stub
+func (c *Config) IsGoatGeneratedFile(path string) bool {
+	return path == c.GoatGeneratedFile() || path == c.GoatGeneratedStubFile()
+}
+
 func (c *Config) PrinterConfig() *printer.Config {
 	if c.printerConfig != nil {
 		return c.printerConfig
@@ -467,6 +611,15 @@ func (c *Config) IsNewRepository() bool {
 	return c.OldBranch == "INIT"
 }
 
+// Commit returns the short commit hash of the new branch, empty if it cannot be resolved
+func (c *Config) Commit() string {
+	commitHash, err := getShortCommitHash(c.NewBranch)
+	if err != nil {
+		return ""
+	}
+	return commitHash
+}
+
 // getShortCommitHash returns the short commit hash of the given reference
 func getShortCommitHash(ref string) (string, error) {
 	repo, err := git.PlainOpen(".")
@@ -615,6 +768,17 @@ func (c *Config) isBelongUncached(absDir string) bool {
 
 // IsTargetDir checks if the directory is a target directory
 func (c *Config) IsTargetDir(dir string) bool {
+	if !c.IsSourceDir(dir) {
+		return false
+	}
+	dir = filepath.ToSlash(filepath.Clean(dir))
+	// check if the dir is excluded by the glob rules
+	return dir == "." || !c.isExcluded(dir) || c.mayReinclude(dir)
+}
+
+// IsSourceDir checks if the directory may hold project sources, regardless of the include and exclude rules
+func (c *Config) IsSourceDir(dir string) bool {
+	dir = filepath.ToSlash(filepath.Clean(dir))
 	// check if the dir is in the excludes
 	if dir == "vendor" || dir == "testdata" || dir == "node_modules" {
 		return false
@@ -626,13 +790,10 @@ func (c *Config) IsTargetDir(dir string) bool {
 			return false
 		}
 	}
-	// check if the file is in the excludes
-	for _, exclude := range c.Ignores {
-		if dir == exclude || strings.HasPrefix(dir, exclude) {
-			return false
-		}
+	// check if the dir is in the ignores
+	if c.isIgnored(dir) {
+		return false
 	}
-
 	// check if this directory contains a nested go.mod file (skip nested modules)
 	if c.SkipNestedModules && dir != "." && c.IsBelongNestedModule(dir) {
 		return false
@@ -643,5 +804,17 @@ func (c *Config) IsTargetDir(dir string) bool {
 
 // IsTargetFile checks if the file is a target file
 func (c *Config) IsTargetFile(fileName string) bool {
-	return c.IsTargetDir(filepath.Dir(fileName)) && utils.IsGoFile(fileName)
+	if !c.IsSourceFile(fileName) || !c.IsTargetDir(filepath.Dir(fileName)) {
+		return false
+	}
+	fileName = filepath.ToSlash(filepath.Clean(fileName))
+	return !c.isExcluded(fileName) && c.isIncluded(fileName)
+}
+
+// IsSourceFile checks if the file is a project Go file, regardless of the include and exclude rules
+func (c *Config) IsSourceFile(fileName string) bool {
+	if !utils.IsGoFile(fileName) || !c.IsSourceDir(filepath.Dir(fileName)) {
+		return false
+	}
+	return !c.isIgnored(filepath.ToSlash(filepath.Clean(fileName)))
 }
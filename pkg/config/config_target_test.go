@@ -282,3 +282,147 @@ func TestConfigIsTargetFile(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigGlobRules(t *testing.T) {
+	cfg := &Config{
+		DiffPrecision: 2,      // Required field
+		AppVersion:    "test", // Avoid git validation
+		Ignores:       []string{"api"},
+		Includes:      []string{"pkg/**", "cmd/**", "api/**", "apiserver/**"},
+		Excludes:      []string{"**/*.pb.go", "!pkg/keep/keep.pb.go", "pkg/legacy"},
+		Overrides: []Override{
+			{Path: "internal/legacy/**", Granularity: GranularityFuncStr},
+			{Path: "pkg/billing/**", Granularity: GranularityLineStr, DataType: "count"},
+			{Path: "pkg/codec/**", LoopPlacement: LoopPlacementBody},
+		},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Failed to validate config: %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		fileName string
+		want     bool
+	}{
+		{"ignored directory", "api/handler.go", false},
+		{"ignore is not a prefix match", "apiserver/handler.go", true},
+		{"included file", "pkg/utils/file.go", true},
+		{"not included file", "internal/x/file.go", false},
+		{"excluded generated file", "pkg/api/api.pb.go", false},
+		{"re-included file", "pkg/keep/keep.pb.go", true},
+		{"excluded directory", "pkg/legacy/old.go", false},
+		{"excluded directory is not a prefix match", "pkg/legacyx/new.go", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := cfg.IsTargetFile(tc.fileName); got != tc.want {
+				t.Errorf("Config.IsTargetFile(%q) = %v, want %v", tc.fileName, got, tc.want)
+			}
+		})
+	}
+
+	if cfg.IsTargetDir("pkg/legacy") {
+		t.Errorf("Config.IsTargetDir(%q) = true, want false", "pkg/legacy")
+	}
+	if !cfg.IsTargetDir("pkg/keep") {
+		t.Errorf("Config.IsTargetDir(%q) = false, want true", "pkg/keep")
+	}
+
+	if got := cfg.GetGranularityOf("internal/legacy/a/b.go"); got != GranularityFunc {
+		t.Errorf("Config.GetGranularityOf() = %v, want %v", got, GranularityFunc)
+	}
+	if got := cfg.GetGranularityOf("pkg/billing/pay.go"); got != GranularityLine {
+		t.Errorf("Config.GetGranularityOf() = %v, want %v", got, GranularityLine)
+	}
+	if got := cfg.GetGranularityOf("pkg/other/x.go"); got != GranularityPatch {
+		t.Errorf("Config.GetGranularityOf() = %v, want %v", got, GranularityPatch)
+	}
+	if got := cfg.GetDataTypeOf("pkg/billing/pay.go"); got != DataTypeCount {
+		t.Errorf("Config.GetDataTypeOf() = %v, want %v", got, DataTypeCount)
+	}
+	if got := cfg.GetDataTypeOf("internal/legacy/a/b.go"); got != DataTypeBool {
+		t.Errorf("Config.GetDataTypeOf() = %v, want %v", got, DataTypeBool)
+	}
+	if got := cfg.GetLoopPlacementOf("pkg/codec/json.go"); got != LoopPlacementBody {
+		t.Errorf("Config.GetLoopPlacementOf() = %v, want %v", got, LoopPlacementBody)
+	}
+	if got := cfg.GetLoopPlacementOf("pkg/billing/pay.go"); got != LoopPlacementHoist {
+		t.Errorf("Config.GetLoopPlacementOf() = %v, want %v", got, LoopPlacementHoist)
+	}
+}
+
+func TestConfigNormalizedRules(t *testing.T) {
+	cfg := &Config{
+		DiffPrecision: 2,      // Required field
+		AppVersion:    "test", // Avoid git validation
+		Ignores:       []string{"./api/", "pkg//gen"},
+		Includes:      []string{"./pkg/**", "cmd//**"},
+		Excludes:      []string{"pkg/legacy/", "!./pkg/legacy/keep.go"},
+		Overrides:     []Override{{Path: "./pkg/billing/**", Granularity: GranularityFuncStr}},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Failed to validate config: %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		fileName string
+		target   bool
+		source   bool
+	}{
+		{"included file", "pkg/utils/file.go", true, true},
+		{"included file with double slash", "cmd/goat/main.go", true, true},
+		{"ignored directory", "api/handler.go", false, false},
+		{"ignored nested directory", "pkg/gen/gen.go", false, false},
+		{"excluded directory", "pkg/legacy/old.go", false, true},
+		{"re-included file", "pkg/legacy/keep.go", true, true},
+		{"not included file", "internal/x/file.go", false, true},
+		{"test file", "pkg/utils/file_test.go", false, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := cfg.IsTargetFile(tc.fileName); got != tc.target {
+				t.Errorf("Config.IsTargetFile(%q) = %v, want %v", tc.fileName, got, tc.target)
+			}
+			if got := cfg.IsSourceFile(tc.fileName); got != tc.source {
+				t.Errorf("Config.IsSourceFile(%q) = %v, want %v", tc.fileName, got, tc.source)
+			}
+		})
+	}
+
+	if got := cfg.GetGranularityOf("pkg/billing/pay.go"); got != GranularityFunc {
+		t.Errorf("Config.GetGranularityOf() = %v, want %v", got, GranularityFunc)
+	}
+	if !cfg.IsSourceDir("pkg/legacy") || cfg.IsTargetDir("pkg/legacy/sub") {
+		t.Errorf("Config.IsSourceDir/IsTargetDir(%q) mismatch", "pkg/legacy")
+	}
+}
+
+func TestConfigValidateRules(t *testing.T) {
+	testCases := []struct {
+		name string
+		cfg  *Config
+	}{
+		{"invalid include", &Config{Includes: []string{"pkg/[a-"}}},
+		{"invalid exclude", &Config{Excludes: []string{"!pkg/[a-"}}},
+		{"invalid override granularity", &Config{Overrides: []Override{{Path: "pkg/**", Granularity: "block"}}}},
+		{"invalid override data type", &Config{Overrides: []Override{{Path: "pkg/**", DataType: "float"}}}},
+		{"invalid loop placement", &Config{LoopPlacement: "unroll"}},
+		{"invalid override loop placement", &Config{Overrides: []Override{{Path: "pkg/**", LoopPlacement: "unroll"}}}},
+		{"empty override path", &Config{Overrides: []Override{{Granularity: GranularityFuncStr}}}},
+		{"average without func granularity", &Config{DataType: "average", Granularity: GranularityLineStr}},
+		{"average override without func granularity", &Config{Overrides: []Override{{Path: "pkg/**", DataType: "average"}}}},
+		{"line override of average", &Config{DataType: "average", Granularity: GranularityFuncStr,
+			Overrides: []Override{{Path: "pkg/**", Granularity: GranularityLineStr}}}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.cfg.DiffPrecision = 1
+			tc.cfg.AppVersion = "test"
+			if err := tc.cfg.Validate(); err == nil {
+				t.Errorf("Config.Validate() error = nil, wantErr true")
+			}
+		})
+	}
+}
@@ -60,6 +60,7 @@ func TestToGranularity(t *testing.T) {
 		{"patch", GranularityPatchStr, GranularityPatch, false},
 		{"func", GranularityFuncStr, GranularityFunc, false},
 		{"scope", GranularityScopeStr, GranularityScope, false},
+		{"condition", GranularityConditionStr, GranularityCondition, false},
 		{"invalid", "invalid", 0, true},
 	}
 
@@ -87,6 +88,7 @@ func TestGranularityIsValid(t *testing.T) {
 		{"patch", GranularityPatch, true},
 		{"func", GranularityFunc, true},
 		{"scope", GranularityScope, true},
+		{"condition", GranularityCondition, true},
 		{"invalid", Granularity(0), false},
 		{"invalid high", Granularity(100), false},
 	}
@@ -109,6 +111,7 @@ func TestGranularityString(t *testing.T) {
 		{"patch", GranularityPatch},
 		{"func", GranularityFunc},
 		{"scope", GranularityScope},
+		{"condition", GranularityCondition},
 	}
 
 	for _, tt := range tests {
@@ -119,6 +122,37 @@ func TestGranularityString(t *testing.T) {
 	}
 }
 
+func TestGranularityStringRoundTrip(t *testing.T) {
+	for g := GranularityLine; g.IsValid(); g++ {
+		t.Run(g.String(), func(t *testing.T) {
+			got, err := ToGranularity(g.String())
+			if err != nil || got != g {
+				t.Errorf("ToGranularity(%q) = %v, %v, want %v", g.String(), got, err, g.Int())
+			}
+		})
+	}
+}
+
+func TestGranularityCoarser(t *testing.T) {
+	tests := []struct {
+		granularity Granularity
+		want        Granularity
+	}{
+		{GranularityCondition, GranularityLine},
+		{GranularityLine, GranularityPatch},
+		{GranularityPatch, GranularityScope},
+		{GranularityScope, GranularityFunc},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.granularity.String(), func(t *testing.T) {
+			if got := tt.granularity.Coarser(); got != tt.want {
+				t.Errorf("Granularity.Coarser() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestGranularityInt(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -179,6 +213,7 @@ func TestDataTypeString(t *testing.T) {
 	}{
 		{"bool", DataTypeBool, "bool"},
 		{"count", DataTypeCount, "count"},
+		{"average", DataTypeAverage, "average"},
 	}
 
 	for _, tt := range tests {
@@ -198,6 +233,7 @@ func TestDataTypeIsValid(t *testing.T) {
 	}{
 		{"bool", DataTypeBool, true},
 		{"count", DataTypeCount, true},
+		{"average", DataTypeAverage, true},
 		{"invalid", DataType(0), false},
 		{"invalid high", DataType(100), false},
 	}
@@ -219,6 +255,7 @@ func TestDataTypeInt(t *testing.T) {
 	}{
 		{"bool", DataTypeBool, 1},
 		{"count", DataTypeCount, 2},
+		{"average", DataTypeAverage, 3},
 	}
 
 	for _, tt := range tests {
@@ -239,6 +276,7 @@ func TestGetDataType(t *testing.T) {
 	}{
 		{"bool", "bool", DataTypeBool, false},
 		{"count", "count", DataTypeCount, false},
+		{"average", "average", DataTypeAverage, false},
 		{"invalid", "invalid", DataTypeBool, true},
 	}
 
@@ -306,6 +344,51 @@ func TestConfigValidate(t *testing.T) {
 	if err := invalidDataType.Validate(); err == nil {
 		t.Errorf("Config.Validate() with invalid data type error = nil, wantErr true")
 	}
+
+	// Test with invalid build tag
+	invalidBuildTag := &Config{
+		DiffPrecision: 1,
+		BuildTag:      "!goat",
+		AppVersion:    "test-version",
+	}
+	if err := invalidBuildTag.Validate(); err == nil {
+		t.Errorf("Config.Validate() with invalid build tag error = nil, wantErr true")
+	}
+
+	// Test the default counter layout and width
+	if validConfig.CounterLayout != CounterLayoutPacked || validConfig.CounterBits != 32 {
+		t.Errorf("Config.Validate() counter = %s/%d, want %s/32", validConfig.CounterLayout, validConfig.CounterBits, CounterLayoutPacked)
+	}
+
+	// Test with invalid counter layout
+	invalidCounterLayout := &Config{
+		DiffPrecision: 1,
+		CounterLayout: "striped",
+		AppVersion:    "test-version",
+	}
+	if err := invalidCounterLayout.Validate(); err == nil {
+		t.Errorf("Config.Validate() with invalid counter layout error = nil, wantErr true")
+	}
+
+	// Test with invalid counter bits
+	invalidCounterBits := &Config{
+		DiffPrecision: 1,
+		CounterBits:   16,
+		AppVersion:    "test-version",
+	}
+	if err := invalidCounterBits.Validate(); err == nil {
+		t.Errorf("Config.Validate() with invalid counter bits error = nil, wantErr true")
+	}
+
+	// Test with invalid track point budget
+	invalidBudget := &Config{
+		DiffPrecision:         1,
+		MaxTrackPointsInLoops: -1,
+		AppVersion:            "test-version",
+	}
+	if err := invalidBudget.Validate(); err == nil {
+		t.Errorf("Config.Validate() with invalid track point budget error = nil, wantErr true")
+	}
 }
 
 func TestConfigGetGranularity(t *testing.T) {
@@ -367,6 +450,22 @@ func TestConfigGoatGeneratedFile(t *testing.T) {
 	}
 }
 
+func TestConfigGoatGeneratedStubFile(t *testing.T) {
+	c := &Config{
+		GoatPackagePath: "path/to/goat",
+	}
+	expected := filepath.Join("path/to/goat", goatGeneratedStubFile)
+	if got := c.GoatGeneratedStubFile(); got != expected {
+		t.Errorf("Config.GoatGeneratedStubFile() = %v, want %v", got, expected)
+	}
+	if !c.IsGoatGeneratedFile(c.GoatGeneratedFile()) || !c.IsGoatGeneratedFile(expected) {
+		t.Errorf("Config.IsGoatGeneratedFile() = false for the generated files, want true")
+	}
+	if c.IsGoatGeneratedFile("path/to/goat/other.go") {
+		t.Errorf("Config.IsGoatGeneratedFile() = true for other files, want false")
+	}
+}
+
 func TestConfigPrinterConfig(t *testing.T) {
 	c := &Config{
 		PrinterConfigMode:     []PrinterConfigMode{PrinterConfigModeUseSpaces},
@@ -0,0 +1,221 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/monshunter/goat/pkg/utils"
+)
+
+// negatePrefix is the prefix of an exclude rule that re-includes the matched paths
+const negatePrefix = "!"
+
+// Override overrides the tracking options of the files matching Path
+type Override struct {
+	// Path is the doublestar glob pattern of the files to override, relative to the project root
+	Path string `yaml:"path"`
+	// Granularity overrides the granularity of the matched files
+	Granularity string `yaml:"granularity,omitempty"`
+	// DataType overrides the data type of the tracking points in the matched files
+	DataType string `yaml:"dataType,omitempty"`
+	// LoopPlacement overrides the placement of the tracking points in the loop bodies of the matched files
+	LoopPlacement string `yaml:"loopPlacement,omitempty"`
+}
+
+// Validate validates the override
+func (o *Override) Validate() error {
+	if err := utils.ValidateGlob(o.Path); err != nil {
+		return err
+	}
+	if o.Granularity != "" {
+		if _, err := ToGranularity(o.Granularity); err != nil {
+			return err
+		}
+	}
+	if o.DataType != "" {
+		if _, err := GetDataType(o.DataType); err != nil {
+			return err
+		}
+	}
+	if o.LoopPlacement != "" {
+		if err := validateLoopPlacement(o.LoopPlacement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateLoopPlacement validates the placement of the tracking points in loop bodies
+func validateLoopPlacement(placement string) error {
+	if placement != LoopPlacementHoist && placement != LoopPlacementBody {
+		return fmt.Errorf("invalid loop placement: %s", placement)
+	}
+	return nil
+}
+
+// validateRules validates the includes, excludes and overrides,
+// and normalizes their patterns and the ignores like the paths they are matched against
+func (c *Config) validateRules() error {
+	for i, include := range c.Includes {
+		if err := utils.ValidateGlob(include); err != nil {
+			return fmt.Errorf("invalid include: %w", err)
+		}
+		c.Includes[i] = normalizePattern(include)
+	}
+	for i, exclude := range c.Excludes {
+		pattern := strings.TrimPrefix(exclude, negatePrefix)
+		if err := utils.ValidateGlob(pattern); err != nil {
+			return fmt.Errorf("invalid exclude: %w", err)
+		}
+		c.Excludes[i] = strings.TrimSuffix(exclude, pattern) + normalizePattern(pattern)
+	}
+	for i := range c.Overrides {
+		if err := c.Overrides[i].Validate(); err != nil {
+			return fmt.Errorf("invalid override %q: %w", c.Overrides[i].Path, err)
+		}
+		c.Overrides[i].Path = normalizePattern(c.Overrides[i].Path)
+	}
+	for i, ignore := range c.Ignores {
+		c.Ignores[i] = normalizePattern(ignore)
+	}
+	return c.validateAverage()
+}
+
+// normalizePattern cleans the pattern and uses forward slashes, as the matched paths do,
+// so that "./pkg/foo/**" and "pkg//foo" match
+func normalizePattern(pattern string) string {
+	if pattern == "" {
+		return pattern
+	}
+	return path.Clean(filepath.ToSlash(pattern))
+}
+
+// validateAverage checks that the files of the average data type are tracked at the func granularity,
+// the duration of a timed tracking point is measured from the top of the function to its return
+func (c *Config) validateAverage() error {
+	if c.GetDataType() == DataTypeAverage && !c.GetGranularity().IsFunc() {
+		return fmt.Errorf("data type %s requires granularity %s, got %s", DataTypeAverage, GranularityFuncStr, c.Granularity)
+	}
+	for _, override := range c.Overrides {
+		dataType, granularity := c.GetDataType(), c.GetGranularity()
+		if override.DataType != "" {
+			dataType, _ = GetDataType(override.DataType)
+		}
+		if override.Granularity != "" {
+			granularity, _ = ToGranularity(override.Granularity)
+		}
+		if dataType == DataTypeAverage && !granularity.IsFunc() {
+			return fmt.Errorf("invalid override %q: data type %s requires granularity %s",
+				override.Path, DataTypeAverage, GranularityFuncStr)
+		}
+	}
+	return nil
+}
+
+// isIgnored checks if the path is one of the ignores or is under one of them
+func (c *Config) isIgnored(path string) bool {
+	for _, ignore := range c.Ignores {
+		ignore = strings.TrimSuffix(filepath.ToSlash(ignore), "/")
+		if path == ignore || strings.HasPrefix(path, ignore+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// isExcluded checks if the path is excluded by the exclude rules.
+// Rules are evaluated in order and the last matching rule wins,
+// a rule starting with "!" re-includes the paths it matches
+func (c *Config) isExcluded(path string) bool {
+	excluded := false
+	for _, exclude := range c.Excludes {
+		negated := strings.HasPrefix(exclude, negatePrefix)
+		if utils.MatchGlobOrParent(strings.TrimPrefix(exclude, negatePrefix), path) {
+			excluded = !negated
+		}
+	}
+	return excluded
+}
+
+// mayReinclude checks if any negated exclude rule may match a path under the directory,
+// in which case the directory must still be walked even though it is excluded
+func (c *Config) mayReinclude(dir string) bool {
+	for _, exclude := range c.Excludes {
+		if !strings.HasPrefix(exclude, negatePrefix) {
+			continue
+		}
+		pattern := strings.TrimPrefix(exclude, negatePrefix)
+		if strings.Contains(pattern, "**") || utils.MatchGlobOrParent(pattern, dir) {
+			return true
+		}
+		// the pattern is deeper than the directory, check the leading segments
+		segments := strings.Split(filepath.ToSlash(pattern), "/")
+		depth := len(strings.Split(dir, "/"))
+		if len(segments) > depth && utils.MatchGlob(strings.Join(segments[:depth], "/"), dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// isIncluded checks if the file is selected by the include rules, no includes means all files
+func (c *Config) isIncluded(fileName string) bool {
+	if len(c.Includes) == 0 {
+		return true
+	}
+	for _, include := range c.Includes {
+		if utils.MatchGlobOrParent(include, fileName) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetGranularityOf returns the granularity of the file, taking the overrides into account
+func (c *Config) GetGranularityOf(fileName string) Granularity {
+	granularity := c.GetGranularity()
+	fileName = filepath.ToSlash(filepath.Clean(fileName))
+	for _, override := range c.Overrides {
+		if override.Granularity == "" || !utils.MatchGlobOrParent(override.Path, fileName) {
+			continue
+		}
+		if g, err := ToGranularity(override.Granularity); err == nil {
+			granularity = g
+		}
+	}
+	return granularity
+}
+
+// GetDataTypeOf returns the data type of the file, taking the overrides into account
+func (c *Config) GetDataTypeOf(fileName string) DataType {
+	dataType := c.GetDataType()
+	fileName = filepath.ToSlash(filepath.Clean(fileName))
+	for _, override := range c.Overrides {
+		if override.DataType == "" || !utils.MatchGlobOrParent(override.Path, fileName) {
+			continue
+		}
+		if dt, err := GetDataType(override.DataType); err == nil {
+			dataType = dt
+		}
+	}
+	return dataType
+}
+
+// GetLoopPlacementOf returns the placement of the tracking points in the loop bodies of the file,
+// taking the overrides into account
+func (c *Config) GetLoopPlacementOf(fileName string) string {
+	placement := c.LoopPlacement
+	if placement == "" {
+		placement = LoopPlacementHoist
+	}
+	fileName = filepath.ToSlash(filepath.Clean(fileName))
+	for _, override := range c.Overrides {
+		if override.LoopPlacement == "" || !utils.MatchGlobOrParent(override.Path, fileName) {
+			continue
+		}
+		placement = override.LoopPlacement
+	}
+	return placement
+}
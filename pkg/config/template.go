@@ -39,6 +39,45 @@ ignores:{{range .Ignores}}
   - {{ . -}}
 {{- end}}
 
+## Glob rules selecting the files to track (doublestar syntax, relative to project root)
+## includes: when not empty, only files matching at least one pattern are tracked
+## excludes: files matching a pattern are skipped, a leading "!" re-includes them,
+##           the last matching rule wins
+## Examples:
+## - "pkg/**" (every file under pkg)
+## - "**/*.pb.go" (generated protobuf files at any depth)
+## - "!internal/api/keep.go" (re-include a file skipped by a previous rule)
+includes:{{range .Includes}}
+  - "{{ . -}}"
+{{- end}}
+
+excludes:{{range .Excludes}}
+  - "{{ . -}}"
+{{- end}}
+
+## Per-path overrides of granularity, dataType and loopPlacement, the last matching rule wins
+## Example:
+## overrides:
+##   - path: "internal/legacy/**"
+##     granularity: func
+##   - path: "pkg/billing/**"
+##     granularity: line
+##     dataType: count
+##   - path: "pkg/codec/**"
+##     loopPlacement: body
+overrides:{{range .Overrides}}
+  - path: "{{ .Path }}"
+  {{- if .Granularity}}
+    granularity: {{ .Granularity }}
+  {{- end}}
+  {{- if .DataType}}
+    dataType: {{ .DataType }}
+  {{- end}}
+  {{- if .LoopPlacement}}
+    loopPlacement: {{ .LoopPlacement }}
+  {{- end}}
+{{- end}}
+
 ## GOAT package configuration
 ## Package name used in imports
 goatPackageName: {{.GoatPackageName}}
@@ -55,6 +94,8 @@ goatPackagePath: {{.GoatPackagePath}}
 ## - patch: Track changes at patch level (diff patches in same scope)
 ## - scope: Track changes at scope level when any part is modified
 ## - func: Track changes at function level when any part is modified
+## - condition: Track changes at line level, and each operand of the changed && and || conditions
+##   of if, for and switch cases as evaluated true or false
 granularity: {{.Granularity}}
 
 ## Diff precision level (1-3, default: 1)
@@ -73,6 +114,11 @@ threads: {{.Threads}}
 ## false: Disable race detection (better performance)
 race: {{.Race}}
 
+## Build tag guarding the tracking runtime (default: "", always compiled in)
+## When set (e.g. "goat"), the tracking runtime is compiled in only by "go build -tags goat",
+## other builds use a generated no-op stub, so the tracked tree can be shipped as a release
+buildTag: "{{.BuildTag}}"
+
 ## Main packages to track (default: all)
 ## Specify relative paths to main packages from project root
 ## Examples:
@@ -97,9 +143,44 @@ printerConfigTabwidth: {{.PrinterConfigTabwidth}}
 printerConfigIndent: {{.PrinterConfigIndent}}
 
 ## Data type for tracking (default: bool)
-## Options: bool, count
+## Options: bool, count, average
+## average counts the calls of each function and records their cumulative duration,
+## it requires granularity: func
 dataType: {{.DataType}}
 
+## Record the first-hit and last-hit Unix times of each track point (default: false)
+## The times are reported as firstHit and lastHit in /track, at the cost of a clock read per hit
+timestamps: {{.Timestamps}}
+
+## Memory layout of the counters (default: packed)
+## packed: one array, the smallest layout
+## padded: one cache line per track point, no false sharing between hot track points
+## sharded: per-goroutine shards merged at read time, no contention on a single hot track point
+counterLayout: {{.CounterLayout}}
+
+## Width of the counters, 32 or 64 (default: 32)
+## 64-bit counters do not overflow on track points hit more than 4 billion times
+counterBits: {{.CounterBits}}
+
+## Tracking budget of each function (default: 0, unlimited)
+## A function with more tracking points than maxTrackPointsPerFunc, or more tracking points
+## in its loop bodies than maxTrackPointsInLoops, falls back to a coarser granularity
+## (line -> patch -> scope -> func) until it fits the budget
+maxTrackPointsPerFunc: {{.MaxTrackPointsPerFunc}}
+maxTrackPointsInLoops: {{.MaxTrackPointsInLoops}}
+
+## Placement of the tracking points in loop bodies (default: hoist)
+## hoist: the tracking points of a loop body without branching are replaced by one point
+##        right before the loop, tagged "hoisted", so hot loops pay no per-iteration cost
+## body: the tracking points stay in the loop body and are evaluated on every iteration
+loopPlacement: {{.LoopPlacement}}
+
+## Track the implicit branches of the changed conditions (default: false)
+## An if without else whose branches all return, break, continue, goto or panic gets a tracking point right after it,
+## and a switch or type switch without default gets a synthesized default case with a tracking point,
+## both tagged "implicit-branch"
+implicitBranches: {{.ImplicitBranches}}
+
 ## Enable verbose output (default: false)
 verbose: {{.Verbose}}
 
@@ -46,11 +46,11 @@ func (d *DifferInit) AnalyzeChanges() ([]*FileChange, error) {
 			}
 			return nil
 		}
-		if !utils.IsGoFile(path) {
+		if !d.cfg.IsTargetFile(path) {
 			return nil
 		}
-		// skip goat_generated.go
-		if path == d.cfg.GoatGeneratedFile() {
+		// skip goat_generated.go and goat_generated_stub.go
+		if d.cfg.IsGoatGeneratedFile(path) {
 			return nil
 		}
 		// get file content
@@ -0,0 +1,417 @@
+package goat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/monshunter/goat/pkg/log"
+
+	"github.com/monshunter/goat/pkg/config"
+	"github.com/monshunter/goat/pkg/diff"
+	"github.com/monshunter/goat/pkg/tracking"
+	"github.com/monshunter/goat/pkg/tracking/increment"
+	"github.com/monshunter/goat/pkg/utils"
+)
+
+// BenchOptions is the options of the bench
+type BenchOptions struct {
+	// Package is the directory of the package with the benchmarks, relative to the project root
+	Package string
+	// Bench is the regular expression of the benchmarks to run
+	Bench string
+	// BenchTime is the -benchtime of the benchmarks, the go test default if empty
+	BenchTime string
+	// Count is the number of runs of each benchmark, the results are averaged
+	Count int
+	// Granularities is the granularities to instrument the package with
+	Granularities []string
+	// DataTypes is the data types to instrument the package with,
+	// average is only benchmarked with the func granularity
+	DataTypes []string
+	// Format is the output format, "text" or "json"
+	Format string
+}
+
+// benchResult is the averaged result of a benchmark
+type benchResult struct {
+	NsPerOp     float64 `json:"nsPerOp"`
+	BytesPerOp  float64 `json:"bytesPerOp"`
+	AllocsPerOp float64 `json:"allocsPerOp"`
+}
+
+// benchComparison is the result of a benchmark with instrumentation compared to the baseline
+type benchComparison struct {
+	Benchmark        string      `json:"benchmark"`
+	Granularity      string      `json:"granularity"`
+	DataType         string      `json:"dataType"`
+	TrackPoints      int         `json:"trackPoints"`
+	Baseline         benchResult `json:"baseline"`
+	Tracked          benchResult `json:"tracked"`
+	NsPerOpDelta     float64     `json:"nsPerOpDelta"`     // percentage
+	BytesPerOpDelta  float64     `json:"bytesPerOpDelta"`  // absolute
+	AllocsPerOpDelta float64     `json:"allocsPerOpDelta"` // absolute
+}
+
+// benchLineRegexp matches a result line of go test -bench -benchmem
+var benchLineRegexp = regexp.MustCompile(`^(Benchmark\S+?)(?:-\d+)?\s+\d+\s+([\d.]+) ns/op(?:\s+([\d.]+) B/op)?(?:\s+([\d.]+) allocs/op)?`)
+
+// BenchExecutor is the executor for the bench
+type BenchExecutor struct {
+	cfg      *config.Config
+	opts     BenchOptions
+	out      io.Writer
+	goModule string
+}
+
+// NewBenchExecutor creates a new bench executor writing to out
+func NewBenchExecutor(cfg *config.Config, opts BenchOptions, out io.Writer) *BenchExecutor {
+	return &BenchExecutor{
+		cfg:      cfg,
+		opts:     opts,
+		out:      out,
+		goModule: config.GoModuleName(),
+	}
+}
+
+// Run runs the bench executor
+func (b *BenchExecutor) Run() error {
+	if err := b.validate(); err != nil {
+		return err
+	}
+	workDir, err := os.MkdirTemp("", "goat-bench-")
+	if err != nil {
+		return fmt.Errorf("failed to create work directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	log.Infof("Copying project to %s", workDir)
+	if err := copyProject(".", workDir); err != nil {
+		return fmt.Errorf("failed to copy project: %w", err)
+	}
+	pkgDir := filepath.Join(workDir, b.opts.Package)
+
+	log.Infof("Running baseline benchmarks of %s", b.opts.Package)
+	baseline, err := b.runBenchmarks(pkgDir)
+	if err != nil {
+		return fmt.Errorf("failed to run baseline benchmarks: %w", err)
+	}
+	if len(baseline) == 0 {
+		return fmt.Errorf("no benchmark matching %q found in %s", b.opts.Bench, b.opts.Package)
+	}
+
+	comparisons := make([]benchComparison, 0)
+	for _, granularityStr := range b.opts.Granularities {
+		granularity, _ := config.ToGranularity(granularityStr)
+		for _, dataTypeStr := range b.opts.DataTypes {
+			dataType, _ := config.GetDataType(dataTypeStr)
+			if dataType == config.DataTypeAverage && !granularity.IsFunc() {
+				log.Debugf("Skipping %s data type with %s granularity", dataType, granularity)
+				continue
+			}
+			log.Infof("Running benchmarks with %s granularity and %s data type", granularity, dataType)
+			count, err := b.instrument(workDir, granularity, dataType)
+			if err != nil {
+				return fmt.Errorf("failed to instrument %s with %s granularity and %s data type: %w",
+					b.opts.Package, granularity, dataType, err)
+			}
+			tracked, err := b.runBenchmarks(pkgDir)
+			if err != nil {
+				return fmt.Errorf("failed to run benchmarks with %s granularity and %s data type: %w",
+					granularity, dataType, err)
+			}
+			comparisons = append(comparisons, compareBenchmarks(baseline, tracked,
+				granularity.String(), dataType.String(), count)...)
+		}
+	}
+
+	if b.opts.Format == "json" {
+		encoder := json.NewEncoder(b.out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(comparisons)
+	}
+	return b.writeText(comparisons)
+}
+
+// validate validates and defaults the options
+func (b *BenchExecutor) validate() error {
+	if b.opts.Format == "" {
+		b.opts.Format = "text"
+	}
+	if b.opts.Format != "text" && b.opts.Format != "json" {
+		return fmt.Errorf("invalid bench format %s, expected text or json", b.opts.Format)
+	}
+	if b.opts.Package == "" {
+		b.opts.Package = "."
+	}
+	b.opts.Package = filepath.Clean(b.opts.Package)
+	if filepath.IsAbs(b.opts.Package) || strings.HasPrefix(b.opts.Package, "..") {
+		return fmt.Errorf("invalid bench package %s, expected a directory in the project", b.opts.Package)
+	}
+	if b.opts.Bench == "" {
+		b.opts.Bench = "."
+	}
+	if b.opts.Count < 1 {
+		b.opts.Count = 1
+	}
+	if len(b.opts.Granularities) == 0 {
+		b.opts.Granularities = []string{config.GranularityLineStr, config.GranularityPatchStr,
+			config.GranularityScopeStr, config.GranularityFuncStr}
+	}
+	for _, granularity := range b.opts.Granularities {
+		if _, err := config.ToGranularity(granularity); err != nil {
+			return fmt.Errorf("invalid granularity: %w", err)
+		}
+	}
+	if len(b.opts.DataTypes) == 0 {
+		b.opts.DataTypes = []string{config.DataTypeBool.String(), config.DataTypeCount.String(),
+			config.DataTypeAverage.String()}
+	}
+	for _, dataType := range b.opts.DataTypes {
+		if _, err := config.GetDataType(dataType); err != nil {
+			return fmt.Errorf("invalid data type: %w", err)
+		}
+	}
+	if _, err := os.Stat(b.cfg.GoatGeneratedFile()); err == nil {
+		return fmt.Errorf("project is already tracked, please run `goat clean` first")
+	}
+	return nil
+}
+
+// instrument restores the package in the work directory and tracks every line of its files
+// with the granularity and data type, it returns the number of tracking points
+func (b *BenchExecutor) instrument(workDir string, granularity config.Granularity, dataType config.DataType) (int, error) {
+	files, err := b.benchFiles()
+	if err != nil {
+		return 0, err
+	}
+	importPath := utils.GoatPackageImportPath(b.goModule, b.cfg.GoatPackagePath)
+	start := 1
+	for _, file := range files {
+		target := filepath.Join(workDir, file)
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read file %s: %w", file, err)
+		}
+		if err := os.WriteFile(target, content, 0644); err != nil {
+			return 0, fmt.Errorf("failed to restore file %s: %w", target, err)
+		}
+		change := &diff.FileChange{
+			Path:        file,
+			LineChanges: diff.LineChanges{{Start: 1, Lines: bytes.Count(content, []byte("\n")) + 1}},
+		}
+		tracker, err := tracking.NewIncrementalTrack(workDir, change,
+			increment.TrackImportPathPlaceHolder, increment.GetPackageInsertStmts(),
+			granularity, b.cfg.PrinterConfig())
+		if err != nil {
+			return 0, fmt.Errorf("failed to create incremental tracker: %w", err)
+		}
+		tracker.SetBudget(b.cfg.MaxTrackPointsPerFunc, b.cfg.MaxTrackPointsInLoops)
+		tracker.SetHoistLoops(b.cfg.GetLoopPlacementOf(file) == config.LoopPlacementHoist)
+		tracker.SetImplicitBranches(b.cfg.ImplicitBranches)
+		if _, err := tracker.Track(); err != nil {
+			return 0, fmt.Errorf("failed to track file %s: %w", file, err)
+		}
+		if tracker.Count() == 0 {
+			continue
+		}
+		replaceStmt := increment.IncreamentReplaceStmt
+		if dataType == config.DataTypeAverage {
+			replaceStmt = increment.IncreamentReplaceTimedStmt
+		}
+		count, newContent, err := replaceTrackIds(b.cfg.GoatPackageAlias, string(tracker.Content()), start, replaceStmt)
+		if err != nil || count != tracker.Count() {
+			return 0, fmt.Errorf("failed to replace statements in %s: expected=%d, actual=%d: %w",
+				file, tracker.Count(), count, err)
+		}
+		_, newContent, err = utils.Replace(newContent, fmt.Sprintf("%q", increment.TrackImportPathPlaceHolder),
+			increment.IncreamentReplaceImport(b.cfg.GoatPackageAlias, importPath))
+		if err != nil {
+			return 0, fmt.Errorf("failed to replace import in %s: %w", file, err)
+		}
+		if err := utils.FormatAndSave(target, []byte(newContent), b.cfg.PrinterConfig()); err != nil {
+			return 0, fmt.Errorf("failed to save tracked file %s: %w", target, err)
+		}
+		start += count
+	}
+	if start == 1 {
+		return 0, fmt.Errorf("no tracking points found in %s", b.opts.Package)
+	}
+
+	values := increment.NewValues(b.cfg)
+	values.DataType = dataType.Int()
+	values.Granularity = granularity.String()
+	// the benchmarks are built without tags, so the runtime is always compiled in
+	values.BuildTag = ""
+	ids := make([]int, 0, start-1)
+	for id := 1; id < start; id++ {
+		ids = append(ids, id)
+	}
+	values.AddTrackIds(ids)
+	generatedFile := filepath.Join(workDir, b.cfg.GoatGeneratedFile())
+	if err := values.Save(generatedFile); err != nil {
+		return 0, fmt.Errorf("failed to save generated file %s: %w", generatedFile, err)
+	}
+	return start - 1, nil
+}
+
+// runBenchmarks runs the benchmarks of the package directory and averages the results by benchmark
+func (b *BenchExecutor) runBenchmarks(dir string) (map[string]benchResult, error) {
+	args := []string{"test", "-run", "^$", "-bench", b.opts.Bench, "-benchmem",
+		"-count", strconv.Itoa(b.opts.Count)}
+	if b.opts.BenchTime != "" {
+		args = append(args, "-benchtime", b.opts.BenchTime)
+	}
+	args = append(args, ".")
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("go %s: %w\n%s", strings.Join(args, " "), err, output)
+	}
+	return parseBenchmarks(string(output)), nil
+}
+
+// parseBenchmarks parses the output of go test -bench -benchmem,
+// the results of a benchmark run several times are averaged
+func parseBenchmarks(output string) map[string]benchResult {
+	sums := make(map[string]benchResult)
+	runs := make(map[string]int)
+	for _, line := range strings.Split(output, "\n") {
+		matches := benchLineRegexp.FindStringSubmatch(strings.TrimSpace(line))
+		if matches == nil {
+			continue
+		}
+		name := matches[1]
+		sum := sums[name]
+		sum.NsPerOp += parseBenchValue(matches[2])
+		sum.BytesPerOp += parseBenchValue(matches[3])
+		sum.AllocsPerOp += parseBenchValue(matches[4])
+		sums[name] = sum
+		runs[name]++
+	}
+	results := make(map[string]benchResult, len(sums))
+	for name, sum := range sums {
+		n := float64(runs[name])
+		results[name] = benchResult{
+			NsPerOp:     sum.NsPerOp / n,
+			BytesPerOp:  sum.BytesPerOp / n,
+			AllocsPerOp: sum.AllocsPerOp / n,
+		}
+	}
+	return results
+}
+
+// parseBenchValue parses a value of a benchmark result, 0 if it is missing
+func parseBenchValue(s string) float64 {
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// compareBenchmarks compares the tracked results with the baseline, sorted by benchmark name
+func compareBenchmarks(baseline, tracked map[string]benchResult,
+	granularity, dataType string, trackPoints int) []benchComparison {
+	names := make([]string, 0, len(baseline))
+	for name := range baseline {
+		if _, ok := tracked[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	comparisons := make([]benchComparison, 0, len(names))
+	for _, name := range names {
+		base, result := baseline[name], tracked[name]
+		comparison := benchComparison{
+			Benchmark:        name,
+			Granularity:      granularity,
+			DataType:         dataType,
+			TrackPoints:      trackPoints,
+			Baseline:         base,
+			Tracked:          result,
+			BytesPerOpDelta:  result.BytesPerOp - base.BytesPerOp,
+			AllocsPerOpDelta: result.AllocsPerOp - base.AllocsPerOp,
+		}
+		if base.NsPerOp > 0 {
+			comparison.NsPerOpDelta = (result.NsPerOp - base.NsPerOp) / base.NsPerOp * 100
+		}
+		comparisons = append(comparisons, comparison)
+	}
+	return comparisons
+}
+
+// writeText writes the comparisons grouped by benchmark
+func (b *BenchExecutor) writeText(comparisons []benchComparison) error {
+	sort.SliceStable(comparisons, func(i, j int) bool {
+		return comparisons[i].Benchmark < comparisons[j].Benchmark
+	})
+	w := tabwriter.NewWriter(b.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "BENCHMARK\tGRANULARITY\tDATA TYPE\tPOINTS\tNS/OP\tDELTA\tB/OP\tDELTA\tALLOCS/OP\tDELTA\n")
+	for i, c := range comparisons {
+		if i == 0 || comparisons[i-1].Benchmark != c.Benchmark {
+			fmt.Fprintf(w, "%s\t-\t-\t0\t%.2f\t\t%.0f\t\t%.0f\t\n",
+				c.Benchmark, c.Baseline.NsPerOp, c.Baseline.BytesPerOp, c.Baseline.AllocsPerOp)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%.2f\t%+.1f%%\t%.0f\t%+.0f\t%.0f\t%+.0f\n",
+			c.Benchmark, c.Granularity, c.DataType, c.TrackPoints, c.Tracked.NsPerOp, c.NsPerOpDelta,
+			c.Tracked.BytesPerOp, c.BytesPerOpDelta, c.Tracked.AllocsPerOp, c.AllocsPerOpDelta)
+	}
+	return w.Flush()
+}
+
+// benchFiles returns the target files of the package to instrument, test files excluded
+func (b *BenchExecutor) benchFiles() ([]string, error) {
+	entries, err := os.ReadDir(b.opts.Package)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package %s: %w", b.opts.Package, err)
+	}
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		file := filepath.Join(b.opts.Package, entry.Name())
+		if entry.IsDir() || !b.cfg.IsTargetFile(file) {
+			continue
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// copyProject copies the regular files of the project to the destination, the .git directory excluded
+func copyProject(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(target, 0755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, content, 0644)
+	})
+}
@@ -0,0 +1,309 @@
+package goat
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/monshunter/goat/pkg/config"
+)
+
+func TestParseBenchmarks(t *testing.T) {
+	testCases := []struct {
+		name   string
+		output string
+		want   map[string]benchResult
+	}{
+		{
+			name: "with benchmem",
+			output: `goos: linux
+goarch: amd64
+pkg: example.com/app/calc
+BenchmarkSum-8   	 1000000	      1052 ns/op	     128 B/op	       2 allocs/op
+PASS
+ok  	example.com/app/calc	1.234s`,
+			want: map[string]benchResult{
+				"BenchmarkSum": {NsPerOp: 1052, BytesPerOp: 128, AllocsPerOp: 2},
+			},
+		},
+		{
+			name:   "without benchmem",
+			output: "BenchmarkSum-8   \t 1000000\t      12.5 ns/op\n",
+			want: map[string]benchResult{
+				"BenchmarkSum": {NsPerOp: 12.5},
+			},
+		},
+		{
+			name:   "without GOMAXPROCS suffix",
+			output: "BenchmarkSum   \t 1000000\t      10 ns/op\t       0 B/op\t       0 allocs/op\n",
+			want: map[string]benchResult{
+				"BenchmarkSum": {NsPerOp: 10},
+			},
+		},
+		{
+			name: "sub-benchmarks",
+			output: `BenchmarkEncode/small-16     	 2000000	       600 ns/op	      64 B/op	       1 allocs/op
+BenchmarkEncode/size=1024-16 	  100000	     12000 ns/op	    2048 B/op	       3 allocs/op`,
+			want: map[string]benchResult{
+				"BenchmarkEncode/small":     {NsPerOp: 600, BytesPerOp: 64, AllocsPerOp: 1},
+				"BenchmarkEncode/size=1024": {NsPerOp: 12000, BytesPerOp: 2048, AllocsPerOp: 3},
+			},
+		},
+		{
+			name: "averaged runs",
+			output: `BenchmarkSum-8   	 1000000	      100 ns/op	      16 B/op	       1 allocs/op
+BenchmarkSum-8   	 1000000	      200 ns/op	      32 B/op	       1 allocs/op
+BenchmarkSum-8   	 1000000	      300 ns/op	      48 B/op	       1 allocs/op`,
+			want: map[string]benchResult{
+				"BenchmarkSum": {NsPerOp: 200, BytesPerOp: 32, AllocsPerOp: 1},
+			},
+		},
+		{
+			name:   "no benchmarks",
+			output: "testing: warning: no tests to run\nPASS\nok  \texample.com/app/calc\t0.002s\n",
+			want:   map[string]benchResult{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := parseBenchmarks(tc.output); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parseBenchmarks() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCompareBenchmarks(t *testing.T) {
+	testCases := []struct {
+		name     string
+		baseline map[string]benchResult
+		tracked  map[string]benchResult
+		want     []benchComparison
+	}{
+		{
+			name:     "overhead",
+			baseline: map[string]benchResult{"BenchmarkSum": {NsPerOp: 100, BytesPerOp: 16, AllocsPerOp: 1}},
+			tracked:  map[string]benchResult{"BenchmarkSum": {NsPerOp: 125, BytesPerOp: 32, AllocsPerOp: 2}},
+			want: []benchComparison{{
+				Benchmark: "BenchmarkSum", Granularity: "line", DataType: "count", TrackPoints: 3,
+				Baseline:     benchResult{NsPerOp: 100, BytesPerOp: 16, AllocsPerOp: 1},
+				Tracked:      benchResult{NsPerOp: 125, BytesPerOp: 32, AllocsPerOp: 2},
+				NsPerOpDelta: 25, BytesPerOpDelta: 16, AllocsPerOpDelta: 1,
+			}},
+		},
+		{
+			name: "missing from the tracked run",
+			baseline: map[string]benchResult{
+				"BenchmarkB": {NsPerOp: 10},
+				"BenchmarkA": {NsPerOp: 10},
+			},
+			tracked: map[string]benchResult{"BenchmarkB": {NsPerOp: 5}},
+			want: []benchComparison{{
+				Benchmark: "BenchmarkB", Granularity: "line", DataType: "count", TrackPoints: 3,
+				Baseline: benchResult{NsPerOp: 10}, Tracked: benchResult{NsPerOp: 5}, NsPerOpDelta: -50,
+			}},
+		},
+		{
+			name:     "missing from the baseline",
+			baseline: map[string]benchResult{},
+			tracked:  map[string]benchResult{"BenchmarkA": {NsPerOp: 10}},
+			want:     []benchComparison{},
+		},
+		{
+			name:     "zero baseline time",
+			baseline: map[string]benchResult{"BenchmarkA": {}},
+			tracked:  map[string]benchResult{"BenchmarkA": {NsPerOp: 1}},
+			want: []benchComparison{{
+				Benchmark: "BenchmarkA", Granularity: "line", DataType: "count", TrackPoints: 3,
+				Tracked: benchResult{NsPerOp: 1},
+			}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := compareBenchmarks(tc.baseline, tc.tracked, "line", "count", 3)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("compareBenchmarks() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBenchExecutorRun(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the benchmarks in short mode")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/bench\n\ngo 1.21\n",
+		"calc/calc.go": `package calc
+
+// Sum returns the sum of the values
+func Sum(values []int) int {
+	total := 0
+	for _, v := range values {
+		if v > 0 {
+			total += v
+		}
+	}
+	return total
+}
+`,
+		"calc/calc_test.go": `package calc
+
+import "testing"
+
+func BenchmarkSum(b *testing.B) {
+	values := []int{1, -2, 3}
+	for i := 0; i < b.N; i++ {
+		Sum(values)
+	}
+}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	cfg := &config.Config{AppName: "bench", AppVersion: "1.0.0", DiffPrecision: 1}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Failed to validate config: %v", err)
+	}
+	var out bytes.Buffer
+	executor := NewBenchExecutor(cfg, BenchOptions{
+		Package:       "calc",
+		BenchTime:     "10x",
+		Granularities: []string{config.GranularityLineStr, config.GranularityFuncStr},
+		DataTypes:     []string{config.DataTypeCount.String(), config.DataTypeAverage.String()},
+		Format:        "json",
+	}, &out)
+	if err := executor.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	var comparisons []benchComparison
+	if err := json.Unmarshal(out.Bytes(), &comparisons); err != nil {
+		t.Fatalf("invalid JSON output %s: %v", out.String(), err)
+	}
+	// average is only benchmarked with the func granularity
+	var got []string
+	for _, c := range comparisons {
+		if c.Benchmark != "BenchmarkSum" || c.TrackPoints == 0 || c.Baseline.NsPerOp == 0 {
+			t.Errorf("comparison = %+v, want BenchmarkSum with tracking points", c)
+		}
+		got = append(got, c.Granularity+"/"+c.DataType)
+	}
+	if want := []string{"line/count", "func/count", "func/average"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("comparisons = %v, want %v", got, want)
+	}
+	// the project itself is left untouched
+	content, err := os.ReadFile(filepath.Join(dir, "calc", "calc.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != files["calc/calc.go"] || strings.Contains(string(content), "goat") {
+		t.Errorf("calc.go was modified:\n%s", content)
+	}
+	if _, err := os.Stat(filepath.Join(dir, cfg.GoatGeneratedFile())); !os.IsNotExist(err) {
+		t.Errorf("generated file found in the project: %v", err)
+	}
+}
+
+func TestBenchExecutorInstrument(t *testing.T) {
+	dir := t.TempDir()
+	source := `package calc
+
+// Sum returns the sum of the values
+func Sum(values []int) int {
+	total := 0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
+`
+	if err := os.MkdirAll(filepath.Join(dir, "calc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/bench\n\ngo 1.21\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "calc", "calc.go"), []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	cfg := &config.Config{AppName: "bench", AppVersion: "1.0.0", DiffPrecision: 1}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Failed to validate config: %v", err)
+	}
+	executor := NewBenchExecutor(cfg, BenchOptions{Package: "calc"}, &bytes.Buffer{})
+	if err := executor.validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+	workDir := t.TempDir()
+	if err := copyProject(".", workDir); err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name        string
+		granularity config.Granularity
+		dataType    config.DataType
+		want        int
+		contains    string
+		excludes    string
+	}{
+		{name: "line count", granularity: config.GranularityLine, dataType: config.DataTypeCount, want: 3,
+			contains: "goat.Track(goat.TRACK_ID_3)", excludes: "TrackTime"},
+		// the file is restored before being instrumented again
+		{name: "func average", granularity: config.GranularityFunc, dataType: config.DataTypeAverage, want: 1,
+			contains: "defer goat.TrackTime(goat.TRACK_ID_1)()", excludes: "goat.Track(goat.TRACK_ID_"},
+		{name: "func count", granularity: config.GranularityFunc, dataType: config.DataTypeCount, want: 1,
+			contains: "goat.Track(goat.TRACK_ID_1)", excludes: "TRACK_ID_2"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			count, err := executor.instrument(workDir, tc.granularity, tc.dataType)
+			if err != nil {
+				t.Fatalf("instrument() error = %v", err)
+			}
+			if count != tc.want {
+				t.Errorf("instrument() = %d, want %d", count, tc.want)
+			}
+			content, err := os.ReadFile(filepath.Join(workDir, "calc", "calc.go"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(content), tc.contains) || strings.Contains(string(content), tc.excludes) {
+				t.Errorf("instrumented file contains %q and not %q:\n%s", tc.contains, tc.excludes, content)
+			}
+			if _, err := os.Stat(filepath.Join(workDir, cfg.GoatGeneratedFile())); err != nil {
+				t.Errorf("generated file not saved: %v", err)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/monshunter/goat/pkg/log"
 
 	"github.com/monshunter/goat/pkg/config"
+	"github.com/monshunter/goat/pkg/tracking/increment"
 	"github.com/monshunter/goat/pkg/utils"
 )
 
@@ -48,7 +49,7 @@ func (c *CleanExecutor) Run() error {
 func (c *CleanExecutor) prepare() error {
 	log.Infof("Preparing files")
 	var err error
-	files, err := prepareFiles(c.cfg)
+	files, err := prepareCleanFiles(c.cfg)
 	if err != nil {
 		log.Errorf("Failed to prepare files: %v", err)
 		return err
@@ -211,6 +212,14 @@ func (c *CleanExecutor) prepareContent(filename string) (string, bool, error) {
 		return "", false, err
 	}
 	changed = changed || count > 0
+	// handle the wrapped condition operands
+	log.Debugf("Unwrapping condition operands for file: %s", filename)
+	count, newContent, err = increment.UnwrapTrackConds(newContent)
+	if err != nil {
+		log.Errorf("Failed to unwrap condition operands: %v", err)
+		return "", false, err
+	}
+	changed = changed || count > 0
 	// handle +goat:main
 	log.Debugf("Replacing +goat:main for file: %s", filename)
 	count, newContent, err = utils.ReplaceWithRegexp(config.TrackMainEntryEndRegexp, newContent, func(older string) (newer string) {
@@ -263,6 +272,7 @@ func (c *CleanExecutor) clean() error {
 	log.Infof("Total cleaned files: %d", len(c.files))
 	log.Debugf("Removing goat generated file: %s", c.cfg.GoatGeneratedFile())
 	os.Remove(c.cfg.GoatGeneratedFile())
+	os.Remove(c.cfg.GoatGeneratedStubFile())
 	// remove goat package if empty
 	log.Debugf("Checking if goat package is empty: %s", c.cfg.GoatPackagePath)
 	empty, err := utils.IsDirEmpty(c.cfg.GoatPackagePath)
@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"go/printer"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
+	"strings"
 
 	"github.com/monshunter/goat/pkg/log"
 
@@ -128,6 +130,80 @@ func getTotalTrackIdxs(fileTrackIdStartMap map[string]trackIdxInterval) []int {
 	return idxs[:slow+1]
 }
 
+// applyDataTypeOverrides sets the data types of the track idxs in files whose data type is overridden
+// fileTrackIdStartMap is the map of the file to the track idxs
+func applyDataTypeOverrides(cfg *config.Config, values *increment.Values, fileTrackIdStartMap map[string]trackIdxInterval) {
+	if len(cfg.Overrides) == 0 {
+		return
+	}
+	for path, interval := range fileTrackIdStartMap {
+		dataType := cfg.GetDataTypeOf(path)
+		if dataType == cfg.GetDataType() {
+			continue
+		}
+		for i := interval.start; i <= interval.end; i++ {
+			values.SetTrackIdDataType(i, dataType.Int())
+		}
+	}
+}
+
+// applyInitHookDataTypes sets the count data type to the track idxs of the init hooks in a file of the average data type,
+// whose track statements are not timed
+// content is the content of the file with the track idxs replaced
+func applyInitHookDataTypes(cfg *config.Config, values *increment.Values, file string, content string) {
+	if cfg.GetDataTypeOf(file) != config.DataTypeAverage {
+		return
+	}
+	for _, id := range increment.InitHookTrackIdsOf(file, content) {
+		values.SetTrackIdDataType(id, config.DataTypeCount.Int())
+	}
+}
+
+// replaceStmtOf returns the function numbering the track statements of the file,
+// the track points of the average data type are timed by a deferred call
+func replaceStmtOf(cfg *config.Config, file string) func(ident string, start int) func(older string) (newer string) {
+	if cfg.GetDataTypeOf(file) == config.DataTypeAverage {
+		return increment.IncreamentReplaceTimedStmt
+	}
+	return increment.IncreamentReplaceStmt
+}
+
+// replaceTrackIds numbers the track statements of the content from start with replaceStmt,
+// then the true and false track IDs of the wrapped condition operands, it returns the number of track IDs
+func replaceTrackIds(alias string, content string, start int,
+	replaceStmt func(ident string, start int) func(older string) (newer string)) (int, string, error) {
+	count, newContent, err := utils.ReplaceWithRegexp(increment.TrackStmtRegexp, content,
+		increment.IncreamentReplaceHookStmt(replaceStmt)(alias, start))
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to replace track statements: %w", err)
+	}
+	conds, newContent, err := utils.Replace(newContent, increment.TrackCondPlaceHolder,
+		increment.IncreamentReplaceCond(alias, start+count))
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to replace track conditions: %w", err)
+	}
+	return count + 2*conds, newContent, nil
+}
+
+// applyTrackAttrs sets the names and tags of the track idxs from the attributes of the +goat:generate comments
+// contents is the contents of the files with the track idxs replaced
+func applyTrackAttrs(values *increment.Values, contents ...string) {
+	for _, content := range contents {
+		for id, attrs := range increment.TrackAttrsOf(content) {
+			values.SetTrackIdAttrs(id, attrs)
+		}
+	}
+}
+
+// applyTrackPositions sets the source positions of the track idxs in the file
+// content is the content of the file with the track idxs replaced
+func applyTrackPositions(values *increment.Values, goModule string, file string, content string) {
+	pkgPath := path.Join(goModule, filepath.ToSlash(filepath.Dir(file)))
+	for id, position := range increment.TrackPositionsOf(filepath.ToSlash(file), pkgPath, content) {
+		values.SetTrackIdPosition(id, position)
+	}
+}
+
 // getMainPackageInfos gets the main package infos
 func getMainPackageInfos(cfg *config.Config, projectRoot string, goModule string) ([]maininfo.MainPackageInfo, error) {
 	return getMainPackageInfosWithConfig(cfg, projectRoot, goModule)
@@ -180,9 +256,8 @@ func handleGoatDelete(cfg *printer.Config, fileContents string, goatImportPath s
 		return 0, "", err
 	}
 	if count > 0 {
-		// handle if there is +goat:generate
-		indicates := config.TrackGenerateEndRegexp.FindAllStringIndex(content, -1)
-		if len(indicates) == 0 {
+		// handle if there is +goat:generate or +goat:user
+		if !hasGoatTracks(content) {
 			// delete the import path
 			bytes, err := utils.DeleteImport(cfg, goatImportPath, goatPackageAlias, "", []byte(content))
 			if err != nil {
@@ -199,7 +274,7 @@ func handleGoatDelete(cfg *printer.Config, fileContents string, goatImportPath s
 func handleGoatInsert(cfg *printer.Config, fileContents string, goatImportPath string, goatPackageAlias string) (int, string, error) {
 	count, content, err := utils.ReplaceWithRegexp(config.TrackInsertRegexp, fileContents,
 		func(older string) (newer string) {
-			return increment.GetPackageInsertDataString()
+			return increment.GetPackageInsertDataStringWithAttrs(markerAttrsOf(older, config.TrackInsertComment))
 		})
 	if err != nil {
 		log.Errorf("Failed to handle goat insert: %v", err)
@@ -217,14 +292,59 @@ func handleGoatInsert(cfg *printer.Config, fileContents string, goatImportPath s
 	return count, fileContents, nil
 }
 
-// resetGoatGenerate resets the goat generate
+// handleGoatUser adds the import path to the files with user track points
+func handleGoatUser(cfg *printer.Config, fileContents string, goatImportPath string, goatPackageAlias string) (int, string, error) {
+	count := len(config.TrackUserEndRegexp.FindAllStringIndex(fileContents, -1))
+	if count == 0 {
+		return 0, fileContents, nil
+	}
+	bytes, err := utils.AddImport(cfg, goatImportPath, goatPackageAlias, "", []byte(fileContents))
+	if err != nil {
+		log.Errorf("Failed to add import: %v", err)
+		return 0, "", err
+	}
+	return count, string(bytes), nil
+}
+
+// hasGoatTracks checks if the content has +goat:generate or +goat:user track points, or wrapped condition operands
+func hasGoatTracks(content string) bool {
+	return config.TrackGenerateEndRegexp.MatchString(content) || config.TrackUserEndRegexp.MatchString(content) ||
+		increment.TrackCondRegexp.MatchString(content)
+}
+
+// resetGoatGenerate resets the goat generate, keeping the synthesized default cases and init hooks
 func resetGoatGenerate(fileContents string) (int, string, error) {
 	return utils.ReplaceWithRegexp(config.TrackGenerateEndRegexp, fileContents,
 		func(older string) (newer string) {
-			return increment.GetPackageInsertDataString()
+			stmts := increment.GetPackageInsertStmtsWithAttrs(markerAttrsOf(older, config.TrackGenerateComment))
+			if increment.HasImplicitDefault(older) {
+				stmts = increment.InsertImplicitDefault(stmts)
+			}
+			if increment.HasInitHook(older) {
+				stmts = increment.InsertInitHook(stmts)
+			}
+			return strings.Join(stmts, "\n") + "\n"
+		})
+}
+
+// resetTrackConds resets the numbered calls wrapping the condition operands to the condition place holder
+func resetTrackConds(fileContents string) (int, string, error) {
+	return utils.ReplaceWithRegexp(increment.TrackCondRegexp, fileContents,
+		func(older string) (newer string) {
+			return increment.TrackCondPlaceHolder
 		})
 }
 
+// markerAttrsOf returns the attributes following the marker, invalid attributes are dropped
+func markerAttrsOf(block string, marker string) increment.Attrs {
+	attrs, err := increment.ParseMarkerAttrs(block, marker)
+	if err != nil {
+		log.Warningf("Dropping invalid attributes of %s: %v", marker, err)
+		return increment.Attrs{}
+	}
+	return attrs
+}
+
 // resetGoatMain resets the goat main
 func resetGoatMain(cfg *printer.Config, fileContents string, goatImportPath string, goatPackageAlias string) (int, string, error) {
 	count, content, err := utils.ReplaceWithRegexp(config.TrackMainEntryEndRegexp, fileContents,
@@ -236,9 +356,8 @@ func resetGoatMain(cfg *printer.Config, fileContents string, goatImportPath stri
 		return 0, "", err
 	}
 	if count > 0 {
-		// handle if there is +goat:generate
-		indicates := config.TrackGenerateEndRegexp.FindAllStringIndex(content, -1)
-		if len(indicates) == 0 {
+		// handle if there is +goat:generate or +goat:user
+		if !hasGoatTracks(content) {
 			// delete the import path
 			bytes, err := utils.DeleteImport(cfg, goatImportPath, goatPackageAlias, "", []byte(content))
 			if err != nil {
@@ -251,7 +370,19 @@ func resetGoatMain(cfg *printer.Config, fileContents string, goatImportPath stri
 	return count, content, nil
 }
 
-func prepareFiles(cfg *config.Config) (files []string, err error) {
+// prepareFiles returns the Go files selected by the include and exclude rules
+func prepareFiles(cfg *config.Config) ([]string, error) {
+	return walkGoFiles(cfg, cfg.IsTargetDir, cfg.IsTargetFile)
+}
+
+// prepareCleanFiles returns every project Go file regardless of the include and exclude rules,
+// so that files tracked before the rules were narrowed are still cleaned
+func prepareCleanFiles(cfg *config.Config) ([]string, error) {
+	return walkGoFiles(cfg, cfg.IsSourceDir, cfg.IsSourceFile)
+}
+
+// walkGoFiles walks the project and returns the files accepted by isFile in the directories accepted by isDir
+func walkGoFiles(cfg *config.Config, isDir, isFile func(string) bool) (files []string, err error) {
 	files = make([]string, 0)
 	err = filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
 		log.Debugf("Prepare files: %s", path)
@@ -260,7 +391,7 @@ func prepareFiles(cfg *config.Config) (files []string, err error) {
 			return err
 		}
 		if info.IsDir() {
-			if !cfg.IsTargetDir(path) {
+			if !isDir(path) {
 				// Log when skipping nested modules for user awareness
 				if cfg.SkipNestedModules && path != "." && cfg.IsBelongNestedModule(path) {
 					log.Warningf("Skipping nested module directory: %s", path)
@@ -269,11 +400,11 @@ func prepareFiles(cfg *config.Config) (files []string, err error) {
 			}
 			return nil
 		}
-		if !utils.IsGoFile(path) {
+		if !isFile(path) {
 			return nil
 		}
-		// skip goat_generated.go
-		if path == cfg.GoatGeneratedFile() {
+		// skip goat_generated.go and goat_generated_stub.go
+		if cfg.IsGoatGeneratedFile(path) {
 			return nil
 		}
 		// get relative path
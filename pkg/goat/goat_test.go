@@ -0,0 +1,72 @@
+package goat
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/monshunter/goat/pkg/config"
+)
+
+func TestPrepareFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{
+		"go.mod",
+		"main.go",
+		"pkg/app/app.go",
+		"pkg/app/app_test.go",
+		"pkg/legacy/old.go",
+		"internal/util/util.go",
+		"vendor/dep/dep.go",
+	}
+	for _, name := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("package x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	cfg := &config.Config{
+		AppName:       "app",
+		AppVersion:    "1.0.0",
+		DiffPrecision: 1,
+		Includes:      []string{"./pkg/**"},
+		Excludes:      []string{"pkg/legacy"},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Failed to validate config: %v", err)
+	}
+	testCases := []struct {
+		name    string
+		prepare func(*config.Config) ([]string, error)
+		want    []string
+	}{
+		{"track and patch follow the rules", prepareFiles, []string{"pkg/app/app.go"}},
+		// files tracked before the rules were narrowed are still cleaned
+		{"clean ignores the rules", prepareCleanFiles,
+			[]string{"internal/util/util.go", "main.go", "pkg/app/app.go", "pkg/legacy/old.go"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.prepare(cfg)
+			if err != nil {
+				t.Fatalf("prepare error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("prepare = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
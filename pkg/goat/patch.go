@@ -193,6 +193,20 @@ func (p *PatchExecutor) prepareContent(file string) (goatFile, error) {
 		return goatFile{}, err
 	}
 	updated = updated || count > 0
+	// handle the wrapped condition operands
+	count, content, err = resetTrackConds(content)
+	if err != nil {
+		log.Errorf("Failed to reset track conditions: %v", err)
+		return goatFile{}, err
+	}
+	updated = updated || count > 0
+	// handle // + goat:user
+	count, content, err = handleGoatUser(p.cfg.PrinterConfig(), content, p.goatImportPath, p.goatPackageAlias)
+	if err != nil {
+		log.Errorf("Failed to handle goat user: %v", err)
+		return goatFile{}, err
+	}
+	updated = updated || count > 0
 	// handle // + goat:main
 	isMainEntry := false
 	for _, mainPkgInfo := range p.mainPackageInfos {
@@ -230,8 +244,8 @@ func (p *PatchExecutor) replaceTracks() (int, error) {
 	slices.Sort(files)
 	for _, file := range files {
 		content := p.filesContents[file]
-		count, newContent, err := utils.Replace(content, increment.TrackStmtPlaceHolder,
-			increment.IncreamentReplaceStmt(p.cfg.GoatPackageAlias, start))
+		count, newContent, err := replaceTrackIds(p.cfg.GoatPackageAlias, content, start,
+			replaceStmtOf(p.cfg, file))
 		if err != nil {
 			log.Errorf("Failed to replace track stmt: %v", err)
 			return 0, err
@@ -279,10 +293,21 @@ func (p *PatchExecutor) apply() error {
 			log.Errorf("Failed to remove goat_generated.go: %v", err)
 			return err
 		}
+		err = values.Remove(p.cfg.GoatGeneratedStubFile())
+		if err != nil && !os.IsNotExist(err) {
+			log.Errorf("Failed to remove goat_generated_stub.go: %v", err)
+			return err
+		}
 		return nil
 	}
 
 	values.AddTrackIds(trackIdxs)
+	applyDataTypeOverrides(p.cfg, values, p.fileTrackIdStartMap)
+	for file, content := range p.filesContents {
+		applyTrackAttrs(values, content)
+		applyTrackPositions(values, p.goModule, file, content)
+		applyInitHookDataTypes(p.cfg, values, file, content)
+	}
 
 	if values.IsEmpty() {
 		log.Infof("No tracking points found, skip saving generated file")
@@ -295,6 +320,11 @@ func (p *PatchExecutor) apply() error {
 		log.Errorf("Failed to save goat_generated.go: %v", err)
 		return err
 	}
+	err = values.SaveStub(p.cfg.GoatGeneratedStubFile())
+	if err != nil {
+		log.Errorf("Failed to save goat_generated_stub.go: %v", err)
+		return err
+	}
 
 	// apply main entry
 	if err := applyMainEntries(p.cfg, p.goModule, p.mainPackageInfos, componentTrackIdxs); err != nil {
@@ -0,0 +1,195 @@
+package goat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/monshunter/goat/pkg/log"
+)
+
+// ReportOptions is the options of the report
+type ReportOptions struct {
+	// Addr is the address of the tracking service, e.g. "127.0.0.1:57005"
+	Addr string
+	// UnixSocket is the unix socket of the tracking service, used instead of Addr if set
+	UnixSocket string
+	// Token is the bearer token of the tracking service
+	Token string
+	// Sessions is the names of the sessions to report, all sessions if empty
+	Sessions []string
+	// Format is the output format, "text" or "json"
+	Format string
+	// Timeout is the timeout of each request
+	Timeout time.Duration
+}
+
+// reportItem is a track point hit in a session
+type reportItem struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Count uint64 `json:"count"`
+}
+
+// reportComponent is the coverage of a component in a session
+type reportComponent struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Metrics struct {
+		Total       int          `json:"total"`
+		Covered     int          `json:"covered"`
+		CoveredRate int          `json:"coveredRate"`
+		Items       []reportItem `json:"items"`
+	} `json:"metrics"`
+}
+
+// reportSession is a session returned by the tracking service
+type reportSession struct {
+	Name      string            `json:"name"`
+	Running   bool              `json:"running"`
+	StartedAt time.Time         `json:"startedAt"`
+	StoppedAt *time.Time        `json:"stoppedAt,omitempty"`
+	Results   []reportComponent `json:"results"`
+}
+
+// ReportExecutor is the executor for the report
+type ReportExecutor struct {
+	opts    ReportOptions
+	baseURL string
+	client  *http.Client
+	out     io.Writer
+}
+
+// NewReportExecutor creates a new report executor writing to out
+func NewReportExecutor(opts ReportOptions, out io.Writer) *ReportExecutor {
+	executor := &ReportExecutor{
+		opts:   opts,
+		out:    out,
+		client: &http.Client{Timeout: opts.Timeout},
+	}
+	if opts.UnixSocket != "" {
+		executor.baseURL = "http://goat"
+		executor.client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", opts.UnixSocket)
+			},
+		}
+	} else {
+		executor.baseURL = strings.TrimSuffix(opts.Addr, "/")
+		if !strings.Contains(executor.baseURL, "://") {
+			executor.baseURL = "http://" + executor.baseURL
+		}
+	}
+	return executor
+}
+
+// Run runs the report executor
+func (r *ReportExecutor) Run() error {
+	if r.opts.Format != "text" && r.opts.Format != "json" {
+		return fmt.Errorf("invalid report format %s, expected text or json", r.opts.Format)
+	}
+	names := r.opts.Sessions
+	if len(names) == 0 {
+		if err := r.get("/session/", &names); err != nil {
+			log.Errorf("Failed to list sessions: %v", err)
+			return err
+		}
+		if len(names) == 0 {
+			return fmt.Errorf("no session found on %s", r.baseURL)
+		}
+	}
+	sessions := make([]reportSession, 0, len(names))
+	for _, name := range names {
+		var session reportSession
+		if err := r.get("/session/"+url.PathEscape(name), &session); err != nil {
+			log.Errorf("Failed to get session %s: %v", name, err)
+			return err
+		}
+		sessions = append(sessions, session)
+	}
+	if r.opts.Format == "json" {
+		encoder := json.NewEncoder(r.out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(sessions)
+	}
+	return r.writeText(sessions)
+}
+
+// get gets the path of the tracking service and decodes the JSON response into v
+func (r *ReportExecutor) get(path string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, r.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	if r.opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.opts.Token)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to request %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to request %s: %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeText writes the coverage of each session, followed by the sessions hitting each track point
+func (r *ReportExecutor) writeText(sessions []reportSession) error {
+	w := tabwriter.NewWriter(r.out, 0, 0, 2, ' ', 0)
+	// track point -> sessions hitting it
+	attribution := make(map[reportItem][]string)
+	for _, session := range sessions {
+		state := "running"
+		if session.StoppedAt != nil {
+			state = fmt.Sprintf("stopped, %s", session.StoppedAt.Sub(session.StartedAt).Round(time.Millisecond))
+		}
+		fmt.Fprintf(w, "Session %s (%s)\n", session.Name, state)
+		fmt.Fprintf(w, "COMPONENT\tCOVERED\tTOTAL\tRATE\n")
+		for _, component := range session.Results {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d%%\n", component.Name,
+				component.Metrics.Covered, component.Metrics.Total, component.Metrics.CoveredRate)
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "COMPONENT\tID\tNAME\tHITS\n")
+		for _, component := range session.Results {
+			for _, item := range component.Metrics.Items {
+				fmt.Fprintf(w, "%s\t%d\t%s\t%d\n", component.Name, item.ID, item.Name, item.Count)
+				// a track point shared by components is attributed once
+				point := reportItem{ID: item.ID, Name: item.Name}
+				if !slices.Contains(attribution[point], session.Name) {
+					attribution[point] = append(attribution[point], session.Name)
+				}
+			}
+		}
+		fmt.Fprintln(w)
+	}
+	if len(sessions) > 1 && len(attribution) > 0 {
+		points := make([]reportItem, 0, len(attribution))
+		for point := range attribution {
+			points = append(points, point)
+		}
+		sort.Slice(points, func(i, j int) bool {
+			return points[i].ID < points[j].ID
+		})
+		fmt.Fprintf(w, "ID\tNAME\tSESSIONS\n")
+		for _, point := range points {
+			fmt.Fprintf(w, "%d\t%s\t%s\n", point.ID, point.Name, strings.Join(attribution[point], ","))
+		}
+	}
+	return w.Flush()
+}
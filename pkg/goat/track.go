@@ -56,6 +56,15 @@ func (t *TrackExecutor) Run() error {
 	}
 
 	log.Infof("Replaced %d tracking points", count)
+	if hoisted := t.sumTrackers(tracking.Tracker.Hoisted); hoisted > 0 {
+		log.Infof("Hoisted %d tracking points out of loop bodies, tagged %q", hoisted, config.HoistedTag)
+	}
+	if implicit := t.sumTrackers(tracking.Tracker.ImplicitBranches); implicit > 0 {
+		log.Infof("Added %d tracking points of implicit branches, tagged %q", implicit, config.ImplicitBranchTag)
+	}
+	if hooks := t.sumTrackers(tracking.Tracker.InitHooks); hooks > 0 {
+		log.Infof("Added %d init hooks tracking package-level initializers", hooks)
+	}
 
 	componentTrackIdxs := getComponentTrackIdxs(t.fileTrackIdStartMap, t.mainPackageInfos)
 
@@ -65,6 +74,12 @@ func (t *TrackExecutor) Run() error {
 	}
 
 	values.AddTrackIds(getTotalTrackIdxs(t.fileTrackIdStartMap))
+	applyDataTypeOverrides(t.cfg, values, t.fileTrackIdStartMap)
+	for i, tracker := range t.trackers {
+		applyTrackAttrs(values, string(tracker.Content()))
+		applyTrackPositions(values, t.goModule, t.changes[i].Path, string(tracker.Content()))
+		applyInitHookDataTypes(t.cfg, values, t.changes[i].Path, string(tracker.Content()))
+	}
 
 	if values.IsEmpty() {
 		log.Infof("No tracking points found, skip saving generated file")
@@ -75,6 +90,9 @@ func (t *TrackExecutor) Run() error {
 	if err = values.Save(t.cfg.GoatGeneratedFile()); err != nil {
 		return fmt.Errorf("failed to save generated file %s: %w", t.cfg.GoatGeneratedFile(), err)
 	}
+	if err = values.SaveStub(t.cfg.GoatGeneratedStubFile()); err != nil {
+		return fmt.Errorf("failed to save generated stub file %s: %w", t.cfg.GoatGeneratedStubFile(), err)
+	}
 
 	log.Infof("Saving tracking points to %d files", t.replacedFiles)
 	if err := t.saveTracks(); err != nil {
@@ -185,13 +203,16 @@ func (t *TrackExecutor) initTracksParallel() error {
 
 // handleDiffChange handles the diff change
 func (t *TrackExecutor) handleDiffChange(change *diff.FileChange) (tracking.Tracker, error) {
-	granularity := t.cfg.GetGranularity()
+	granularity := t.cfg.GetGranularityOf(change.Path)
 	tracker, err := tracking.NewIncrementalTrack(".", change,
 		increment.TrackImportPathPlaceHolder, increment.GetPackageInsertStmts(),
 		granularity, t.cfg.PrinterConfig())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create incremental tracker: %w", err)
 	}
+	tracker.SetBudget(t.cfg.MaxTrackPointsPerFunc, t.cfg.MaxTrackPointsInLoops)
+	tracker.SetHoistLoops(t.cfg.GetLoopPlacementOf(change.Path) == config.LoopPlacementHoist)
+	tracker.SetImplicitBranches(t.cfg.ImplicitBranches)
 	_, err = tracker.Track()
 	if err != nil {
 		return nil, fmt.Errorf("failed to track file: %w", err)
@@ -200,14 +221,23 @@ func (t *TrackExecutor) handleDiffChange(change *diff.FileChange) (tracking.Trac
 	return tracker, nil
 }
 
+// sumTrackers returns the sum of the counts selected from the trackers, such as tracking.Tracker.Hoisted
+func (t *TrackExecutor) sumTrackers(count func(tracking.Tracker) int) int {
+	sum := 0
+	for _, tracker := range t.trackers {
+		sum += count(tracker)
+	}
+	return sum
+}
+
 // replaceTracks replaces the tracks
 func (t *TrackExecutor) replaceTracks() (int, error) {
 	log.Infof("Replacing tracks")
 	start := 1
 	importPath := utils.GoatPackageImportPath(t.goModule, t.cfg.GoatPackagePath)
 	for i, tracker := range t.trackers {
-		count, newContent, err := utils.Replace(string(tracker.Content()), increment.TrackStmtPlaceHolder,
-			increment.IncreamentReplaceStmt(t.cfg.GoatPackageAlias, start))
+		count, newContent, err := replaceTrackIds(t.cfg.GoatPackageAlias, string(tracker.Content()), start,
+			replaceStmtOf(t.cfg, t.changes[i].Path))
 		if err != nil || count != tracker.Count() {
 			return 0, fmt.Errorf("failed to replace statements in %s: expected=%d, actual=%d: %w",
 				tracker.Target(), tracker.Count(), count, err)
@@ -0,0 +1,110 @@
+package tracking
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"github.com/monshunter/goat/pkg/config"
+)
+
+// SetImplicitBranches sets whether the implicit branches of the changed conditions are tracked,
+// right after an if without else whose branches don't fall through,
+// and in a default case synthesized for a switch without one
+func (t *IncrementalTrack) SetImplicitBranches(implicit bool) {
+	t.implicitBranches = implicit
+}
+
+// ImplicitBranches returns the number of tracking points of implicit branches
+func (t *IncrementalTrack) ImplicitBranches() int {
+	return t.implicit
+}
+
+// markImplicitElse marks a tracking point right after a changed if whose else-if chain has no final else,
+// every branch of the chain must leave the code after it, so the point is only reached when the conditions are false
+func (t *IncrementalTrack) markImplicitElse(fset *token.FileSet, n *ast.IfStmt) {
+	if !t.implicitBranches {
+		return
+	}
+	last := n
+	for {
+		if !isTerminating(last.Body) {
+			return
+		}
+		next, ok := last.Else.(*ast.IfStmt)
+		if !ok {
+			break
+		}
+		last = next
+	}
+	if last.Else != nil {
+		return
+	}
+	// the if must end its line, so the next line holds the code following it
+	end := fset.Position(n.End())
+	rest := strings.TrimSpace(t.source[end.Line-1][end.Column-1:])
+	if rest != "" && !strings.HasPrefix(rest, "//") {
+		return
+	}
+	t.markImplicit(end.Line + 1)
+}
+
+// isTerminating checks if the block ends with a return, break, continue, goto or a call to panic
+func isTerminating(body *ast.BlockStmt) bool {
+	if body == nil || len(body.List) == 0 {
+		return false
+	}
+	switch stmt := body.List[len(body.List)-1].(type) {
+	case *ast.ReturnStmt:
+		return true
+	case *ast.BranchStmt:
+		return stmt.Tok != token.FALLTHROUGH
+	case *ast.ExprStmt:
+		call, ok := stmt.X.(*ast.CallExpr)
+		if !ok {
+			return false
+		}
+		ident, ok := call.Fun.(*ast.Ident)
+		return ok && ident.Name == "panic"
+	}
+	return false
+}
+
+// markImplicitDefault marks a tracking point in a default case synthesized
+// before the closing brace of a changed switch or type switch without one
+func (t *IncrementalTrack) markImplicitDefault(fset *token.FileSet, body *ast.BlockStmt) {
+	if !t.implicitBranches || body == nil {
+		return
+	}
+	for _, stmt := range body.List {
+		if clause, ok := stmt.(*ast.CaseClause); ok && clause.List == nil {
+			return
+		}
+	}
+	// the closing brace must start its line, so the default case follows the last case
+	rbrace := fset.Position(body.Rbrace)
+	if strings.TrimSpace(t.source[rbrace.Line-1][:rbrace.Column-1]) != "" {
+		return
+	}
+	line, ok := t.markImplicit(rbrace.Line)
+	if ok {
+		t.implicitDefaults[line] = struct{}{}
+	}
+}
+
+// markImplicit marks a tracking point of an implicit branch before the line, tagged as such,
+// it returns the line of the tracking point and false if the line is already tracked
+func (t *IncrementalTrack) markImplicit(line int) (int, bool) {
+	if line > len(t.source) || t.granularityOf(line).IsFunc() {
+		return line, false
+	}
+	line, ok := t.markInsert(line)
+	if !ok {
+		return line, false
+	}
+	attrs := t.insertedAttrs[line]
+	attrs.Tags = append(attrs.Tags, config.ImplicitBranchTag)
+	t.insertedAttrs[line] = attrs
+	t.implicit++
+	return line, true
+}
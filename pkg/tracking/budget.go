@@ -0,0 +1,138 @@
+package tracking
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/monshunter/goat/pkg/config"
+	"github.com/monshunter/goat/pkg/log"
+)
+
+// trackBudget is the maximum number of tracking points of a function, 0 means unlimited
+type trackBudget struct {
+	maxPointsPerFunc int
+	maxPointsInLoops int
+}
+
+// isSet checks if any limit of the budget is set
+func (b trackBudget) isSet() bool {
+	return b.maxPointsPerFunc > 0 || b.maxPointsInLoops > 0
+}
+
+// funcPoints is the number of tracking points of a function
+type funcPoints struct {
+	total   int
+	inLoops int
+}
+
+// exceeds checks if the points of a function exceed the budget
+func (b trackBudget) exceeds(points funcPoints) bool {
+	return (b.maxPointsPerFunc > 0 && points.total > b.maxPointsPerFunc) ||
+		(b.maxPointsInLoops > 0 && points.inLoops > b.maxPointsInLoops)
+}
+
+// SetBudget sets the maximum number of tracking points of a function and of its loop bodies,
+// a function exceeding them falls back to a coarser granularity, 0 means unlimited
+func (t *IncrementalTrack) SetBudget(maxPointsPerFunc, maxPointsInLoops int) {
+	t.budget = trackBudget{maxPointsPerFunc: maxPointsPerFunc, maxPointsInLoops: maxPointsInLoops}
+}
+
+// granularityOf returns the granularity of the function containing the line
+func (t *IncrementalTrack) granularityOf(line int) config.Granularity {
+	return t.granularityOfFunc(t.functionScopes.Search(line))
+}
+
+// granularityOfFunc returns the granularity of the function, coarsened if it exceeded the budget
+func (t *IncrementalTrack) granularityOfFunc(funcIdx int) config.Granularity {
+	if granularity, ok := t.funcGranularities[funcIdx]; ok {
+		return granularity
+	}
+	return t.granularity
+}
+
+// pointsOfFuncs counts the marked tracking points of each function
+func (t *IncrementalTrack) pointsOfFuncs() map[int]funcPoints {
+	points := make(map[int]funcPoints)
+	for _, positions := range []InsertPositions{t.insertedPositions, t.singleLineInsertedPositions} {
+		for _, position := range positions {
+			idx := t.functionScopes.Search(position.line)
+			p := points[idx]
+			p.total++
+			if t.isInLoop(idx, position.line) {
+				p.inLoops++
+			}
+			points[idx] = p
+		}
+	}
+	// each condition operand has a true and a false tracking point
+	for _, operand := range t.conditions {
+		idx := t.functionScopes.Search(operand.start.Line)
+		p := points[idx]
+		p.total += 2
+		if t.isInLoop(idx, operand.start.Line) {
+			p.inLoops += 2
+		}
+		points[idx] = p
+	}
+	return points
+}
+
+// isInLoop checks if the line is in a loop body of the function,
+// the loops around a function literal do not count for the function literal
+func (t *IncrementalTrack) isInLoop(funcIdx int, line int) bool {
+	for _, loop := range t.loopScopes {
+		if loop.Contains(line) && loop.StartLine >= t.functionScopes[funcIdx].StartLine {
+			return true
+		}
+	}
+	return false
+}
+
+// enforceBudget coarsens the granularity of the functions exceeding the budget,
+// and reports whether the tracking points need to be marked again
+func (t *IncrementalTrack) enforceBudget() (bool, error) {
+	if !t.budget.isSet() {
+		return false, nil
+	}
+	coarsened := false
+	pointsOfFuncs := t.pointsOfFuncs()
+	for _, idx := range slices.Sorted(maps.Keys(pointsOfFuncs)) {
+		points := pointsOfFuncs[idx]
+		granularity := t.granularityOfFunc(idx)
+		if idx == 0 || granularity.IsFunc() || !t.budget.exceeds(points) {
+			continue
+		}
+		if granularity.IsLine() && t.trackScopes == nil {
+			trackScopes, err := TrackScopesOfAST(t.fileName, t.content)
+			if err != nil {
+				return false, fmt.Errorf("failed to analyze tracking scopes in %s: %w", t.fileName, err)
+			}
+			t.trackScopes = trackScopes
+		}
+		coarser := granularity.Coarser()
+		log.Infof("Function at %s:%d has %d tracking points (%d in loops) over the budget, falling back to %s granularity",
+			t.fileName, t.functionScopes[idx].StartLine, points.total, points.inLoops, coarser)
+		t.funcGranularities[idx] = coarser
+		coarsened = true
+	}
+	return coarsened, nil
+}
+
+// resetMarks drops the marked tracking points before marking them again
+func (t *IncrementalTrack) resetMarks() {
+	t.count = 0
+	t.hoisted = 0
+	t.implicit = 0
+	t.insertedPositions.Reset()
+	t.singleLineInsertedPositions.Reset()
+	clear(t.visitedInsertedPositions)
+	clear(t.visitedTrackScopes)
+	clear(t.patchScopes)
+	clear(t.insertedAttrs)
+	t.conditions = t.conditions[:0]
+	clear(t.visitedConditions)
+	clear(t.untypedConditions)
+	clear(t.implicitDefaults)
+	clear(t.initHooks)
+}
@@ -0,0 +1,238 @@
+package tracking
+
+import (
+	"cmp"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/monshunter/goat/pkg/log"
+	increament "github.com/monshunter/goat/pkg/tracking/increment"
+)
+
+// conditionImporter imports the packages of the conditions from source, it is shared by the trackers
+// so that each package is imported once, and guarded by its mutex as the trackers run concurrently
+var conditionImporter = &lockedImporter{importer: importer.ForCompiler(token.NewFileSet(), "source", nil).(types.ImporterFrom)}
+
+// lockedImporter is an importer safe for concurrent use
+type lockedImporter struct {
+	mutex    sync.Mutex
+	importer types.ImporterFrom
+}
+
+// Import imports the package of the path
+func (l *lockedImporter) Import(path string) (*types.Package, error) {
+	return l.ImportFrom(path, "", 0)
+}
+
+// ImportFrom imports the package of the path relative to the directory
+func (l *lockedImporter) ImportFrom(path, dir string, mode types.ImportMode) (*types.Package, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.importer.ImportFrom(path, dir, mode)
+}
+
+// conditionOperand is an operand of a && or || condition, wrapped to track whether it is true or false
+type conditionOperand struct {
+	start token.Position
+	end   token.Position
+}
+
+// conditionEdit is a text inserted in a source line to wrap a condition operand
+type conditionEdit struct {
+	line   int
+	column int
+	text   string
+}
+
+// isShortCircuit checks if the expression is a && or || expression, parentheses removed
+func isShortCircuit(expr ast.Expr) bool {
+	for {
+		paren, ok := expr.(*ast.ParenExpr)
+		if !ok {
+			break
+		}
+		expr = paren.X
+	}
+	binary, ok := expr.(*ast.BinaryExpr)
+	return ok && (binary.Op == token.LAND || binary.Op == token.LOR)
+}
+
+// conditionOperandsOf returns the operands of the && and || expressions of the condition,
+// nested through parentheses, nil if the condition is not a && or || expression
+func conditionOperandsOf(cond ast.Expr) []ast.Expr {
+	if cond == nil || !isShortCircuit(cond) {
+		return nil
+	}
+	var operands []ast.Expr
+	var walk func(expr ast.Expr)
+	walk = func(expr ast.Expr) {
+		switch e := expr.(type) {
+		case *ast.ParenExpr:
+			if isShortCircuit(e.X) {
+				walk(e.X)
+				return
+			}
+		case *ast.BinaryExpr:
+			if e.Op == token.LAND || e.Op == token.LOR {
+				walk(e.X)
+				walk(e.Y)
+				return
+			}
+		}
+		operands = append(operands, expr)
+	}
+	walk(cond)
+	return operands
+}
+
+// conditionTypesOf type checks the package of the file, with the other files of its directory,
+// type errors such as unresolved imports are tolerated, so the types of some expressions are invalid
+func conditionTypesOf(fset *token.FileSet, f *ast.File, fileName string) *types.Info {
+	files := []*ast.File{f}
+	dir := filepath.Dir(fileName)
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || name == filepath.Base(fileName) || !strings.HasSuffix(name, ".go") ||
+			(strings.HasSuffix(name, "_test.go") && !strings.HasSuffix(fileName, "_test.go")) {
+			continue
+		}
+		if match, err := build.Default.MatchFile(dir, name); err != nil || !match {
+			continue
+		}
+		sibling, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.SkipObjectResolution)
+		if err != nil || sibling.Name.Name != f.Name.Name {
+			continue
+		}
+		files = append(files, sibling)
+	}
+	info := &types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	conf := types.Config{Importer: conditionImporter, FakeImportC: true, Error: func(error) {}}
+	_, _ = conf.Check(f.Name.Name, fset, files, info)
+	return info
+}
+
+// isWrappable checks if the operands of the condition can be wrapped, known is false if the type of
+// the condition is unknown, as when its package doesn't type check. TrackCond returns the type of its operand,
+// so the operands of a condition of a named boolean type are wrapped only if none of them is an untyped boolean,
+// which TrackCond would turn into a bool that doesn't mix with the named type
+func (t *IncrementalTrack) isWrappable(cond ast.Expr) (ok bool, known bool) {
+	if t.conditionTypes == nil {
+		return false, false
+	}
+	typ := t.conditionTypes.Types[cond].Type
+	if typ == nil || typ == types.Typ[types.Invalid] {
+		return false, false
+	}
+	basic, ok := typ.Underlying().(*types.Basic)
+	if !ok || basic.Info()&types.IsBoolean == 0 {
+		return false, true
+	}
+	if typ == basic {
+		return true, true
+	}
+	for _, operand := range conditionOperandsOf(cond) {
+		if isUntypedBool(operand) {
+			return false, true
+		}
+	}
+	return true, true
+}
+
+// isUntypedBool checks if the expression is an untyped boolean, a comparison or a boolean constant
+func isUntypedBool(expr ast.Expr) bool {
+	switch e := expr.(type) {
+	case *ast.ParenExpr:
+		return isUntypedBool(e.X)
+	case *ast.UnaryExpr:
+		return e.Op == token.NOT && isUntypedBool(e.X)
+	case *ast.BinaryExpr:
+		switch e.Op {
+		case token.EQL, token.NEQ, token.LSS, token.GTR, token.LEQ, token.GEQ:
+			return true
+		case token.LAND, token.LOR:
+			return isUntypedBool(e.X) && isUntypedBool(e.Y)
+		}
+	case *ast.Ident:
+		return e.Name == "true" || e.Name == "false"
+	}
+	return false
+}
+
+// markConditions marks the operands of a changed && or || condition to be wrapped,
+// in the functions of the condition granularity, each operand takes a true and a false tracking point
+func (t *IncrementalTrack) markConditions(fset *token.FileSet, cond ast.Expr) {
+	if cond == nil || !t.granularityOf(fset.Position(cond.Pos()).Line).IsCondition() || !isShortCircuit(cond) {
+		return
+	}
+	ok, known := t.isWrappable(cond)
+	if !known {
+		t.untypedConditions[fset.Position(cond.Pos()).Line] = struct{}{}
+	}
+	if !ok {
+		return
+	}
+	for _, operand := range conditionOperandsOf(cond) {
+		start, end := fset.Position(operand.Pos()), fset.Position(operand.End())
+		if t.ignores[start.Line] {
+			continue
+		}
+		key := InsertPosition{line: start.Line, column: start.Column}
+		if _, ok := t.visitedConditions[key]; ok {
+			continue
+		}
+		t.visitedConditions[key] = struct{}{}
+		t.conditions = append(t.conditions, conditionOperand{start: start, end: end})
+		t.count += 2
+	}
+}
+
+// warnUntypedConditions warns of the changed conditions whose operands are not wrapped as their types are unknown
+func (t *IncrementalTrack) warnUntypedConditions() {
+	if len(t.untypedConditions) == 0 {
+		return
+	}
+	lines := slices.Sorted(maps.Keys(t.untypedConditions))
+	log.Warningf("Skipped the operands of %d conditions in %s at lines %v, their types are unknown as the package doesn't type check",
+		len(lines), t.fileName, lines)
+}
+
+// wrapConditions returns the source lines with the marked condition operands wrapped
+// in the condition place holder, the single line inserted positions after them are shifted accordingly
+func (t *IncrementalTrack) wrapConditions(sources []string) []string {
+	if len(t.conditions) == 0 {
+		return sources
+	}
+	edits := make([]conditionEdit, 0, 2*len(t.conditions))
+	for _, operand := range t.conditions {
+		edits = append(edits,
+			conditionEdit{line: operand.start.Line, column: operand.start.Column, text: increament.TrackCondPlaceHolder + " "},
+			conditionEdit{line: operand.end.Line, column: operand.end.Column, text: ")"})
+	}
+	// apply the edits from the end, so the columns of the remaining edits stay valid
+	slices.SortFunc(edits, func(a, b conditionEdit) int {
+		return cmp.Or(cmp.Compare(b.line, a.line), cmp.Compare(b.column, a.column))
+	})
+	wrapped := slices.Clone(sources)
+	for _, edit := range edits {
+		source := wrapped[edit.line-1]
+		wrapped[edit.line-1] = source[:edit.column-1] + edit.text + source[edit.column-1:]
+		for i := range t.singleLineInsertedPositions {
+			position := &t.singleLineInsertedPositions[i]
+			if position.line == edit.line && position.column > edit.column {
+				position.column += len(edit.text)
+			}
+		}
+	}
+	return wrapped
+}
@@ -0,0 +1,150 @@
+package tracking
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"github.com/monshunter/goat/pkg/config"
+	"github.com/monshunter/goat/pkg/log"
+	increament "github.com/monshunter/goat/pkg/tracking/increment"
+)
+
+// ignoredScope is a range of lines excluded from tracking by a directive
+type ignoredScope struct {
+	BlockScope
+	// directive is the directive excluding the lines
+	directive string
+	// name is the name of the ignored function, if any
+	name string
+}
+
+// ignoreDirectives is the result of parsing the //goat:ignore directives of a file
+type ignoreDirectives struct {
+	// file is true if the whole file is excluded by //goat:ignore-file
+	file bool
+	// scopes is the list of ignored line ranges, [StartLine, EndLine] inclusive
+	scopes []ignoredScope
+}
+
+// directiveOf returns the directive of the comment, or "" if the comment is not a goat directive.
+// A directive may be followed by a free-form reason, e.g. "//goat:ignore hot loop"
+func directiveOf(comment *ast.Comment) string {
+	if !strings.HasPrefix(comment.Text, "//goat:") {
+		return ""
+	}
+	fields := strings.Fields(comment.Text)
+	switch fields[0] {
+	case config.IgnoreDirective, config.IgnoreStartDirective,
+		config.IgnoreEndDirective, config.IgnoreFileDirective, config.NameDirective:
+		return fields[0]
+	}
+	return ""
+}
+
+// hasDirective checks if the comment group contains the directive
+func hasDirective(group *ast.CommentGroup, directive string) bool {
+	if group == nil {
+		return false
+	}
+	for _, comment := range group.List {
+		if directiveOf(comment) == directive {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoreDirectivesOfAST parses the //goat:ignore directives of the file
+func ignoreDirectivesOfAST(fset *token.FileSet, f *ast.File) ignoreDirectives {
+	var result ignoreDirectives
+
+	// the file directive must be placed before the first declaration
+	headerEnd := f.End()
+	if len(f.Decls) > 0 {
+		headerEnd = f.Decls[0].Pos()
+	}
+
+	startLine := 0
+	for _, group := range f.Comments {
+		for _, comment := range group.List {
+			line := fset.Position(comment.Pos()).Line
+			switch directiveOf(comment) {
+			case config.IgnoreFileDirective:
+				if comment.Pos() < headerEnd {
+					result.file = true
+				}
+			case config.IgnoreStartDirective:
+				if startLine == 0 {
+					startLine = line
+				}
+			case config.IgnoreEndDirective:
+				if startLine > 0 {
+					result.scopes = append(result.scopes, ignoredScope{
+						BlockScope: BlockScope{StartLine: startLine, EndLine: line},
+						directive:  config.IgnoreStartDirective,
+					})
+					startLine = 0
+				}
+			}
+		}
+	}
+	// an unterminated block is ignored to the end of the file
+	if startLine > 0 {
+		result.scopes = append(result.scopes, ignoredScope{
+			BlockScope: BlockScope{StartLine: startLine, EndLine: fset.Position(f.End()).Line},
+			directive:  config.IgnoreStartDirective,
+		})
+	}
+
+	for _, decl := range f.Decls {
+		funcDecl, ok := decl.(*ast.FuncDecl)
+		if !ok || funcDecl.Body == nil || !hasDirective(funcDecl.Doc, config.IgnoreDirective) {
+			continue
+		}
+		result.scopes = append(result.scopes, ignoredScope{
+			BlockScope: BlockScope{
+				StartLine: fset.Position(funcDecl.Pos()).Line,
+				EndLine:   fset.Position(funcDecl.End()).Line,
+			},
+			directive: config.IgnoreDirective,
+			name:      funcDecl.Name.Name,
+		})
+	}
+	return result
+}
+
+// nameDirectivesOfAST parses the //goat:name directives of the file,
+// the result is the map of the directive line to the attributes
+func nameDirectivesOfAST(fset *token.FileSet, f *ast.File) map[int]increament.Attrs {
+	result := make(map[int]increament.Attrs)
+	for _, group := range f.Comments {
+		for _, comment := range group.List {
+			if directiveOf(comment) != config.NameDirective {
+				continue
+			}
+			position := fset.Position(comment.Pos())
+			attrs, err := increament.ParseAttrs(strings.TrimPrefix(comment.Text, config.NameDirective))
+			if err != nil {
+				log.Warningf("Invalid %s directive at line %d: %v", config.NameDirective, position.Line, err)
+				continue
+			}
+			if !attrs.IsEmpty() {
+				result[position.Line] = attrs
+			}
+		}
+	}
+	return result
+}
+
+// lines returns the ignored lines, indexed by 1-based line number
+func (d ignoreDirectives) lines(length int) []bool {
+	// +1 for the line number, because the line number is 1-based
+	ignores := make([]bool, length+1)
+	for _, scope := range d.scopes {
+		for i := scope.StartLine; i <= scope.EndLine && i <= length; i++ {
+			ignores[i] = true
+		}
+	}
+	return ignores
+}
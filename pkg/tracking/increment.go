@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"go/ast"
 	"go/printer"
+	"go/scanner"
 	"go/token"
+	"go/types"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/monshunter/goat/pkg/log"
@@ -39,6 +42,24 @@ type IncrementalTrack struct {
 	patchScopes                 map[scopeKey]*patchScope
 	lineChanges                 []bool
 	comments                    []bool
+	codes                       []bool
+	ignores                     []bool
+	ignoredFile                 bool
+	nameDirectives              map[int]increament.Attrs
+	insertedAttrs               map[int]increament.Attrs
+	budget                      trackBudget
+	loopScopes                  loopScopes
+	funcGranularities           map[int]config.Granularity
+	hoistLoops                  bool
+	hoisted                     int
+	conditions                  []conditionOperand
+	conditionTypes              *types.Info
+	untypedConditions           map[int]struct{}
+	visitedConditions           map[InsertPosition]struct{}
+	implicitBranches            bool
+	implicitDefaults            map[int]struct{}
+	implicit                    int
+	initHooks                   map[int]struct{}
 }
 
 func NewIncrementalTrack(basePath string, fileChange *diff.FileChange,
@@ -77,9 +98,25 @@ func NewIncrementalTrack(basePath string, fileChange *diff.FileChange,
 		}
 	}
 	source := strings.Split(string(content), "\n")
-	comments := initComments(source)
 	lineChanges := initLineChanges(len(source), fileChange.LineChanges)
 
+	fset, astFile, err := utils.GetAstTree(fileName, content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse file %s: %w", fileName, err)
+	}
+	comments, codes := lineKindsOfAST(fset, astFile, content, source)
+
+	// analyze //goat:ignore directives, ignored lines are treated as unchanged
+	directives := ignoreDirectivesOfAST(fset, astFile)
+	ignores := directives.lines(len(source))
+	for line, ignored := range ignores {
+		if ignored {
+			lineChanges[line] = false
+		}
+	}
+	logIgnoreDirectives(fileName, directives)
+	nameDirectives := nameDirectivesOfAST(fset, astFile)
+
 	log.Debugf("Tracking file: %s (funcs=%d, trackScopes=%d)",
 		fileName, len(functionScopes), len(trackScopes))
 
@@ -103,6 +140,17 @@ func NewIncrementalTrack(basePath string, fileChange *diff.FileChange,
 		patchScopes:                 make(map[scopeKey]*patchScope),
 		lineChanges:                 lineChanges,
 		comments:                    comments,
+		codes:                       codes,
+		ignores:                     ignores,
+		ignoredFile:                 directives.file,
+		nameDirectives:              nameDirectives,
+		insertedAttrs:               make(map[int]increament.Attrs),
+		loopScopes:                  loopScopesOfAST(fset, astFile, source),
+		funcGranularities:           make(map[int]config.Granularity),
+		visitedConditions:           make(map[InsertPosition]struct{}),
+		implicitDefaults:            make(map[int]struct{}),
+		initHooks:                   make(map[int]struct{}),
+		untypedConditions:           make(map[int]struct{}),
 	}, nil
 }
 
@@ -120,7 +168,7 @@ func (t *IncrementalTrack) doInsert() ([]byte, error) {
 
 	posIdx := 0
 	i := 0
-	sources := t.source
+	sources := t.wrapConditions(t.source)
 	var buf bytes.Buffer
 	deltaArray := make([]int, len(t.singleLineInsertedPositions))
 	for i := range deltaArray {
@@ -136,7 +184,7 @@ func (t *IncrementalTrack) doInsert() ([]byte, error) {
 	buf.Grow(t.sourceLength + adjustLength)
 	for ; i < len(sources) && posIdx < len(t.insertedPositions); i++ {
 		if i == t.insertedPositions[posIdx].line-1 {
-			lines := doInsert(&buf, t.trackStmtPlaceHolders)
+			lines := doInsert(&buf, t.trackStmtPlaceHoldersOf(t.insertedPositions[posIdx].line))
 			delta += lines
 			posIdx++
 		}
@@ -223,14 +271,48 @@ func (t *IncrementalTrack) checkAndMarkInsert(line int) {
 	t.forceMarkInsert(line)
 }
 
+// checkAndMarkInsertStmt marks the insert before the statement if any line of it is changed,
+// so an edit on a later line of a multi-line statement is tracked as well
+func (t *IncrementalTrack) checkAndMarkInsertStmt(stmt ast.Stmt, fset *token.FileSet) {
+	lines := spanLines(fset, stmt.Pos(), stmt.End(), stmt)
+	if !t.isAnyLineChanged(lines) {
+		return
+	}
+	t.forceMarkInsert(lines[0])
+	t.markPatchSpan(lines)
+}
+
+// markPatchSpan marks the changed lines following the first line of a multi-line statement
+// as inserted in its patch scope, they are covered by the tracking point of the statement
+func (t *IncrementalTrack) markPatchSpan(lines []int) {
+	if len(lines) < 2 || !t.granularityOf(lines[0]).IsPatch() {
+		return
+	}
+	idx := t.trackScopes.Search(lines[0])
+	if idx == -1 {
+		return
+	}
+	trackScope := t.trackScopes[idx].Search(lines[0])
+	patchScope := t.patchScopes[scopeKey{startLine: trackScope.StartLine, endLine: trackScope.EndLine}]
+	if patchScope == nil {
+		return
+	}
+	for _, line := range lines[1:] {
+		if line > patchScope.startLine && line < patchScope.endLine && patchScope.isNewLine(line) {
+			patchScope.markInserted(line)
+		}
+	}
+}
+
 func (t *IncrementalTrack) forceMarkInsert(line int) {
-	if t.granularity.IsFunc() {
+	granularity := t.granularityOf(line)
+	if granularity.IsFunc() {
 		t.markInsertByFunc(line)
-	} else if t.granularity.IsScope() {
+	} else if granularity.IsScope() {
 		t.markInsertByScope(line)
-	} else if t.granularity.IsPatch() {
+	} else if granularity.IsPatch() {
 		t.markInsertByPatch(line)
-	} else if t.granularity.IsLine() {
+	} else if granularity.IsLine() || granularity.IsCondition() {
 		t.markInsertByLine(line)
 	}
 }
@@ -284,27 +366,77 @@ func (t *IncrementalTrack) markInsertByPatch(line int) {
 	}
 }
 
+// isLineChanged checks if the line is changed and holds code,
+// changed comment, blank and string-only lines are never tracked
 func (t *IncrementalTrack) isLineChanged(line int) bool {
-	return t.lineChanges[line]
+	return t.lineChanges[line] && t.codes[line]
 }
 
-func (t *IncrementalTrack) isLineChangedRange(start, end int) bool {
-	for i := start; i <= end; i++ {
-		if t.isLineChanged(i) {
+func (t *IncrementalTrack) isAnyLineChanged(lines []int) bool {
+	for _, line := range lines {
+		if t.isLineChanged(line) {
 			return true
 		}
 	}
 	return false
 }
 
-func (t *IncrementalTrack) markInsert(line int) {
+// isSpanChanged checks if any line between the positions is changed,
+// the bodies of the function literals of the nodes excluded
+func (t *IncrementalTrack) isSpanChanged(fset *token.FileSet, from, to token.Pos, nodes ...ast.Node) bool {
+	return t.isAnyLineChanged(spanLines(fset, from, to, nodes...))
+}
+
+// spanLines returns the lines between the positions, without the lines inside the bodies
+// of the function literals of the nodes, which are tracked on their own
+func spanLines(fset *token.FileSet, from, to token.Pos, nodes ...ast.Node) []int {
+	funcLits := BlockScopes{}
+	for _, node := range nodes {
+		if node == nil {
+			continue
+		}
+		ast.Inspect(node, func(n ast.Node) bool {
+			if lit, ok := n.(*ast.FuncLit); ok {
+				funcLits = append(funcLits, BlockScope{
+					StartLine: fset.Position(lit.Body.Lbrace).Line,
+					EndLine:   fset.Position(lit.Body.Rbrace).Line,
+				})
+				return false
+			}
+			return true
+		})
+	}
+	start, end := fset.Position(from).Line, fset.Position(to).Line
+	lines := make([]int, 0, end-start+1)
+	for line := start; line <= end; line++ {
+		if !slices.ContainsFunc(funcLits, func(lit BlockScope) bool { return lit.Contains(line) }) {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// markInsert marks a tracking point before the line, it returns the line of the tracking point
+// and whether it is a new one
+func (t *IncrementalTrack) markInsert(line int) (int, bool) {
 	for t.comments[line] {
 		line++
 	}
 
 	// Add a check to avoid inserts outside of function scopes which will cause error
 	if !t.isInFunctionScopes(line) {
-		return
+		return line, false
+	}
+
+	// Skip the lines excluded by //goat:ignore directives
+	if t.ignores[line] {
+		return line, false
+	}
+
+	// Hoist the tracking points of a branch-free loop body right before the loop
+	line, hoisted := t.hoistedLine(line)
+	if hoisted && t.ignores[line] {
+		return line, false
 	}
 
 	// Add a check to avoid duplicate inserts
@@ -313,11 +445,49 @@ func (t *IncrementalTrack) markInsert(line int) {
 	// It is important for ensuring the correctness and avoiding unnecessary duplicate tracking.
 	key := InsertPosition{line: line, column: 0}
 	if _, ok := t.visitedInsertedPositions[key]; ok {
-		return
+		return line, false
 	}
 	t.insertedPositions.Insert(line, 0)
 	t.visitedInsertedPositions[key] = struct{}{}
+	t.markAttrs(line)
+	if hoisted {
+		t.markHoisted(line)
+	}
 	t.count++
+	return line, true
+}
+
+// markAttrs records the attributes of the //goat:name directive
+// in the comments directly preceding the insert line
+func (t *IncrementalTrack) markAttrs(line int) {
+	for i := line - 1; i > 0 && t.comments[i]; i-- {
+		if strings.TrimSpace(t.source[i-1]) == "" {
+			return
+		}
+		if attrs, ok := t.nameDirectives[i]; ok {
+			t.insertedAttrs[line] = attrs
+			return
+		}
+	}
+}
+
+// trackStmtPlaceHoldersOf returns the track statement place holders of the insert line,
+// with the attributes of the line appended to the generate comment
+func (t *IncrementalTrack) trackStmtPlaceHoldersOf(line int) []string {
+	placeHolders := t.trackStmtPlaceHolders
+	if _, ok := t.implicitDefaults[line]; ok {
+		placeHolders = increament.InsertImplicitDefault(placeHolders)
+	}
+	if _, ok := t.initHooks[line]; ok {
+		placeHolders = increament.InsertInitHook(placeHolders)
+	}
+	attrs, ok := t.insertedAttrs[line]
+	if !ok || len(placeHolders) == 0 || placeHolders[0] != config.TrackGenerateComment {
+		return placeHolders
+	}
+	placeHolders = slices.Clone(placeHolders)
+	placeHolders[0] = config.TrackGenerateComment + " " + attrs.String()
+	return placeHolders
 }
 
 func (t *IncrementalTrack) isInFunctionScopes(line int) bool {
@@ -343,6 +513,7 @@ func (t *IncrementalTrack) Track() (int, error) {
 	t.insertedPositions.Reset()
 	t.singleLineInsertedPositions.Reset()
 	clear(t.visitedInsertedPositions)
+	clear(t.insertedAttrs)
 	log.Debugf("Adding tracking to file: %s", t.fileName)
 	t.content, err = t.addStmts()
 	if err != nil {
@@ -382,11 +553,35 @@ func (t *IncrementalTrack) SetContent(content []byte) {
 
 // addStmts adds tracking statements to the target file
 func (t *IncrementalTrack) addStmts() ([]byte, error) {
+	if t.ignoredFile {
+		return t.content, nil
+	}
 	fset, f, err := utils.GetAstTree("", t.content)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse file %s: %w", t.fileName, err)
 	}
 
+	if t.granularity.IsCondition() {
+		t.conditionTypes = conditionTypesOf(fset, f, t.fileName)
+	}
+	t.markStmts(fset, f)
+	for {
+		coarsened, err := t.enforceBudget()
+		if err != nil {
+			return nil, err
+		}
+		if !coarsened {
+			break
+		}
+		t.resetMarks()
+		t.markStmts(fset, f)
+	}
+	t.warnUntypedConditions()
+	return t.doInsert()
+}
+
+// markStmts marks the tracking points of the declarations of the file
+func (t *IncrementalTrack) markStmts(fset *token.FileSet, f *ast.File) {
 	for _, decl := range f.Decls {
 		switch decl := decl.(type) {
 		case *ast.FuncDecl:
@@ -406,9 +601,9 @@ func (t *IncrementalTrack) addStmts() ([]byte, error) {
 		case *ast.GenDecl:
 			t.processGlobalValueSpecs(decl.Specs, fset)
 			t.processGlobalFunctionLit(decl.Specs, fset)
+			t.markInitHook(fset, decl)
 		}
 	}
-	return t.doInsert()
 }
 
 func (t *IncrementalTrack) processGlobalValueSpecs(specs []ast.Spec, fset *token.FileSet) {
@@ -479,18 +674,13 @@ func (t *IncrementalTrack) processControlStatements(node ast.Node, fset *token.F
 			// 1. If the if statement is changed, it means the whole if statement may be modified
 			// 2. Even if the Init part is not changed, the change of the if statement may affect the whole statement structure
 			// 3. In this case, we need to add tracking statements to the if statement
-			changed = t.isLineChanged(fset.Position(n.If).Line)
-			if !changed && n.Init != nil {
-				changed = t.isLineChangedRange(fset.Position(n.Init.Pos()).Line,
-					fset.Position(n.Init.End()).Line)
-			}
-			if !changed && n.Cond != nil {
-				changed = t.isLineChangedRange(fset.Position(n.Cond.Pos()).Line,
-					fset.Position(n.Cond.End()).Line)
-			}
+			// 4. The header is checked line by line up to the opening brace, so a multi-line condition counts
+			changed = t.isSpanChanged(fset, n.If, n.Body.Lbrace, n.Init, n.Cond)
 
 			if changed {
+				t.markConditions(fset, n.Cond)
 				t.forceMarkInsert(fset.Position(n.Body.Lbrace).Line + 1)
+				t.markImplicitElse(fset, n)
 				if n.Else != nil {
 					elseStmt, ok := n.Else.(*ast.BlockStmt)
 					if ok && len(elseStmt.List) > 0 {
@@ -505,31 +695,35 @@ func (t *IncrementalTrack) processControlStatements(node ast.Node, fset *token.F
 			// 1. If the switch keyword line is changed, it means the whole switch statement may be modified
 			// 2. Even if the Init and Tag parts are not changed, the change of the switch keyword line may affect the whole statement structure
 			// 3. In this case, we need to add tracking statements to each case clause
-			changed = t.isLineChanged(fset.Position(n.Switch).Line)
-			if !changed && n.Init != nil {
-				changed = t.isLineChangedRange(fset.Position(n.Init.Pos()).Line,
-					fset.Position(n.Init.End()).Line)
-			}
-			if !changed && n.Tag != nil {
-				changed = t.isLineChangedRange(fset.Position(n.Tag.Pos()).Line,
-					fset.Position(n.Tag.End()).Line)
+			if n.Body != nil {
+				changed = t.isSpanChanged(fset, n.Switch, n.Body.Lbrace, n.Init, n.Tag)
 			}
-			if changed && n.Body != nil {
+			if changed {
 				for _, subStmt := range n.Body.List {
 					if caseClause, ok := subStmt.(*ast.CaseClause); ok && len(caseClause.Body) > 0 {
 						t.forceMarkInsert(fset.Position(caseClause.Colon).Line + 1)
 					}
 				}
+				t.markImplicitDefault(fset, n.Body)
+			}
+			// The cases of a switch without a tag are conditions
+			if n.Tag == nil && n.Body != nil {
+				for _, subStmt := range n.Body.List {
+					caseClause, ok := subStmt.(*ast.CaseClause)
+					if !ok {
+						continue
+					}
+					for _, expr := range caseClause.List {
+						if t.isSpanChanged(fset, expr.Pos(), expr.End(), expr) {
+							t.markConditions(fset, expr)
+						}
+					}
+				}
 			}
 		case *ast.TypeSwitchStmt:
 			// return true
-			changed = t.isLineChanged(fset.Position(n.Switch).Line)
-			if !changed && n.Init != nil {
-				changed = t.isLineChangedRange(fset.Position(n.Init.Pos()).Line,
-					fset.Position(n.Init.End()).Line)
-			}
-			if !changed && n.Assign != nil {
-				changed = t.isLineChangedRange(fset.Position(n.Assign.Pos()).Line, fset.Position(n.Assign.End()).Line)
+			if n.Body != nil {
+				changed = t.isSpanChanged(fset, n.Switch, n.Body.Lbrace, n.Init, n.Assign)
 			}
 
 			// Judge if the type switch statement is changed:
@@ -542,28 +736,25 @@ func (t *IncrementalTrack) processControlStatements(node ast.Node, fset *token.F
 						t.forceMarkInsert(fset.Position(caseClause.Colon).Line + 1)
 					}
 				}
+				t.markImplicitDefault(fset, n.Body)
 			}
 		case *ast.CaseClause:
 			// return true
-			changed = t.isLineChanged(fset.Position(n.Case).Line)
-			if !changed && len(n.List) > 0 {
-				for _, expr := range n.List {
-					changed = t.isLineChangedRange(fset.Position(expr.Pos()).Line, fset.Position(expr.End()).Line)
-					if changed {
-						break
-					}
-				}
+			nodes := make([]ast.Node, 0, len(n.List))
+			for _, expr := range n.List {
+				nodes = append(nodes, expr)
 			}
+			changed = t.isSpanChanged(fset, n.Case, n.Colon, nodes...)
 			if changed {
 				t.forceMarkInsert(fset.Position(n.Colon).Line + 1)
 			}
 
+		case *ast.SelectStmt:
+			// A select without default has no implicit branch to track: it blocks until a case is ready,
+			// and a synthesized default case would make it non-blocking
 		case *ast.CommClause:
 			// return true
-			changed = t.isLineChanged(fset.Position(n.Case).Line)
-			if !changed && n.Comm != nil {
-				changed = t.isLineChangedRange(fset.Position(n.Comm.Pos()).Line, fset.Position(n.Comm.End()).Line)
-			}
+			changed = t.isSpanChanged(fset, n.Case, n.Colon, n.Comm)
 			if changed {
 				t.forceMarkInsert(fset.Position(n.Colon).Line + 1)
 			}
@@ -576,18 +767,7 @@ func (t *IncrementalTrack) processControlStatements(node ast.Node, fset *token.F
 			// 1. If the range statement is changed, it means the whole range statement may be modified
 			// 2. Even if the Key and Value parts are not changed, the change of the range statement may affect the whole statement structure
 			// 3. In this case, we need to add tracking statements to the range statement
-			changed = t.isLineChanged(fset.Position(n.For).Line)
-			if !changed && n.Key != nil {
-				changed = t.isLineChangedRange(fset.Position(n.Key.Pos()).Line,
-					fset.Position(n.Key.End()).Line)
-			}
-			if !changed && n.Value != nil {
-				changed = t.isLineChangedRange(fset.Position(n.Value.Pos()).Line,
-					fset.Position(n.Value.End()).Line)
-			}
-			if !changed && n.X != nil {
-				changed = t.isLineChangedRange(fset.Position(n.X.Pos()).Line, fset.Position(n.X.End()).Line)
-			}
+			changed = t.isSpanChanged(fset, n.For, n.Body.Lbrace, n.Key, n.Value, n.X)
 
 			if changed {
 				t.forceMarkInsert(fset.Position(n.Body.Lbrace).Line + 1)
@@ -600,21 +780,10 @@ func (t *IncrementalTrack) processControlStatements(node ast.Node, fset *token.F
 			// 1. If the for statement is changed, it means the whole for statement may be modified
 			// 2. Even if the Init and Assign parts are not changed, the change of the for statement may affect the whole statement structure
 			// 3. In this case, we need to add tracking statements to each case clause
-			changed = t.isLineChanged(fset.Position(n.For).Line)
-			if !changed && n.Init != nil {
-				changed = t.isLineChangedRange(fset.Position(n.Init.Pos()).Line,
-					fset.Position(n.Init.End()).Line)
-			}
-			if !changed && n.Cond != nil {
-				changed = t.isLineChangedRange(fset.Position(n.Cond.Pos()).Line,
-					fset.Position(n.Cond.End()).Line)
-			}
-			if !changed && n.Post != nil {
-				changed = t.isLineChangedRange(fset.Position(n.Post.Pos()).Line,
-					fset.Position(n.Post.End()).Line)
-			}
+			changed = t.isSpanChanged(fset, n.For, n.Body.Lbrace, n.Init, n.Cond, n.Post)
 
 			if changed {
+				t.markConditions(fset, n.Cond)
 				t.forceMarkInsert(fset.Position(n.Body.Lbrace).Line + 1)
 			}
 		}
@@ -631,9 +800,10 @@ func (t *IncrementalTrack) processStatements(statList []ast.Stmt, fset *token.Fi
 		}
 		switch s := stmt.(type) {
 		case *ast.AssignStmt:
-			t.checkAndMarkInsert(fset.Position(s.Pos()).Line)
-			t.analyzeAndModifyExpr(s.Rhs, fset)
+			t.checkAndMarkInsertStmt(s, fset)
+			t.analyzeAndModifyExpr(fset, s)
 		case *ast.IfStmt:
+			t.analyzeAndModifyExpr(fset, s.Init, s.Cond)
 			if s.Body != nil {
 				t.processStatements(s.Body.List, fset)
 			}
@@ -646,58 +816,56 @@ func (t *IncrementalTrack) processStatements(statList []ast.Stmt, fset *token.Fi
 				}
 			}
 		case *ast.ForStmt:
+			t.analyzeAndModifyExpr(fset, s.Init, s.Cond, s.Post)
 			if s.Body != nil {
 				t.processStatements(s.Body.List, fset)
 			}
 		case *ast.RangeStmt:
+			t.analyzeAndModifyExpr(fset, s.Key, s.Value, s.X)
 			if s.Body != nil {
 				t.processStatements(s.Body.List, fset)
 			}
 		case *ast.SwitchStmt:
+			t.analyzeAndModifyExpr(fset, s.Init, s.Tag)
 			if s.Body != nil {
 				t.processStatements(s.Body.List, fset)
 			}
 		case *ast.CommClause:
+			t.analyzeAndModifyExpr(fset, s.Comm)
 			t.processStatements(s.Body, fset)
 		case *ast.CaseClause:
+			for _, expr := range s.List {
+				t.analyzeAndModifyExpr(fset, expr)
+			}
 			t.processStatements(s.Body, fset)
 		case *ast.BlockStmt:
 			t.checkAndMarkInsert(fset.Position(s.Pos()).Line)
 			t.processStatements(s.List, fset)
 		case *ast.ReturnStmt:
-			t.checkAndMarkInsert(fset.Position(s.Pos()).Line)
-			t.analyzeAndModifyExpr(s.Results, fset)
+			t.checkAndMarkInsertStmt(s, fset)
+			t.analyzeAndModifyExpr(fset, s)
 		case *ast.DeferStmt:
-			t.checkAndMarkInsert(fset.Position(s.Pos()).Line)
-			if s.Call != nil && s.Call.Fun != nil {
-				t.analyzeAndModifyExpr([]ast.Expr{s.Call.Fun}, fset)
-			}
+			t.checkAndMarkInsertStmt(s, fset)
+			t.analyzeAndModifyExpr(fset, s)
 		case *ast.SelectStmt:
 			if s.Body != nil {
 				t.processStatements(s.Body.List, fset)
 			}
 		case *ast.GoStmt:
-			t.checkAndMarkInsert(fset.Position(s.Pos()).Line)
-			if s.Call != nil && s.Call.Fun != nil {
-				t.analyzeAndModifyExpr([]ast.Expr{s.Call.Fun}, fset)
-			}
+			t.checkAndMarkInsertStmt(s, fset)
+			t.analyzeAndModifyExpr(fset, s)
 		case *ast.TypeSwitchStmt:
+			t.analyzeAndModifyExpr(fset, s.Init, s.Assign)
 			if s.Body != nil {
 				t.processStatements(s.Body.List, fset)
 			}
 		case *ast.ExprStmt:
 			switch s.X.(type) {
 			case *ast.CallExpr:
-				t.checkAndMarkInsert(fset.Position(s.Pos()).Line)
-				expr := s.X.(*ast.CallExpr)
-				if expr.Fun != nil {
-					t.analyzeAndModifyExpr([]ast.Expr{expr.Fun}, fset)
-				}
-				if expr.Args != nil {
-					t.analyzeAndModifyExpr(expr.Args, fset)
-				}
+				t.checkAndMarkInsertStmt(s, fset)
 			default:
 			}
+			t.analyzeAndModifyExpr(fset, s.X)
 		case *ast.LabeledStmt:
 			t.processStatements([]ast.Stmt{s.Stmt}, fset)
 		// Improve:
@@ -713,69 +881,44 @@ func (t *IncrementalTrack) processStatements(statList []ast.Stmt, fset *token.Fi
 						switch spec := spec.(type) {
 						case *ast.ValueSpec:
 							if len(spec.Values) > 0 {
-								t.checkAndMarkInsert(fset.Position(s.Pos()).Line)
+								t.checkAndMarkInsertStmt(s, fset)
 							}
 						}
 					}
 				}
 			}
+			t.analyzeAndModifyExpr(fset, s)
 		default:
-			t.checkAndMarkInsert(fset.Position(s.Pos()).Line)
+			t.checkAndMarkInsertStmt(s, fset)
+			t.analyzeAndModifyExpr(fset, s)
 		}
 	}
 }
 
-// analyzeAndModifyExpr analyzes and modifies expressions by processing any function literals found.
-// It works in conjunction with processStatements to recursively handle nested expressions.
-func (t *IncrementalTrack) analyzeAndModifyExpr(exprList []ast.Expr, fset *token.FileSet) {
-	for _, expr := range exprList {
-		if expr == nil {
+// analyzeAndModifyExpr analyzes and modifies the function literals found anywhere in the nodes,
+// the statements of their bodies are processed by processStatements, which handles the nested ones.
+func (t *IncrementalTrack) analyzeAndModifyExpr(fset *token.FileSet, nodes ...ast.Node) {
+	for _, node := range nodes {
+		if node == nil {
 			continue
 		}
-		switch expr := expr.(type) {
-		case *ast.FuncLit:
-
+		ast.Inspect(node, func(n ast.Node) bool {
+			expr, ok := n.(*ast.FuncLit)
+			if !ok {
+				return true
+			}
 			if expr.Body == nil || len(expr.Body.List) == 0 {
-				continue
+				return false
 			}
 			// Handle single line function
 			if fset.Position(expr.Pos()).Line == fset.Position(expr.End()).Line {
 				pos := fset.Position(expr.Body.List[0].Pos())
 				t.insertSingleLineStmt(pos)
-				continue
+				return false
 			}
 			t.processStatements(expr.Body.List, fset)
-		case *ast.CallExpr:
-			if expr.Fun != nil {
-				t.analyzeAndModifyExpr([]ast.Expr{expr.Fun}, fset)
-			}
-			if expr.Args != nil {
-				t.analyzeAndModifyExpr(expr.Args, fset)
-			}
-		case *ast.StructType:
-			if expr.Fields != nil {
-				for _, field := range expr.Fields.List {
-					if field.Type != nil {
-						t.analyzeAndModifyExpr([]ast.Expr{field.Type}, fset)
-					}
-				}
-			}
-		case *ast.CompositeLit:
-			if expr.Elts != nil {
-				for _, elt := range expr.Elts {
-					t.analyzeAndModifyExpr([]ast.Expr{elt}, fset)
-				}
-			}
-		case *ast.KeyValueExpr:
-			if expr.Value != nil {
-				t.analyzeAndModifyExpr([]ast.Expr{expr.Value}, fset)
-			}
-		case *ast.UnaryExpr:
-			if expr.X != nil {
-				t.analyzeAndModifyExpr([]ast.Expr{expr.X}, fset)
-			}
-		default:
-		}
+			return false
+		})
 	}
 }
 
@@ -816,12 +959,64 @@ func initLineChanges(length int, lineChanges diff.LineChanges) []bool {
 	return res
 }
 
-// initComments initializes the comments array
-func initComments(sources []string) []bool {
+// logIgnoreDirectives reports the code excluded from tracking by directives in verbose output
+func logIgnoreDirectives(fileName string, directives ignoreDirectives) {
+	if directives.file {
+		log.Debugf("Ignoring file %s by %s", fileName, config.IgnoreFileDirective)
+		return
+	}
+	for _, scope := range directives.scopes {
+		if scope.name != "" {
+			log.Debugf("Ignoring function %s in %s:%d-%d by %s",
+				scope.name, fileName, scope.StartLine, scope.EndLine, scope.directive)
+			continue
+		}
+		log.Debugf("Ignoring block in %s:%d-%d by %s",
+			fileName, scope.StartLine, scope.EndLine, scope.directive)
+	}
+}
+
+// lineKindsOfAST classifies the lines of the file from its comments and tokens,
+// comments marks the blank and comment-only lines, codes marks the lines where a token starts,
+// the lines inside a multi-line string literal are neither
+func lineKindsOfAST(fset *token.FileSet, astFile *ast.File, content []byte, source []string) (comments, codes []bool) {
 	// +1 for the line number, because the line number is 1-based
-	comments := make([]bool, len(sources)+1)
-	for i, source := range sources {
-		comments[i+1] = utils.IsGoComment(source)
+	comments = make([]bool, len(source)+1)
+	codes = make([]bool, len(source)+1)
+	literals := make([]bool, len(source)+1)
+
+	file := token.NewFileSet().AddFile("", -1, len(content))
+	var s scanner.Scanner
+	s.Init(file, content, nil, 0)
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		// skip the semicolons inserted automatically at the end of the lines
+		if tok == token.SEMICOLON && lit == "\n" {
+			continue
+		}
+		line := file.Line(pos)
+		codes[line] = true
+		if tok == token.STRING {
+			for i := 1; i <= strings.Count(lit, "\n"); i++ {
+				literals[line+i] = true
+			}
+		}
+	}
+
+	for _, group := range astFile.Comments {
+		for _, comment := range group.List {
+			for line := fset.Position(comment.Pos()).Line; line <= fset.Position(comment.End()).Line; line++ {
+				comments[line] = !codes[line] && !literals[line]
+			}
+		}
+	}
+	for i, line := range source {
+		if !codes[i+1] && !literals[i+1] && strings.TrimSpace(line) == "" {
+			comments[i+1] = true
+		}
 	}
-	return comments
+	return comments, codes
 }
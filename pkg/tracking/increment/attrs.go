@@ -0,0 +1,216 @@
+package increment
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/monshunter/goat/pkg/config"
+)
+
+// attrValueRegexp is the regexp of a valid attribute value (name or tag)
+var attrValueRegexp = regexp.MustCompile(`^[A-Za-z0-9_.:/-]+$`)
+
+// trackIdRegexp is the regexp of a numbered track statement
+var trackIdRegexp = regexp.MustCompile(`\.Track(?:Time)?\(\w+\.TRACK_ID_(\d+)\)`)
+
+// TrackCondRegexp is the regexp of the numbered prefix of a call wrapping a condition operand
+var TrackCondRegexp = regexp.MustCompile(`\b\w+\.TrackCond\(\w+\.TRACK_ID_(\d+), \w+\.TRACK_ID_(\d+),`)
+
+// Attrs is the attributes of a track point, which are written after the
+// +goat:generate and +goat:insert comments, e.g.
+// "// +goat:insert name=refund-flow tags=payments,critical"
+type Attrs struct {
+	// Name is the name of the track point
+	Name string
+	// Tags is the tags of the track point
+	Tags []string
+}
+
+// ParseAttrs parses the attributes from a "key=value" list separated by spaces.
+// A leading value without a key is taken as the name, unknown keys are ignored
+func ParseAttrs(s string) (Attrs, error) {
+	var attrs Attrs
+	for i, field := range strings.Fields(s) {
+		key, value, found := strings.Cut(field, "=")
+		if !found {
+			if i > 0 {
+				return Attrs{}, fmt.Errorf("invalid attribute %q, expected key=value", field)
+			}
+			key, value = "name", field
+		}
+		switch key {
+		case "name":
+			if !attrValueRegexp.MatchString(value) {
+				return Attrs{}, fmt.Errorf("invalid name %q", value)
+			}
+			attrs.Name = value
+		case "tags":
+			for _, tag := range strings.Split(value, ",") {
+				if tag == "" {
+					continue
+				}
+				if !attrValueRegexp.MatchString(tag) {
+					return Attrs{}, fmt.Errorf("invalid tag %q", tag)
+				}
+				attrs.Tags = append(attrs.Tags, tag)
+			}
+		}
+	}
+	return attrs, nil
+}
+
+// IsEmpty checks if the attributes are empty
+func (a Attrs) IsEmpty() bool {
+	return a.Name == "" && len(a.Tags) == 0
+}
+
+// String returns the "key=value" representation of the attributes
+func (a Attrs) String() string {
+	fields := make([]string, 0, 2)
+	if a.Name != "" {
+		fields = append(fields, "name="+a.Name)
+	}
+	if len(a.Tags) > 0 {
+		fields = append(fields, "tags="+strings.Join(a.Tags, ","))
+	}
+	return strings.Join(fields, " ")
+}
+
+// HasTag checks if the attributes contain the tag
+func (a Attrs) HasTag(tag string) bool {
+	for _, t := range a.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseMarkerAttrs parses the attributes following the marker in the first line of the block,
+// e.g. "// +goat:insert name=refund-flow tags=payments,critical"
+func ParseMarkerAttrs(block string, marker string) (Attrs, error) {
+	line, _, _ := strings.Cut(strings.TrimLeft(block, " \t\r\n"), "\n")
+	_, rest, found := strings.Cut(line, marker)
+	if !found {
+		return Attrs{}, nil
+	}
+	return ParseAttrs(rest)
+}
+
+// GetPackageInsertStmtsWithAttrs returns the package insert statements
+// with the attributes appended to the generate comment
+func GetPackageInsertStmtsWithAttrs(attrs Attrs) []string {
+	stmts := GetPackageInsertStmts()
+	if !attrs.IsEmpty() {
+		stmts[0] = config.TrackGenerateComment + " " + attrs.String()
+	}
+	return stmts
+}
+
+// ImplicitDefaultStmt is the default case synthesized in the generate block of a switch without one
+const ImplicitDefaultStmt = "default:"
+
+// InsertImplicitDefault returns the package insert statements with the synthesized default case
+// before the track statement
+func InsertImplicitDefault(stmts []string) []string {
+	idx := slices.Index(stmts, TrackStmtPlaceHolder)
+	if idx == -1 {
+		return stmts
+	}
+	return slices.Insert(slices.Clone(stmts), idx, ImplicitDefaultStmt)
+}
+
+// HasImplicitDefault checks if the generate block holds a synthesized default case
+func HasImplicitDefault(block string) bool {
+	for _, line := range strings.Split(block, "\n") {
+		if strings.TrimSpace(line) == ImplicitDefaultStmt {
+			return true
+		}
+	}
+	return false
+}
+
+// InitHookStmt is the init function synthesized around the track statement of a changed package-level initializer
+const InitHookStmt = "func init() {"
+
+// InsertInitHook returns the package insert statements with the track statement
+// wrapped in the synthesized init function
+func InsertInitHook(stmts []string) []string {
+	idx := slices.Index(stmts, TrackStmtPlaceHolder)
+	if idx == -1 {
+		return stmts
+	}
+	return slices.Insert(slices.Insert(slices.Clone(stmts), idx+1, "}"), idx, InitHookStmt)
+}
+
+// HasInitHook checks if the generate block holds a synthesized init function
+func HasInitHook(block string) bool {
+	for _, line := range strings.Split(block, "\n") {
+		if strings.TrimSpace(line) == InitHookStmt {
+			return true
+		}
+	}
+	return false
+}
+
+// TrackStmtRegexp matches the track statement place holders, including the synthesized init function of the init hooks
+var TrackStmtRegexp = regexp.MustCompile(`(?:` + regexp.QuoteMeta(InitHookStmt) + `\s*)?` +
+	regexp.QuoteMeta(TrackStmtPlaceHolder))
+
+// IncreamentReplaceHookStmt returns replaceStmt for the matches of TrackStmtRegexp, the track statements
+// of the init hooks are always numbered by IncreamentReplaceStmt, a timed call would only time the init function
+func IncreamentReplaceHookStmt(replaceStmt func(ident string, start int) func(older string) (newer string)) func(ident string,
+	start int) func(older string) (newer string) {
+	return func(ident string, start int) func(older string) (newer string) {
+		return func(older string) (newer string) {
+			replace := replaceStmt
+			if strings.HasPrefix(older, InitHookStmt) {
+				replace = IncreamentReplaceStmt
+			}
+			newer = strings.TrimSuffix(older, TrackStmtPlaceHolder) + replace(ident, start)(TrackStmtPlaceHolder)
+			start++
+			return
+		}
+	}
+}
+
+// GetPackageInsertDataStringWithAttrs returns the package insert data string
+// with the attributes appended to the generate comment
+func GetPackageInsertDataStringWithAttrs(attrs Attrs) string {
+	return strings.Join(GetPackageInsertStmtsWithAttrs(attrs), "\n") + "\n"
+}
+
+// TrackAttrsOf returns the attributes of the numbered track points in the content,
+// the true and false track points of the condition operands are tagged as such,
+// the result is the map of the track ID to the attributes
+func TrackAttrsOf(content string) map[int]Attrs {
+	result := make(map[int]Attrs)
+	for _, block := range config.TrackGenerateEndRegexp.FindAllString(content, -1) {
+		attrs, err := ParseMarkerAttrs(block, config.TrackGenerateComment)
+		if err != nil || attrs.IsEmpty() {
+			continue
+		}
+		match := trackIdRegexp.FindStringSubmatch(block)
+		if match == nil {
+			continue
+		}
+		id, err := strconv.Atoi(match[1])
+		if err != nil {
+			continue
+		}
+		result[id] = attrs
+	}
+	for _, match := range TrackCondRegexp.FindAllStringSubmatch(content, -1) {
+		trueId, trueErr := strconv.Atoi(match[1])
+		falseId, falseErr := strconv.Atoi(match[2])
+		if trueErr != nil || falseErr != nil {
+			continue
+		}
+		result[trueId] = Attrs{Tags: []string{config.ConditionTrueTag}}
+		result[falseId] = Attrs{Tags: []string{config.ConditionFalseTag}}
+	}
+	return result
+}
@@ -0,0 +1,146 @@
+package increment
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/monshunter/goat/pkg/config"
+)
+
+func TestParseAttrs(t *testing.T) {
+	testCases := []struct {
+		name    string
+		input   string
+		want    Attrs
+		wantErr bool
+	}{
+		{name: "empty", input: "", want: Attrs{}},
+		{name: "name and tags", input: " name=refund-flow tags=payments,critical",
+			want: Attrs{Name: "refund-flow", Tags: []string{"payments", "critical"}}},
+		{name: "bare name", input: "refund-flow tags=payments", want: Attrs{Name: "refund-flow", Tags: []string{"payments"}}},
+		{name: "empty tags are skipped", input: "tags=,critical,", want: Attrs{Tags: []string{"critical"}}},
+		{name: "unknown keys are ignored", input: "name=a owner=me", want: Attrs{Name: "a"}},
+		{name: "bare value after the first field", input: "name=a b", wantErr: true},
+		{name: "invalid name", input: `name=a"b`, wantErr: true},
+		{name: "invalid tag", input: "tags=a,b c", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseAttrs(tc.input)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseAttrs(%q) error = %v, wantErr %v", tc.input, err, tc.wantErr)
+			}
+			if !tc.wantErr && !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ParseAttrs(%q) = %+v, want %+v", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAttrsString(t *testing.T) {
+	attrs := Attrs{Name: "refund-flow", Tags: []string{"payments", "critical"}}
+	if got, want := attrs.String(), "name=refund-flow tags=payments,critical"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	parsed, err := ParseAttrs(attrs.String())
+	if err != nil || !reflect.DeepEqual(parsed, attrs) {
+		t.Errorf("ParseAttrs(String()) = %+v, %v, want %+v", parsed, err, attrs)
+	}
+	if !(Attrs{}).IsEmpty() || attrs.IsEmpty() {
+		t.Errorf("IsEmpty() returned an unexpected result")
+	}
+	if !attrs.HasTag("critical") || attrs.HasTag("refund-flow") {
+		t.Errorf("HasTag() returned an unexpected result")
+	}
+}
+
+func TestTrackAttrsOf(t *testing.T) {
+	content := `package main
+
+func main() {
+	// +goat:generate name=refund-flow tags=payments,critical
+	// +goat:tips: do not edit the block between the +goat comments
+	goat.Track(goat.TRACK_ID_1)
+	// +goat:end
+	a := 1
+	// +goat:generate
+	// +goat:tips: do not edit the block between the +goat comments
+	goat.Track(goat.TRACK_ID_2)
+	// +goat:end
+	b := 2
+	// +goat:generate tags=critical
+	// +goat:tips: do not edit the block between the +goat comments
+	goat.Track(goat.TRACK_ID_3)
+	// +goat:end
+	if goat.TrackCond(goat.TRACK_ID_4, goat.TRACK_ID_5, a > 0) && b > 0 {
+		_, _ = a, b
+	}
+}
+`
+	want := map[int]Attrs{
+		1: {Name: "refund-flow", Tags: []string{"payments", "critical"}},
+		3: {Tags: []string{"critical"}},
+		4: {Tags: []string{config.ConditionTrueTag}},
+		5: {Tags: []string{config.ConditionFalseTag}},
+	}
+	if got := TrackAttrsOf(content); !reflect.DeepEqual(got, want) {
+		t.Errorf("TrackAttrsOf() = %+v, want %+v", got, want)
+	}
+}
+
+func TestGetPackageInsertDataStringWithAttrs(t *testing.T) {
+	if got, want := GetPackageInsertDataStringWithAttrs(Attrs{}), GetPackageInsertDataString(); got != want {
+		t.Errorf("empty attributes should render the default insert data, got %q", got)
+	}
+	data := GetPackageInsertDataStringWithAttrs(Attrs{Name: "refund-flow"})
+	attrs, err := ParseMarkerAttrs(data, "// +goat:generate")
+	if err != nil || attrs.Name != "refund-flow" {
+		t.Errorf("ParseMarkerAttrs() = %+v, %v, want the name refund-flow", attrs, err)
+	}
+}
+
+func TestImplicitDefault(t *testing.T) {
+	stmts := InsertImplicitDefault(GetPackageInsertStmts())
+	if idx := slices.Index(stmts, ImplicitDefaultStmt); idx == -1 || stmts[idx+1] != TrackStmtPlaceHolder {
+		t.Errorf("InsertImplicitDefault() = %q, want the default case before the track statement", stmts)
+	}
+	if HasImplicitDefault(GetPackageInsertDataString()) {
+		t.Errorf("HasImplicitDefault() = true for the default insert data")
+	}
+	if !HasImplicitDefault(strings.Join(stmts, "\n\t")) {
+		t.Errorf("HasImplicitDefault() = false for %q", stmts)
+	}
+}
+
+func TestInitHook(t *testing.T) {
+	stmts := InsertInitHook(GetPackageInsertStmts())
+	idx := slices.Index(stmts, TrackStmtPlaceHolder)
+	if idx < 1 || stmts[idx-1] != InitHookStmt || stmts[idx+1] != "}" {
+		t.Errorf("InsertInitHook() = %q, want the track statement wrapped in an init function", stmts)
+	}
+	if HasInitHook(GetPackageInsertDataString()) {
+		t.Errorf("HasInitHook() = true for the default insert data")
+	}
+	if !HasInitHook(strings.Join(stmts, "\n")) {
+		t.Errorf("HasInitHook() = false for %q", stmts)
+	}
+}
+
+func TestIncreamentReplaceHookStmt(t *testing.T) {
+	content := strings.Join([]string{
+		TrackStmtPlaceHolder,
+		strings.Join(InsertInitHook([]string{TrackStmtPlaceHolder}), "\n\t"),
+		TrackStmtPlaceHolder,
+	}, "\n")
+	want := strings.Join([]string{
+		"defer goat.TrackTime(goat.TRACK_ID_5)()",
+		InitHookStmt + "\n\tgoat.Track(goat.TRACK_ID_6)\n\t}",
+		"defer goat.TrackTime(goat.TRACK_ID_7)()",
+	}, "\n")
+	got := TrackStmtRegexp.ReplaceAllStringFunc(content, IncreamentReplaceHookStmt(IncreamentReplaceTimedStmt)("goat", 5))
+	if got != want {
+		t.Errorf("IncreamentReplaceHookStmt() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,54 @@
+package increment
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// UnwrapTrackConds replaces the numbered "goat.TrackCond(goat.TRACK_ID_n, goat.TRACK_ID_m, operand)" calls
+// in the content with their operands, it returns the number of unwrapped calls and the new content
+func UnwrapTrackConds(content string) (int, string, error) {
+	count := 0
+	// the operands of the outermost calls may wrap other calls, e.g. in a function literal
+	for {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "", content, parser.ParseComments|parser.SkipObjectResolution)
+		if err != nil {
+			return 0, "", fmt.Errorf("failed to parse content: %w", err)
+		}
+		var calls []*ast.CallExpr
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if ok && isTrackCondCall(call) {
+				calls = append(calls, call)
+				return false
+			}
+			return true
+		})
+		if len(calls) == 0 {
+			return count, content, nil
+		}
+		file := fset.File(f.Pos())
+		var buf strings.Builder
+		buf.Grow(len(content))
+		last := 0
+		for _, call := range calls {
+			operand := call.Args[2]
+			buf.WriteString(content[last:file.Offset(call.Pos())])
+			buf.WriteString(content[file.Offset(operand.Pos()):file.Offset(operand.End())])
+			last = file.Offset(call.End())
+		}
+		buf.WriteString(content[last:])
+		content = buf.String()
+		count += len(calls)
+	}
+}
+
+// isTrackCondCall checks if the call is a numbered "goat.TrackCond(goat.TRACK_ID_n, goat.TRACK_ID_m, operand)" call
+func isTrackCondCall(call *ast.CallExpr) bool {
+	fun, ok := call.Fun.(*ast.SelectorExpr)
+	return ok && fun.Sel.Name == "TrackCond" && len(trackIdsOfCall(call)) == 2
+}
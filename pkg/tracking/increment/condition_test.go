@@ -0,0 +1,83 @@
+package increment
+
+import "testing"
+
+func TestUnwrapTrackConds(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		want    string
+		count   int
+	}{
+		{
+			name: "wrapped operands",
+			content: `package main
+
+func check(a, b, c bool) bool {
+	if goat.TrackCond(goat.TRACK_ID_1, goat.TRACK_ID_2, a) && (goat.TrackCond(goat.TRACK_ID_3, goat.TRACK_ID_4, b) || c) {
+		return true
+	}
+	return false
+}
+`,
+			want: `package main
+
+func check(a, b, c bool) bool {
+	if a && (b || c) {
+		return true
+	}
+	return false
+}
+`,
+			count: 2,
+		},
+		{
+			name: "nested in a function literal operand",
+			content: `package main
+
+func check(a, b bool) bool {
+	return goat.TrackCond(goat.TRACK_ID_1, goat.TRACK_ID_2, func() bool {
+		return goat.TrackCond(goat.TRACK_ID_3, goat.TRACK_ID_4, a) || b
+	}())
+}
+`,
+			want: `package main
+
+func check(a, b bool) bool {
+	return func() bool {
+		return a || b
+	}()
+}
+`,
+			count: 2,
+		},
+		{
+			name: "other calls kept",
+			content: `package main
+
+func check(a bool) bool {
+	return other.TrackCond(ID, ID, a)
+}
+`,
+			want: `package main
+
+func check(a bool) bool {
+	return other.TrackCond(ID, ID, a)
+}
+`,
+			count: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			count, got, err := UnwrapTrackConds(tc.content)
+			if err != nil {
+				t.Fatalf("UnwrapTrackConds() error = %v", err)
+			}
+			if count != tc.count || got != tc.want {
+				t.Errorf("UnwrapTrackConds() = %d, %q, want %d, %q", count, got, tc.count, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package increment
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// Position is the location of a track point in the source code
+type Position struct {
+	// File is the file path relative to the project root
+	File string
+	// Package is the import path of the package
+	Package string
+	// Func is the enclosing function, "Type.Method" for methods, "var x, y" for the initializers
+	// of package-level variables and their init hooks, empty elsewhere at package level
+	Func string
+	// Line is the line of the track statement
+	Line int
+}
+
+// TrackPositionsOf returns the positions of the numbered track points in the content
+// of the file of the package, the result is the map of the track ID to the position
+func TrackPositionsOf(filename string, pkgPath string, content string) map[int]Position {
+	result := make(map[int]Position)
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, content, parser.SkipObjectResolution)
+	if err != nil {
+		return result
+	}
+	record := func(funcName string, node ast.Node) {
+		ast.Inspect(node, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			for _, id := range trackIdsOfCall(call) {
+				result[id] = Position{
+					File:    filename,
+					Package: pkgPath,
+					Func:    funcName,
+					Line:    fset.Position(call.Pos()).Line,
+				}
+			}
+			return true
+		})
+	}
+	// vars is the initialized variables of the previous declaration, which an init hook follows
+	vars := ""
+	for _, decl := range f.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if vars != "" && isInitHook(decl) {
+				record(vars, decl)
+			} else {
+				record(funcNameOf(decl), decl)
+			}
+			vars = ""
+		case *ast.GenDecl:
+			vars = ""
+			if decl.Tok == token.VAR {
+				vars = varsNameOf(decl.Specs)
+			}
+			for _, spec := range decl.Specs {
+				name := ""
+				if decl.Tok == token.VAR {
+					name = varsNameOf([]ast.Spec{spec})
+				}
+				record(name, spec)
+			}
+		default:
+			record("", decl)
+			vars = ""
+		}
+	}
+	return result
+}
+
+// varsNameOf returns "var x, y" for the initialized variables of the specs of a var declaration, empty if none
+func varsNameOf(specs []ast.Spec) string {
+	var names []string
+	for _, spec := range specs {
+		spec, ok := spec.(*ast.ValueSpec)
+		if !ok || len(spec.Values) == 0 {
+			continue
+		}
+		for _, name := range spec.Names {
+			names = append(names, name.Name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	return "var " + strings.Join(names, ", ")
+}
+
+// InitHookTrackIdsOf returns the track IDs of the init hooks in the content of the file
+func InitHookTrackIdsOf(filename string, content string) []int {
+	f, err := parser.ParseFile(token.NewFileSet(), filename, content, parser.SkipObjectResolution)
+	if err != nil {
+		return nil
+	}
+	var ids []int
+	for i, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || i == 0 || !isInitHook(fn) {
+			continue
+		}
+		// an init hook follows the declaration of the initialized variables
+		if prev, ok := f.Decls[i-1].(*ast.GenDecl); ok && prev.Tok == token.VAR && varsNameOf(prev.Specs) != "" {
+			ids = append(ids, trackIdsOfCall(fn.Body.List[0].(*ast.ExprStmt).X.(*ast.CallExpr))...)
+		}
+	}
+	return ids
+}
+
+// isInitHook checks if the function is an init hook, an init function holding only a track statement
+func isInitHook(fn *ast.FuncDecl) bool {
+	if fn.Recv != nil || fn.Name.Name != "init" || fn.Body == nil || len(fn.Body.List) != 1 {
+		return false
+	}
+	stmt, ok := fn.Body.List[0].(*ast.ExprStmt)
+	if !ok {
+		return false
+	}
+	call, ok := stmt.X.(*ast.CallExpr)
+	return ok && len(trackIdsOfCall(call)) > 0
+}
+
+// trackIdsOfCall returns the track ID of a "goat.Track(goat.TRACK_ID_n)" or "goat.TrackTime(goat.TRACK_ID_n)" call,
+// or the true and false track IDs of a "goat.TrackCond(goat.TRACK_ID_n, goat.TRACK_ID_m, operand)" call
+func trackIdsOfCall(call *ast.CallExpr) []int {
+	fun, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return nil
+	}
+	var args []ast.Expr
+	switch {
+	case (fun.Sel.Name == "Track" || fun.Sel.Name == "TrackTime") && len(call.Args) == 1:
+		args = call.Args
+	case fun.Sel.Name == "TrackCond" && len(call.Args) == 3:
+		args = call.Args[:2]
+	default:
+		return nil
+	}
+	ids := make([]int, 0, len(args))
+	for _, arg := range args {
+		id, ok := trackIdOfArg(arg)
+		if !ok {
+			return nil
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// trackIdOfArg returns the track ID of a "goat.TRACK_ID_n" argument
+func trackIdOfArg(expr ast.Expr) (int, bool) {
+	arg, ok := expr.(*ast.SelectorExpr)
+	if !ok || !strings.HasPrefix(arg.Sel.Name, "TRACK_ID_") {
+		return 0, false
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(arg.Sel.Name, "TRACK_ID_"))
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
+
+// funcNameOf returns the name of the function, "Type.Method" for methods
+func funcNameOf(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+	typ := fn.Recv.List[0].Type
+	for {
+		switch t := typ.(type) {
+		case *ast.StarExpr:
+			typ = t.X
+			continue
+		case *ast.IndexExpr:
+			typ = t.X
+			continue
+		case *ast.IndexListExpr:
+			typ = t.X
+			continue
+		case *ast.Ident:
+			return t.Name + "." + fn.Name.Name
+		}
+		return fn.Name.Name
+	}
+}
@@ -0,0 +1,104 @@
+package increment
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTrackPositionsOf(t *testing.T) {
+	content := `package pay
+
+import goat "example.com/app/goat"
+
+var ready = func() bool {
+	goat.Track(goat.TRACK_ID_1)
+	return true
+}()
+
+func Refund() {
+	goat.Track(goat.TRACK_ID_2)
+	go func() {
+		goat.Track(goat.TRACK_ID_3)
+	}()
+}
+
+func (s *Service[T]) Charge() {
+	goat.Track(goat.TRACK_ID_4)
+	other.Track(ID)
+}
+
+func Settle() {
+	defer goat.TrackTime(goat.TRACK_ID_5)()
+}
+
+func Check(a, b bool) bool {
+	return goat.TrackCond(goat.TRACK_ID_6, goat.TRACK_ID_7, a) && b
+}
+
+var (
+	rates, fees = load()
+	limit       int
+)
+
+func init() {
+	goat.Track(goat.TRACK_ID_8)
+}
+
+func init() {
+	goat.Track(goat.TRACK_ID_9)
+}
+`
+	got := TrackPositionsOf("pkg/pay/pay.go", "example.com/app/pkg/pay", content)
+	want := map[int]Position{
+		1: {File: "pkg/pay/pay.go", Package: "example.com/app/pkg/pay", Func: "var ready", Line: 6},
+		2: {File: "pkg/pay/pay.go", Package: "example.com/app/pkg/pay", Func: "Refund", Line: 11},
+		3: {File: "pkg/pay/pay.go", Package: "example.com/app/pkg/pay", Func: "Refund", Line: 13},
+		4: {File: "pkg/pay/pay.go", Package: "example.com/app/pkg/pay", Func: "Service.Charge", Line: 18},
+		5: {File: "pkg/pay/pay.go", Package: "example.com/app/pkg/pay", Func: "Settle", Line: 23},
+		6: {File: "pkg/pay/pay.go", Package: "example.com/app/pkg/pay", Func: "Check", Line: 27},
+		7: {File: "pkg/pay/pay.go", Package: "example.com/app/pkg/pay", Func: "Check", Line: 27},
+		8: {File: "pkg/pay/pay.go", Package: "example.com/app/pkg/pay", Func: "var rates, fees", Line: 36},
+		9: {File: "pkg/pay/pay.go", Package: "example.com/app/pkg/pay", Func: "init", Line: 40},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TrackPositionsOf() = %+v, want %+v", got, want)
+	}
+	if got := TrackPositionsOf("bad.go", "x", "package"); len(got) != 0 {
+		t.Errorf("TrackPositionsOf() of invalid content = %+v, want empty", got)
+	}
+}
+
+func TestInitHookTrackIdsOf(t *testing.T) {
+	content := `package pay
+
+import goat "example.com/app/goat"
+
+func init() {
+	goat.Track(goat.TRACK_ID_1)
+}
+
+var rates = load()
+
+func init() {
+	goat.Track(goat.TRACK_ID_2)
+}
+
+var limit int
+
+func init() {
+	goat.Track(goat.TRACK_ID_3)
+}
+
+var fees = load()
+
+func init() {
+	defer goat.TrackTime(goat.TRACK_ID_4)()
+}
+`
+	if got := InitHookTrackIdsOf("pkg/pay/pay.go", content); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("InitHookTrackIdsOf() = %v, want [2]", got)
+	}
+	if got := InitHookTrackIdsOf("bad.go", "package"); len(got) != 0 {
+		t.Errorf("InitHookTrackIdsOf() of invalid content = %v, want empty", got)
+	}
+}
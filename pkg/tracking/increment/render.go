@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"text/template"
 
 	"github.com/monshunter/goat/pkg/config"
@@ -18,6 +19,24 @@ type Values struct {
 	TrackIds    []int
 	Race        bool
 	DataType    int
+	// TrackIdDataTypes is the data types of the track IDs which differ from DataType
+	TrackIdDataTypes map[int]int
+	// TrackIdAttrs is the names and tags of the track IDs
+	TrackIdAttrs map[int]Attrs
+	// TrackIdPositions is the source positions of the track IDs
+	TrackIdPositions map[int]Position
+	// BuildTag is the build tag guarding the runtime, empty means always compiled in
+	BuildTag string
+	// CounterLayout is the memory layout of the counters: packed, padded or sharded
+	CounterLayout string
+	// CounterBits is the width of the counters: 32 or 64
+	CounterBits int
+	// Timestamps records the first-hit and last-hit times of the track IDs
+	Timestamps bool
+	// Commit is the short commit hash of the instrumented source
+	Commit string
+	// Granularity is the granularity of the instrumentation
+	Granularity string
 }
 
 type Component struct {
@@ -29,16 +48,52 @@ type Component struct {
 // NewValues creates a new Values instance
 func NewValues(cfg *config.Config) *Values {
 	return &Values{
-		PackageName: cfg.GoatPackageName,
-		Version:     cfg.AppVersion,
-		Name:        cfg.AppName,
-		Components:  make([]Component, 0),
-		TrackIds:    make([]int, 0),
-		Race:        cfg.Race,
-		DataType:    cfg.GetDataType().Int(),
+		PackageName:   cfg.GoatPackageName,
+		Version:       cfg.AppVersion,
+		Name:          cfg.AppName,
+		Components:    make([]Component, 0),
+		TrackIds:      make([]int, 0),
+		Race:          cfg.Race,
+		DataType:      cfg.GetDataType().Int(),
+		BuildTag:      cfg.BuildTag,
+		Timestamps:    cfg.Timestamps,
+		CounterLayout: cfg.CounterLayout,
+		CounterBits:   cfg.CounterBits,
+		Commit:        cfg.Commit(),
+		Granularity:   cfg.Granularity,
 	}
 }
 
+// Layout returns the memory layout of the counters, packed by default
+func (v *Values) Layout() string {
+	if v.CounterLayout == "" {
+		return config.CounterLayoutPacked
+	}
+	return v.CounterLayout
+}
+
+// Bits returns the width of the counters, 32 by default
+func (v *Values) Bits() int {
+	if v.CounterBits == 64 {
+		return 64
+	}
+	return 32
+}
+
+// HasAverage checks if any track ID is of the average data type
+func (v *Values) HasAverage() bool {
+	average := config.DataTypeAverage.Int()
+	if v.DataType == average {
+		return true
+	}
+	for _, dataType := range v.TrackIdDataTypes {
+		if dataType == average {
+			return true
+		}
+	}
+	return false
+}
+
 // AddComponent adds a component to the Values
 func (v *Values) AddComponent(id int, name string, trackIds []int) {
 	v.Components = append(v.Components, Component{
@@ -58,6 +113,48 @@ func (v *Values) AddTrackIds(ids []int) {
 	v.TrackIds = append(v.TrackIds, ids...)
 }
 
+// SetTrackIdDataType overrides the data type of the track ID
+func (v *Values) SetTrackIdDataType(id int, dataType int) {
+	if dataType == v.DataType {
+		delete(v.TrackIdDataTypes, id)
+		return
+	}
+	if v.TrackIdDataTypes == nil {
+		v.TrackIdDataTypes = make(map[int]int)
+	}
+	v.TrackIdDataTypes[id] = dataType
+}
+
+// SetTrackIdAttrs sets the name and tags of the track ID
+func (v *Values) SetTrackIdAttrs(id int, attrs Attrs) {
+	if attrs.IsEmpty() {
+		delete(v.TrackIdAttrs, id)
+		return
+	}
+	if v.TrackIdAttrs == nil {
+		v.TrackIdAttrs = make(map[int]Attrs)
+	}
+	v.TrackIdAttrs[id] = Attrs{Name: attrs.Name, Tags: slices.Clone(attrs.Tags)}
+}
+
+// SetTrackIdPosition sets the source position of the track ID
+func (v *Values) SetTrackIdPosition(id int, position Position) {
+	if v.TrackIdPositions == nil {
+		v.TrackIdPositions = make(map[int]Position)
+	}
+	v.TrackIdPositions[id] = position
+}
+
+// Tags returns the sorted unique tags of the track IDs
+func (v *Values) Tags() []string {
+	tags := make([]string, 0)
+	for _, attrs := range v.TrackIdAttrs {
+		tags = append(tags, attrs.Tags...)
+	}
+	slices.Sort(tags)
+	return slices.Compact(tags)
+}
+
 // Validate validates the parameters of the Values
 func (v *Values) Validate() error {
 	if v.PackageName == "" {
@@ -121,22 +218,56 @@ func (v *Values) MergeValues(other *Values) {
 			v.TrackIds = append(v.TrackIds, id)
 		}
 	}
+
+	// Merge TrackIdDataTypes
+	for id, dataType := range other.TrackIdDataTypes {
+		v.SetTrackIdDataType(id, dataType)
+	}
+
+	// Merge TrackIdAttrs
+	for id, attrs := range other.TrackIdAttrs {
+		v.SetTrackIdAttrs(id, attrs)
+	}
+
+	// Merge TrackIdPositions
+	for id, position := range other.TrackIdPositions {
+		v.SetTrackIdPosition(id, position)
+	}
 }
 
 // Clone creates a deep copy of the Values
 func (v *Values) Clone() *Values {
 	newValues := &Values{
-		PackageName: v.PackageName,
-		Version:     v.Version,
-		Name:        v.Name,
-		Race:        v.Race,
-		TrackIds:    make([]int, len(v.TrackIds)),
-		Components:  make([]Component, len(v.Components)),
+		PackageName:   v.PackageName,
+		Version:       v.Version,
+		Name:          v.Name,
+		Race:          v.Race,
+		DataType:      v.DataType,
+		BuildTag:      v.BuildTag,
+		Timestamps:    v.Timestamps,
+		CounterLayout: v.CounterLayout,
+		CounterBits:   v.CounterBits,
+		Commit:        v.Commit,
+		Granularity:   v.Granularity,
+		TrackIds:      make([]int, len(v.TrackIds)),
+		Components:    make([]Component, len(v.Components)),
 	}
 
 	// 复制TrackIds
 	copy(newValues.TrackIds, v.TrackIds)
 
+	for id, dataType := range v.TrackIdDataTypes {
+		newValues.SetTrackIdDataType(id, dataType)
+	}
+
+	for id, attrs := range v.TrackIdAttrs {
+		newValues.SetTrackIdAttrs(id, attrs)
+	}
+
+	for id, position := range v.TrackIdPositions {
+		newValues.SetTrackIdPosition(id, position)
+	}
+
 	// Deep copy Components
 	for i, comp := range v.Components {
 		newValues.Components[i] = Component{
@@ -163,6 +294,14 @@ func (v *Values) Render() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// RenderStub renders the no-op stub of the Values, which is compiled in without the build tag
+func (v *Values) RenderStub() ([]byte, error) {
+	if v.BuildTag == "" {
+		return nil, fmt.Errorf("build tag is required to render the stub")
+	}
+	return v.RenderWithCustomTemplate(StubTemplate)
+}
+
 // RenderWithCustomTemplate renders data with a custom template
 func (v *Values) RenderWithCustomTemplate(customTemplate string) ([]byte, error) {
 	tmpl, err := template.New("custom").Parse(customTemplate)
@@ -196,6 +335,33 @@ func (v *Values) Save(outputPath string) error {
 	return os.WriteFile(outputPath, data, 0644)
 }
 
+// SaveStub saves the rendered stub to a file,
+// the stale stub is removed if the build tag is not set
+func (v *Values) SaveStub(outputPath string) error {
+	if v.BuildTag == "" {
+		if err := os.Remove(outputPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := v.Validate(); err != nil {
+		return err
+	}
+
+	data, err := v.RenderStub()
+	if err != nil {
+		return err
+	}
+
+	// Ensure the directory exists
+	dir := filepath.Dir(outputPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(outputPath, data, 0644)
+}
+
 // Remove removes the file
 func (v *Values) Remove(outputPath string) error {
 	return os.Remove(outputPath)
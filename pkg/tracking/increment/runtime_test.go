@@ -0,0 +1,1720 @@
+package increment
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// runRuntimeTest renders the values into a temporary module and runs the test source
+// against the generated package, extra arguments are passed to "go test", the output is returned
+func runRuntimeTest(t testing.TB, values *Values, testSource string, args ...string) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping runtime test in short mode")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	dir := t.TempDir()
+	pkgDir := filepath.Join(dir, values.PackageName)
+	if err := values.Save(filepath.Join(pkgDir, "goat_generated.go")); err != nil {
+		t.Fatalf("Failed to save generated file: %v", err)
+	}
+	if err := values.SaveStub(filepath.Join(pkgDir, "goat_generated_stub.go")); err != nil {
+		t.Fatalf("Failed to save generated stub file: %v", err)
+	}
+	files := map[string]string{
+		filepath.Join(dir, "go.mod"):                  "module goattest\n\ngo 1.21\n",
+		filepath.Join(pkgDir, "goat_runtime_test.go"): testSource,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	cmd := exec.Command(goBin, append(append([]string{"test", "-count=1"}, args...), "./...")...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOWORK=off", "GOFLAGS=")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		data, _ := os.ReadFile(filepath.Join(pkgDir, "goat_generated.go"))
+		t.Fatalf("Runtime test failed: %v\n%s\ngenerated code:\n%s", err, output, data)
+	}
+	return string(output)
+}
+
+// newRuntimeValues returns the values of a runtime with two components
+func newRuntimeValues(race bool, dataType int) *Values {
+	return &Values{
+		PackageName: "goat",
+		Version:     "1.0.0",
+		Name:        "runtime",
+		TrackIds:    []int{1, 2, 3},
+		Components: []Component{
+			{ID: 0, Name: "server", TrackIds: []int{1, 2}},
+			{ID: 1, Name: "worker", TrackIds: []int{2, 3}},
+		},
+		Race:     race,
+		DataType: dataType,
+	}
+}
+
+func TestRuntimeServer(t *testing.T) {
+	runRuntimeTest(t, newRuntimeValues(true, 1), `package goat
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	Track(TRACK_ID_2)
+	snapshot := Snapshot()
+	if snapshot.Name != NAME || len(snapshot.Results) != 2 {
+		t.Fatalf("unexpected snapshot: %+v", snapshot)
+	}
+	for _, result := range snapshot.Results {
+		if result.Metrics.Total != 2 || result.Metrics.Covered != 1 {
+			t.Errorf("component %s: total=%d covered=%d, want 2 and 1",
+				result.Name, result.Metrics.Total, result.Metrics.Covered)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	server := httptest.NewServer(http.StripPrefix("/goat", Handler()))
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/goat/track?component=worker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var results Results
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Results) != 1 || results.Results[0].Name != "worker" {
+		t.Errorf("unexpected results: %+v", results)
+	}
+	resp, err = http.Get(server.URL + "/goat/track?component=unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status of an unknown component = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestStartShutdown(t *testing.T) {
+	if err := Start(Options{Disabled: true}); err != nil || Addr() != "" {
+		t.Fatalf("disabled Start() = %v, addr %q, want no listener", err, Addr())
+	}
+	// repeated calls fail until Shutdown, even when listening is disabled
+	if err := Start(Options{Disabled: true}); err == nil {
+		t.Errorf("second disabled Start() error = nil, want already started")
+	}
+	if err := Start(Options{Addr: "127.0.0.1:0"}); err == nil || Addr() != "" {
+		t.Errorf("Start() after a disabled Start() = %v, addr %q, want already started", err, Addr())
+	}
+	if err := Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := Start(Options{Addr: "127.0.0.1:0"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Start(Options{Addr: "127.0.0.1:0"}); err == nil {
+		t.Errorf("second Start() error = nil, want already started")
+	}
+	resp, err := http.Get("http://" + Addr() + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status of /metrics = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if err := Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if Addr() != "" {
+		t.Errorf("Addr() after Shutdown = %q, want empty", Addr())
+	}
+}
+
+func TestPortFallback(t *testing.T) {
+	occupied, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer occupied.Close()
+	_, port, _ := net.SplitHostPort(occupied.Addr().String())
+
+	// without fallback the clash is reported instead of exiting the process
+	if err := Start(Options{Addr: occupied.Addr().String()}); err == nil {
+		Shutdown(context.Background())
+		t.Fatalf("Start() on an occupied port error = nil, want error")
+	}
+	t.Setenv("GOAT_PORT", port)
+	ServeHTTP(COMPONENT_0)
+	if Addr() != "" {
+		Shutdown(context.Background())
+		t.Fatalf("ServeHTTP() on an occupied port listens on %s", Addr())
+	}
+
+	t.Setenv("GOAT_PORT_FALLBACK", "5")
+	if err := Start(DefaultOptions()); err != nil {
+		t.Fatal(err)
+	}
+	defer Shutdown(context.Background())
+	_, fallbackPort, _ := net.SplitHostPort(Addr())
+	want, _ := strconv.Atoi(port)
+	got, _ := strconv.Atoi(fallbackPort)
+	if got <= want || got > want+5 {
+		t.Errorf("fallback port = %d, want in (%d, %d]", got, want, want+5)
+	}
+}
+
+func TestDefaultOptions(t *testing.T) {
+	t.Setenv("GOAT_METRICS_IP", "")
+	t.Setenv("GOAT_PORT", "")
+	if opts := DefaultOptions(); opts.Addr != "127.0.0.1:57005" || opts.Disabled {
+		t.Errorf("DefaultOptions() = %+v, want 127.0.0.1:57005", opts)
+	}
+	t.Setenv("GOAT_METRICS_IP", "0.0.0.0")
+	t.Setenv("GOAT_PORT", "8080")
+	t.Setenv("GOAT_DISABLE_LISTEN", "true")
+	if opts := DefaultOptions(); opts.Addr != "0.0.0.0:8080" || !opts.Disabled || opts.PortFallback != 0 {
+		t.Errorf("DefaultOptions() = %+v", opts)
+	}
+}
+`)
+}
+
+// exportedUsesOf returns the statements using each exported identifier of the generated runtime
+// from another package, the generic functions being instantiated with bool
+func exportedUsesOf(t *testing.T, values *Values) []string {
+	t.Helper()
+	data, err := values.Render()
+	if err != nil {
+		t.Fatalf("Failed to render template: %v", err)
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), "goat_generated.go", data, parser.SkipObjectResolution)
+	if err != nil {
+		t.Fatalf("Failed to parse the generated code: %v", err)
+	}
+	var uses []string
+	for _, decl := range f.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Recv != nil || !decl.Name.IsExported() {
+				continue
+			}
+			if decl.Type.TypeParams != nil {
+				uses = append(uses, "_ = goat."+decl.Name.Name+"[bool]")
+			} else {
+				uses = append(uses, "_ = goat."+decl.Name.Name)
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					if spec.Name.IsExported() {
+						uses = append(uses, "var _ goat."+spec.Name.Name)
+					}
+				case *ast.ValueSpec:
+					for _, name := range spec.Names {
+						if name.IsExported() {
+							uses = append(uses, "_ = goat."+name.Name)
+						}
+					}
+				}
+			}
+		}
+	}
+	return uses
+}
+
+func TestRuntimeExportedIdentifiers(t *testing.T) {
+	values := newRuntimeValues(false, 3)
+	values.BuildTag = "goat"
+	uses := exportedUsesOf(t, values)
+	for _, name := range []string{"goat.COMPONENT_TRACK_IDS", "goat.COMPONENT_1_TRACK_IDS", "goat.TrackCond[bool]", "goat.TrackTime"} {
+		if !strings.Contains(strings.Join(uses, "\n"), name) {
+			t.Fatalf("exported identifiers %q miss %s", uses, name)
+		}
+	}
+	source := "package goat_test\n\nimport (\n\t\"testing\"\n\n\t\"goattest/goat\"\n)\n\n" +
+		"func TestExported(t *testing.T) {\n\t" + strings.Join(uses, "\n\t") + "\n}\n"
+	for _, args := range [][]string{nil, {"-tags", "goat"}} {
+		t.Run(fmt.Sprintf("tags=%v", args), func(t *testing.T) {
+			runRuntimeTest(t, values, source, args...)
+		})
+	}
+}
+
+func TestRuntimeBuildTag(t *testing.T) {
+	values := newRuntimeValues(false, 2)
+	values.BuildTag = "goat"
+	t.Run("stub", func(t *testing.T) {
+		runRuntimeTest(t, values, `package goat
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStub(t *testing.T) {
+	Track(TRACK_ID_1)
+	TrackNamed("checkout")
+	ServeHTTP(COMPONENT_1)
+	if err := Start(DefaultOptions()); err != nil || Addr() != "" {
+		t.Errorf("Start() = %v, addr %q, want a no-op", err, Addr())
+	}
+	if snapshot := Snapshot(); len(snapshot.Results) != 0 || snapshot.User != nil {
+		t.Errorf("Snapshot() = %+v, want empty", snapshot)
+	}
+	if err := Shutdown(context.Background()); err != nil {
+		t.Error(err)
+	}
+	if GetComponentName(COMPONENT_1) != "worker" {
+		t.Errorf("GetComponentName() = %q, want worker", GetComponentName(COMPONENT_1))
+	}
+}
+`)
+	})
+	t.Run("runtime", func(t *testing.T) {
+		runRuntimeTest(t, values, `package goat
+
+import "testing"
+
+func TestRuntime(t *testing.T) {
+	Track(TRACK_ID_1)
+	Track(TRACK_ID_1)
+	if items := Snapshot().Results[0].Metrics.Items; items[0].Count != 2 {
+		t.Errorf("count of TRACK_ID_1 = %d, want 2", items[0].Count)
+	}
+}
+`, "-tags", "goat")
+	})
+}
+
+func TestRuntimeSecureListeners(t *testing.T) {
+	runRuntimeTest(t, newRuntimeValues(true, 1), `package goat
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeSelfSignedCert writes a self-signed certificate usable by both servers and clients
+func writeSelfSignedCert(t *testing.T, dir string) (certFile, keyFile string, cert tls.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "goat"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cert, err = tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile, cert
+}
+
+func getStatus(t *testing.T, client *http.Client, url string, token string) int {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestUnixSocketWithToken(t *testing.T) {
+	dir, err := os.MkdirTemp("", "goat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "goat.sock")
+	t.Setenv("GOAT_UNIX_SOCKET", socket)
+	t.Setenv("GOAT_TOKEN", "secret")
+	if err := Start(DefaultOptions()); err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	if status := getStatus(t, client, "http://goat/track", ""); status != http.StatusUnauthorized {
+		t.Errorf("status without token = %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status := getStatus(t, client, "http://goat/track", "wrong"); status != http.StatusUnauthorized {
+		t.Errorf("status with a wrong token = %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status := getStatus(t, client, "http://goat/metrics", "secret"); status != http.StatusOK {
+		t.Errorf("status with the token = %d, want %d", status, http.StatusOK)
+	}
+	// the socket of a live service is not removed
+	if listener, err := listenUnix(socket); err == nil {
+		listener.Close()
+		t.Errorf("listenUnix() on a live socket error = nil, want in use")
+	}
+	if status := getStatus(t, client, "http://goat/metrics", "secret"); status != http.StatusOK {
+		t.Errorf("status after listening on the live socket = %d, want %d", status, http.StatusOK)
+	}
+	if err := Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// the socket can be reused after shutdown
+	if err := Start(Options{UnixSocket: socket}); err != nil {
+		t.Fatal(err)
+	}
+	Shutdown(context.Background())
+	// the stale socket file of a service that didn't shut down is removed
+	staleSocket := filepath.Join(dir, "stale.sock")
+	stale, err := net.Listen("unix", staleSocket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	if err := Start(Options{UnixSocket: staleSocket}); err != nil {
+		t.Fatalf("Start() on a stale socket error = %v", err)
+	}
+	Shutdown(context.Background())
+}
+
+func TestTLSWithClientCert(t *testing.T) {
+	certFile, keyFile, cert := writeSelfSignedCert(t, t.TempDir())
+	if err := Start(Options{Addr: "127.0.0.1:0", TLSCertFile: certFile}); err == nil {
+		Shutdown(context.Background())
+		t.Fatalf("Start() without a TLS key error = nil, want error")
+	}
+	if err := Start(Options{Addr: "127.0.0.1:0", TLSCertFile: certFile, TLSKeyFile: keyFile,
+		TLSClientCAFile: certFile}); err != nil {
+		t.Fatal(err)
+	}
+	defer Shutdown(context.Background())
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	url := "https://" + Addr() + "/track"
+	anonymous := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	if status := getStatus(t, anonymous, url, ""); status == http.StatusOK {
+		t.Errorf("request without a client certificate succeeded")
+	}
+	authenticated := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      pool,
+		Certificates: []tls.Certificate{cert},
+	}}}
+	if status := getStatus(t, authenticated, url, ""); status != http.StatusOK {
+		t.Errorf("status with a client certificate = %d, want %d", status, http.StatusOK)
+	}
+}
+`)
+}
+
+func TestRuntimeSessions(t *testing.T) {
+	runRuntimeTest(t, newRuntimeValues(true, 2), `package goat
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSessionDelta(t *testing.T) {
+	Track(TRACK_ID_1)
+	if err := StartSession("login"); err != nil {
+		t.Fatal(err)
+	}
+	Track(TRACK_ID_1)
+	Track(TRACK_ID_3)
+	Track(TRACK_ID_3)
+	running, err := SessionSnapshot("login")
+	if err != nil || !running.Running || running.StoppedAt != nil {
+		t.Fatalf("SessionSnapshot() = %+v, %v, want a running session", running, err)
+	}
+	if err := StopSession("login"); err != nil {
+		t.Fatal(err)
+	}
+	// hits after the stop are not attributed to the session
+	Track(TRACK_ID_2)
+	session, err := SessionSnapshot("login")
+	if err != nil || session.Running || session.StoppedAt == nil {
+		t.Fatalf("SessionSnapshot() = %+v, %v, want a stopped session", session, err)
+	}
+	hits := map[int]uint64{}
+	for _, result := range session.Results {
+		for _, item := range result.Metrics.Items {
+			hits[item.ID] = item.Count
+		}
+	}
+	if len(hits) != 2 || hits[TRACK_ID_1] != 1 || hits[TRACK_ID_3] != 2 {
+		t.Errorf("session hits = %v, want map[1:1 3:2]", hits)
+	}
+	if session.Results[0].Metrics.Covered != 1 || session.Results[0].Metrics.Total != 2 {
+		t.Errorf("server metrics = %+v, want 1 of 2 covered", session.Results[0].Metrics)
+	}
+	if err := StopSession("login"); err == nil {
+		t.Errorf("second StopSession() error = nil, want already stopped")
+	}
+	if err := StartSession("a/b"); err == nil {
+		t.Errorf("StartSession() with a slash error = nil, want error")
+	}
+}
+
+func TestSessionHandler(t *testing.T) {
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+	status := func(method, path string) int {
+		req, _ := http.NewRequest(method, server.URL+path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := status(http.MethodGet, "/session/e2e/start"); code != http.StatusMethodNotAllowed {
+		t.Errorf("GET start status = %d, want %d", code, http.StatusMethodNotAllowed)
+	}
+	if code := status(http.MethodPost, "/session/e2e/start"); code != http.StatusNoContent {
+		t.Errorf("POST start status = %d, want %d", code, http.StatusNoContent)
+	}
+	Track(TRACK_ID_2)
+	if code := status(http.MethodPost, "/session/e2e/stop"); code != http.StatusNoContent {
+		t.Errorf("POST stop status = %d, want %d", code, http.StatusNoContent)
+	}
+	if code := status(http.MethodGet, "/session/unknown"); code != http.StatusNotFound {
+		t.Errorf("unknown session status = %d, want %d", code, http.StatusNotFound)
+	}
+	resp, err := http.Get(server.URL + "/session/e2e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var session Session
+	if err := json.NewDecoder(resp.Body).Decode(&session); err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range session.Results {
+		if len(result.Metrics.Items) != 1 || result.Metrics.Items[0].ID != TRACK_ID_2 {
+			t.Errorf("component %s items = %+v, want only track ID 2", result.Name, result.Metrics.Items)
+		}
+	}
+	resp, err = http.Get(server.URL + "/session/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var names []string
+	if err := json.NewDecoder(resp.Body).Decode(&names); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, name := range names {
+		found = found || name == "e2e"
+	}
+	if !found {
+		t.Errorf("sessions = %v, want e2e listed", names)
+	}
+}
+`, "-race")
+}
+
+func TestRuntimeTrackCond(t *testing.T) {
+	values := newRuntimeValues(false, 2)
+	values.BuildTag = "goat"
+	source := `package goat
+
+import "testing"
+
+type flag bool
+
+func TestTrackCond(t *testing.T) {
+	if !TrackCond(TRACK_ID_1, TRACK_ID_2, true) {
+		t.Fatal("TrackCond() changed the true operand")
+	}
+	if TrackCond(TRACK_ID_1, TRACK_ID_2, flag(false)) {
+		t.Fatal("TrackCond() changed the false operand")
+	}
+	if results := Snapshot().Results; len(results) > 0 {
+		items := results[0].Metrics.Items
+		if items[0].Count != 1 || items[1].Count != 1 {
+			t.Errorf("counts of TRACK_ID_1 and TRACK_ID_2 = %d, %d, want 1, 1", items[0].Count, items[1].Count)
+		}
+	}
+}
+`
+	t.Run("stub", func(t *testing.T) {
+		runRuntimeTest(t, values, source)
+	})
+	t.Run("runtime", func(t *testing.T) {
+		runRuntimeTest(t, values, source, "-tags", "goat")
+	})
+}
+
+func TestRuntimeConditionalTrack(t *testing.T) {
+	runRuntimeTest(t, newRuntimeValues(true, 2), `package goat
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func get(t *testing.T, url string, etag string) (*http.Response, Results) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var results Results
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp, results
+}
+
+func TestETag(t *testing.T) {
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+	resp, _ := get(t, server.URL+"/track", "")
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		t.Fatalf("ETag header is empty")
+	}
+	if resp, _ := get(t, server.URL+"/track", etag); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("unchanged status = %d, want %d", resp.StatusCode, http.StatusNotModified)
+	}
+	Track(TRACK_ID_1)
+	if resp, _ := get(t, server.URL+"/track", etag); resp.StatusCode != http.StatusOK {
+		t.Errorf("changed status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestSince(t *testing.T) {
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+	_, results := get(t, server.URL+"/track?component=worker", "")
+	version := results.Results[0].Metrics.Version
+	Track(TRACK_ID_3)
+	_, results = get(t, server.URL+"/track?component=worker&since="+version, "")
+	metrics := results.Results[0].Metrics
+	if metrics.Since != version || len(metrics.Items) != 1 || metrics.Items[0].ID != TRACK_ID_3 {
+		t.Errorf("metrics since %s = %+v, want only track ID 3", version, metrics)
+	}
+	if metrics.Total != 2 {
+		t.Errorf("total = %d, want the total of all items", metrics.Total)
+	}
+	// an unknown version returns all items
+	_, results = get(t, server.URL+"/track?component=worker&since=unknown", "")
+	if metrics := results.Results[0].Metrics; metrics.Since != "" || len(metrics.Items) != 2 {
+		t.Errorf("metrics since an unknown version = %+v, want all items", metrics)
+	}
+}
+
+func TestReset(t *testing.T) {
+	post := func(url string) int {
+		resp, err := http.Post(url, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if err := Start(Options{Addr: "127.0.0.1:0"}); err != nil {
+		t.Fatal(err)
+	}
+	if code := post("http://" + Addr() + "/reset"); code != http.StatusForbidden {
+		t.Errorf("reset without a token status = %d, want %d", code, http.StatusForbidden)
+	}
+	Shutdown(context.Background())
+
+	if err := Start(Options{Addr: "127.0.0.1:0", AllowReset: true}); err != nil {
+		t.Fatal(err)
+	}
+	defer Shutdown(context.Background())
+	Track(TRACK_ID_1)
+	Track(TRACK_ID_3)
+	TrackNamed("checkout")
+	if code := post("http://" + Addr() + "/reset?component=server"); code != http.StatusNoContent {
+		t.Fatalf("reset status = %d, want %d", code, http.StatusNoContent)
+	}
+	if trackCount(TRACK_ID_1) != 0 || trackCount(TRACK_ID_3) == 0 {
+		t.Errorf("counts after resetting server = %d, %d, want 0 and non-zero", trackCount(TRACK_ID_1), trackCount(TRACK_ID_3))
+	}
+	if code := post("http://" + Addr() + "/reset"); code != http.StatusNoContent {
+		t.Fatalf("reset status = %d, want %d", code, http.StatusNoContent)
+	}
+	if trackCount(TRACK_ID_3) != 0 || userMetrics(2).Covered != 0 {
+		t.Errorf("counts after resetting all are not zero")
+	}
+	resp, err := http.Get("http://" + Addr() + "/reset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET reset status = %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
+`, "-race")
+}
+
+func TestRuntimeTrackQuery(t *testing.T) {
+	values := newRuntimeValues(false, 2)
+	values.SetTrackIdPosition(1, Position{File: "pkg/pay/pay.go", Package: "app/pkg/pay", Func: "Refund", Line: 10})
+	values.SetTrackIdPosition(2, Position{File: "pkg/pay/card/card.go", Package: "app/pkg/pay/card", Func: "Card.Charge", Line: 20})
+	values.SetTrackIdPosition(3, Position{File: "cmd/worker/main.go", Package: "app/cmd/worker", Func: "main", Line: 5})
+	values.SetTrackIdAttrs(3, Attrs{Tags: []string{"jobs"}})
+	runRuntimeTest(t, values, `package goat
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func query(t *testing.T, server *httptest.Server, rawQuery string) (int, Results, []byte) {
+	t.Helper()
+	resp, err := http.Get(server.URL + "/track?" + rawQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	var results Results
+	if resp.StatusCode == http.StatusOK {
+		if err := json.Unmarshal(data, &results); err != nil {
+			t.Fatalf("invalid JSON %s: %v", data, err)
+		}
+	}
+	return resp.StatusCode, results, data
+}
+
+func ids(results Results) []int {
+	var result []int
+	for _, component := range results.Results {
+		for _, item := range component.Metrics.Items {
+			result = append(result, item.ID)
+		}
+	}
+	return result
+}
+
+func TestFilters(t *testing.T) {
+	Track(TRACK_ID_1)
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+	testCases := []struct {
+		name    string
+		query   string
+		want    string
+		covered int
+		total   int
+	}{
+		{name: "file glob", query: "component=server&file=pkg/pay/*.go", want: "[1]", covered: 1, total: 1},
+		{name: "file doublestar", query: "component=server&file=pkg/**/*.go", want: "[1,2]", covered: 1, total: 2},
+		{name: "file directory", query: "component=server&file=pkg/pay/card", want: "[2]", covered: 0, total: 1},
+		{name: "package", query: "component=server&package=app/pkg/pay", want: "[1]", covered: 1, total: 1},
+		{name: "package tree", query: "component=server&package=app/pkg/pay/**", want: "[1,2]", covered: 1, total: 2},
+		{name: "function", query: "component=server&func=Card.*", want: "[2]", covered: 0, total: 1},
+		{name: "uncovered", query: "component=server&state=uncovered", want: "[2]", covered: 1, total: 2},
+		{name: "tag", query: "component=worker&tag=jobs", want: "[3]", covered: 0, total: 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, results, _ := query(t, server, tc.query+"&order=2")
+			if status != http.StatusOK {
+				t.Fatalf("status = %d", status)
+			}
+			if got, _ := json.Marshal(ids(results)); string(got) != tc.want {
+				t.Errorf("ids = %s, want %s", got, tc.want)
+			}
+			metrics := results.Results[0].Metrics
+			if metrics.Covered != tc.covered || metrics.Total != tc.total {
+				t.Errorf("covered/total = %d/%d, want %d/%d", metrics.Covered, metrics.Total, tc.covered, tc.total)
+			}
+		})
+	}
+	if status, _, _ := query(t, server, "state=unknown"); status != http.StatusBadRequest {
+		t.Errorf("invalid state status = %d, want %d", status, http.StatusBadRequest)
+	}
+	if status, _, _ := query(t, server, "file=[a"); status != http.StatusBadRequest {
+		t.Errorf("invalid glob status = %d, want %d", status, http.StatusBadRequest)
+	}
+}
+
+func TestFields(t *testing.T) {
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+	_, _, data := query(t, server, "component=server&fields=id,count")
+	var raw struct {
+		Results []struct {
+			Metrics struct {
+				Items []map[string]interface{} `+"`json:\"items\"`"+`
+			} `+"`json:\"metrics\"`"+`
+		} `+"`json:\"results\"`"+`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range raw.Results[0].Metrics.Items {
+		if len(item) != 2 || item["id"] == nil || item["count"] == nil {
+			t.Errorf("item = %v, want only id and count", item)
+		}
+	}
+	// counts above 2^53 keep their precision
+	selected := string(selectFields([]byte("{\"results\":[{\"metrics\":{\"items\":"+
+		"[{\"id\":1,\"name\":\"a\",\"count\":9007199254740993}]}}]}"), []string{"id", "count"}))
+	if want := "[{\"count\":9007199254740993,\"id\":1}]"; !strings.Contains(selected, want) {
+		t.Errorf("selectFields() = %s, want the items %s", selected, want)
+	}
+}
+
+func TestPagination(t *testing.T) {
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+	var got []int
+	cursor := ""
+	for page := 0; page < 10; page++ {
+		status, results, _ := query(t, server, "order=2&limit=3&cursor="+cursor)
+		if status != http.StatusOK {
+			t.Fatalf("status = %d", status)
+		}
+		if n := len(ids(results)); n > 3 {
+			t.Fatalf("page has %d items, want at most 3", n)
+		}
+		got = append(got, ids(results)...)
+		if results.NextCursor == "" {
+			break
+		}
+		cursor = results.NextCursor
+	}
+	if data, _ := json.Marshal(got); string(data) != "[1,2,2,3]" {
+		t.Errorf("paginated ids = %s, want [1,2,2,3]", data)
+	}
+	if status, _, _ := query(t, server, "limit=2&cursor=%21"); status != http.StatusBadRequest {
+		t.Errorf("invalid cursor status = %d, want %d", status, http.StatusBadRequest)
+	}
+}
+
+func TestPaginationWhileCounting(t *testing.T) {
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+	testCases := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{name: "count order", query: "limit=1", want: "[1,2,2,3]"},
+		{name: "count desc order", query: "order=1&limit=1", want: "[1,2,2,3]"},
+		{name: "id desc order", query: "order=3&limit=1", want: "[2,1,3,2]"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []int
+			cursor := ""
+			for page := 0; page < 10; page++ {
+				status, results, _ := query(t, server, tc.query+"&cursor="+cursor)
+				if status != http.StatusOK {
+					t.Fatalf("status = %d", status)
+				}
+				got = append(got, ids(results)...)
+				// the counts change between the pages
+				for _, id := range ids(results) {
+					for i := 0; i < 3; i++ {
+						Track(id)
+					}
+				}
+				if results.NextCursor == "" {
+					break
+				}
+				cursor = results.NextCursor
+			}
+			if data, _ := json.Marshal(got); string(data) != tc.want {
+				t.Errorf("paginated ids = %s, want %s", data, tc.want)
+			}
+		})
+	}
+}
+
+func TestGzip(t *testing.T) {
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/track", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", resp.Header.Get("Content-Encoding"))
+	}
+	reader, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results Results
+	if err := json.NewDecoder(reader).Decode(&results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Results) != 2 {
+		t.Errorf("results = %+v, want 2 components", results)
+	}
+}
+`)
+}
+
+func TestRuntimeRaceFreeSnapshot(t *testing.T) {
+	source := `package goat
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentSnapshots(t *testing.T) {
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := 0; ; id++ {
+				select {
+				case <-stop:
+					return
+				default:
+					Track(id%(TRACK_ID_END-1) + 1)
+					TrackNamed("worker")
+				}
+			}
+		}()
+	}
+	var readers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for j := 0; j < 10; j++ {
+				for _, path := range []string{"/track", "/track?since=x", "/metrics"} {
+					resp, err := http.Get(server.URL + path)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+				}
+				Snapshot()
+			}
+		}()
+	}
+	readers.Wait()
+	close(stop)
+	wg.Wait()
+}
+
+func TestMetricsOfCurrentComponent(t *testing.T) {
+	// the worker component has ID 1 while the metrics are computed for one component only
+	currentComponent = "worker"
+	defer func() { currentComponent = "" }()
+	Track(TRACK_ID_3)
+	recorder := httptest.NewRecorder()
+	metricsHandler(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := recorder.Body.String()
+	if !strings.Contains(body, "goat_track_total{app=\"runtime\",version=\"1.0.0\",component=\"worker\"} 2") {
+		t.Errorf("metrics of the worker component not found:\n%s", body)
+	}
+	if strings.Contains(body, "component=\"server\"") {
+		t.Errorf("metrics of the server component found:\n%s", body)
+	}
+}
+
+func TestMetricsLabelEscaping(t *testing.T) {
+	TrackNamed("pay \"refund\"\\\tcafé\n")
+	recorder := httptest.NewRecorder()
+	metricsHandler(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := recorder.Body.String()
+	if !strings.Contains(body, "name=\"pay \\\"refund\\\"\\\\\tcafé\\n\"} 1") {
+		t.Errorf("escaped name label not found:\n%s", body)
+	}
+}
+`
+	for _, dataType := range []int{1, 2} {
+		t.Run(fmt.Sprintf("dataType=%d", dataType), func(t *testing.T) {
+			runRuntimeTest(t, newRuntimeValues(true, dataType), source, "-race")
+		})
+	}
+}
+
+func TestRuntimeInitTracks(t *testing.T) {
+	runRuntimeTest(t, newRuntimeValues(true, 2), `package goat
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// tracked while the package is initialized, like the init hooks and package-level initializers
+var initialized = func() bool {
+	Track(TRACK_ID_1)
+	return true
+}()
+
+func init() {
+	Track(TRACK_ID_2)
+}
+
+func TestTracksBeforeStart(t *testing.T) {
+	if err := Start(Options{Addr: "127.0.0.1:0"}); err != nil {
+		t.Fatal(err)
+	}
+	defer Shutdown(context.Background())
+	resp, err := http.Get("http://" + Addr() + "/track?component=server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var results Results
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[int]uint64)
+	for _, result := range results.Results {
+		for _, item := range result.Metrics.Items {
+			counts[item.ID] = item.Count
+		}
+	}
+	if !initialized || counts[1] != 1 || counts[2] != 1 {
+		t.Errorf("counts = %v, want the tracks of the package initialization retained", counts)
+	}
+}
+`)
+}
+
+func TestRuntimeComponentScope(t *testing.T) {
+	runRuntimeTest(t, newRuntimeValues(true, 1), `package goat
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func fetch(t *testing.T, path string) []byte {
+	t.Helper()
+	resp, err := http.Get("http://" + Addr() + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return data
+}
+
+func TestServeHTTPScope(t *testing.T) {
+	t.Setenv("GOAT_METRICS_IP", "127.0.0.1")
+	t.Setenv("GOAT_PORT", "0")
+	ServeHTTP(COMPONENT_1)
+	defer Shutdown(context.Background())
+
+	metrics := string(fetch(t, "/metrics"))
+	if !strings.Contains(metrics, "component=\"worker\"") || strings.Contains(metrics, "component=\"server\"") {
+		t.Errorf("metrics are not scoped to the worker component:\n%s", metrics)
+	}
+	var results Results
+	if err := json.Unmarshal(fetch(t, "/track"), &results); err != nil {
+		t.Fatal(err)
+	}
+	if results.Component != "worker" || len(results.Results) != 1 || results.Results[0].Name != "worker" {
+		t.Errorf("track results = %+v, want only the worker component", results)
+	}
+	// an explicit component query is still served
+	if err := json.Unmarshal(fetch(t, "/track?component=server"), &results); err != nil {
+		t.Fatal(err)
+	}
+	if results.Component != "server" || len(results.Results) != 1 || results.Results[0].Name != "server" {
+		t.Errorf("track results = %+v, want the server component", results)
+	}
+	// the snapshots cover all components, whatever the scope
+	if snapshot := Snapshot(); snapshot.Component != "" || len(snapshot.Results) != 2 {
+		t.Errorf("snapshot = %+v, want all components without a component label", snapshot)
+	}
+	if err := StartSession("scope"); err != nil {
+		t.Fatal(err)
+	}
+	if session, err := SessionSnapshot("scope"); err != nil || session.Component != "" || len(session.Results) != 2 {
+		t.Errorf("session = %+v, %v, want all components without a component label", session, err)
+	}
+	StopSession("scope")
+
+	// GOAT_CURRENT_COMPONENT overrides the component of the main entry
+	currentComponent = "server"
+	defer func() { currentComponent = "" }()
+	metrics = string(fetch(t, "/metrics"))
+	if !strings.Contains(metrics, "component=\"server\"") || strings.Contains(metrics, "component=\"worker\"") {
+		t.Errorf("metrics are not scoped to the server component:\n%s", metrics)
+	}
+}
+
+func TestStartWithoutScope(t *testing.T) {
+	if err := Start(Options{Addr: "127.0.0.1:0"}); err != nil {
+		t.Fatal(err)
+	}
+	defer Shutdown(context.Background())
+	var results Results
+	if err := json.Unmarshal(fetch(t, "/track"), &results); err != nil {
+		t.Fatal(err)
+	}
+	if results.Component != "" || len(results.Results) != 2 {
+		t.Errorf("track results = %+v, want all components", results)
+	}
+}
+`)
+}
+
+func TestRuntimeOTLPExporter(t *testing.T) {
+	runRuntimeTest(t, newRuntimeValues(true, 2), `package goat
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeReceiver is a fake OTLP/HTTP receiver recording the export requests
+type fakeReceiver struct {
+	mu       sync.Mutex
+	requests []map[string]interface{}
+	paths    []string
+	headers  []string
+}
+
+func (f *fakeReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	f.requests = append(f.requests, request)
+	f.paths = append(f.paths, r.URL.Path)
+	f.headers = append(f.headers, r.Header.Get("Authorization"))
+	f.mu.Unlock()
+	w.WriteHeader(http.StatusOK)
+}
+
+func (f *fakeReceiver) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.requests)
+}
+
+// dataPoints returns the data points of the metric in the request by component or track point name
+func dataPoints(request map[string]interface{}, metric string) map[string]interface{} {
+	result := make(map[string]interface{})
+	resource := request["resourceMetrics"].([]interface{})[0].(map[string]interface{})
+	scope := resource["scopeMetrics"].([]interface{})[0].(map[string]interface{})
+	for _, m := range scope["metrics"].([]interface{}) {
+		m := m.(map[string]interface{})
+		if m["name"] != metric {
+			continue
+		}
+		data, ok := m["gauge"].(map[string]interface{})
+		if !ok {
+			data = m["sum"].(map[string]interface{})
+		}
+		for _, point := range data["dataPoints"].([]interface{}) {
+			point := point.(map[string]interface{})
+			key := ""
+			for _, attribute := range point["attributes"].([]interface{}) {
+				attribute := attribute.(map[string]interface{})
+				value := attribute["value"].(map[string]interface{})["stringValue"].(string)
+				if key != "" {
+					key += "/"
+				}
+				key += value
+			}
+			if v, ok := point["asInt"]; ok {
+				result[key] = v
+			} else {
+				result[key] = point["asDouble"]
+			}
+		}
+	}
+	return result
+}
+
+func TestExporter(t *testing.T) {
+	receiver := &fakeReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	Track(TRACK_ID_2)
+	Track(TRACK_ID_2)
+	err := Start(Options{
+		Disabled:        true,
+		OTLPEndpoint:    server.URL,
+		OTLPHeaders:     map[string]string{"Authorization": "Bearer secret"},
+		OTLPInterval:    10 * time.Millisecond,
+		OTLPTrackPoints: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); receiver.count() == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if receiver.count() == 0 {
+		t.Fatalf("no export received")
+	}
+	exported := receiver.count()
+	if err := Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if receiver.count() <= exported {
+		t.Errorf("no final export on shutdown")
+	}
+
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	if receiver.paths[0] != "/v1/metrics" || receiver.headers[0] != "Bearer secret" {
+		t.Errorf("path = %s, authorization = %s", receiver.paths[0], receiver.headers[0])
+	}
+	request := receiver.requests[len(receiver.requests)-1]
+	if got := dataPoints(request, TRACK_TOTAL); got["server"] != "2" || got["worker"] != "2" {
+		t.Errorf("total = %v", got)
+	}
+	if got := dataPoints(request, TRACK_COVERED); got["server"] != "1" || got["worker"] != "1" {
+		t.Errorf("covered = %v", got)
+	}
+	if got := dataPoints(request, TRACK_COVERAGE_RATIO); got["server"] != 50.0 {
+		t.Errorf("ratio = %v", got)
+	}
+	if got := dataPoints(request, TRACK_HITS); got["server/2/TRACK_ID_2"] != "2" || got["worker/3/TRACK_ID_3"] != "0" {
+		t.Errorf("hits = %v", got)
+	}
+}
+
+func TestExporterResetStartTime(t *testing.T) {
+	startTimes := func() map[string]string {
+		result := make(map[string]string)
+		for _, metric := range otlpRequestOf([]Component{COMPONENT_0, COMPONENT_1}, true, time.Now()).ResourceMetrics[0].ScopeMetrics[0].Metrics {
+			if metric.Name != TRACK_HITS {
+				continue
+			}
+			for _, point := range metric.Sum.DataPoints {
+				result[point.Attributes[0].Value.StringValue+"/"+point.Attributes[1].Value.StringValue] = point.StartTimeUnixNano
+			}
+		}
+		return result
+	}
+	before := startTimes()
+	time.Sleep(time.Millisecond)
+	Reset(COMPONENT_1)
+	after := startTimes()
+	if after["server/1"] != before["server/1"] {
+		t.Errorf("start time of a track point not reset changed from %s to %s", before["server/1"], after["server/1"])
+	}
+	for _, key := range []string{"server/2", "worker/2", "worker/3"} {
+		if after[key] <= before[key] {
+			t.Errorf("start time of the reset track point %s = %s, want after %s", key, after[key], before[key])
+		}
+	}
+}
+
+func TestDefaultOTLPOptions(t *testing.T) {
+	t.Setenv("GOAT_OTLP_ENDPOINT", "http://collector:4318")
+	t.Setenv("GOAT_OTLP_HEADERS", "x-api-key=abc, tenant = a")
+	t.Setenv("GOAT_OTLP_INTERVAL", "15s")
+	opts := DefaultOptions()
+	if opts.OTLPEndpoint != "http://collector:4318" || opts.OTLPInterval != 15*time.Second ||
+		opts.OTLPHeaders["x-api-key"] != "abc" || opts.OTLPHeaders["tenant"] != "a" {
+		t.Errorf("DefaultOptions() = %+v", opts)
+	}
+}
+`, "-race")
+}
+
+func TestRuntimePrometheus(t *testing.T) {
+	values := newRuntimeValues(false, 2)
+	values.Commit = "abc1234"
+	values.Granularity = "block"
+	values.SetTrackIdDataType(3, 1)
+	values.SetTrackIdAttrs(1, Attrs{Name: "refund"})
+	values.SetTrackIdPosition(1, Position{File: "pkg/pay/pay.go", Package: "app/pkg/pay", Func: "Refund", Line: 10})
+	values.SetTrackIdPosition(2, Position{File: "pkg/pay/card/card.go", Package: "app/pkg/pay/card", Func: "Card.Charge", Line: 20})
+	values.SetTrackIdPosition(3, Position{File: "cmd/worker/main.go", Package: "app/cmd/worker", Func: "main", Line: 5})
+	runRuntimeTest(t, values, `package goat
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T, server *httptest.Server, rawQuery string, accept string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/metrics?"+rawQuery, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp, string(data)
+}
+
+func TestPrometheusMetrics(t *testing.T) {
+	Track(TRACK_ID_1)
+	Track(TRACK_ID_1)
+	Track(TRACK_ID_3)
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+
+	resp, metrics := scrape(t, server, "detail=package,file,func", "")
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/plain; charset=utf-8" {
+		t.Errorf("Content-Type = %s", contentType)
+	}
+	expected := []string{
+		"goat_track_coverage_ratio{app=\"runtime\",version=\"1.0.0\",component=\"server\"} 50\n",
+		"goat_track_package_total{app=\"runtime\",version=\"1.0.0\",component=\"server\",package=\"app/pkg/pay\"} 1\n",
+		"goat_track_package_covered{app=\"runtime\",version=\"1.0.0\",component=\"server\",package=\"app/pkg/pay/card\"} 0\n",
+		"goat_track_file_covered{app=\"runtime\",version=\"1.0.0\",component=\"worker\",file=\"cmd/worker/main.go\"} 1\n",
+		"goat_track_func_total{app=\"runtime\",version=\"1.0.0\",component=\"server\",file=\"pkg/pay/card/card.go\",func=\"Card.Charge\"} 1\n",
+		"goat_track_detail_dropped_func{app=\"runtime\",version=\"1.0.0\"} 0\n",
+		"# TYPE goat_track_hits_total counter\n",
+		"goat_track_hits_total{app=\"runtime\",version=\"1.0.0\",component=\"server\",id=\"1\",name=\"refund\"} 2\n",
+		"goat_track_hits_total{app=\"runtime\",version=\"1.0.0\",component=\"worker\",id=\"2\",name=\"TRACK_ID_2\"} 0\n",
+		"goat_build_info{app=\"runtime\",version=\"1.0.0\",commit=\"abc1234\",granularity=\"block\",data_type=\"count\",race=\"false\"} 1\n",
+	}
+	for _, line := range expected {
+		if !strings.Contains(metrics, line) {
+			t.Errorf("metrics do not contain %q:\n%s", line, metrics)
+		}
+	}
+	// track ID 3 records whether it is covered, so it has no hits
+	if strings.Contains(metrics, "id=\"3\"") || strings.Contains(metrics, "# EOF") {
+		t.Errorf("unexpected metrics:\n%s", metrics)
+	}
+
+	// the detail series are capped per level
+	metricsMaxSeries = 1
+	defer func() { metricsMaxSeries = 1000 }()
+	_, metrics = scrape(t, server, "detail=file", "")
+	if strings.Count(metrics, "goat_track_file_total{") != 1 || !strings.Contains(metrics, "goat_track_detail_dropped_file{app=\"runtime\",version=\"1.0.0\"} 3\n") {
+		t.Errorf("detail series are not capped:\n%s", metrics)
+	}
+	if strings.Contains(metrics, "goat_track_package_total") {
+		t.Errorf("unexpected package series:\n%s", metrics)
+	}
+
+	if resp, _ := scrape(t, server, "detail=line", ""); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status of an invalid detail = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestOpenMetrics(t *testing.T) {
+	Track(TRACK_ID_2)
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+
+	resp, metrics := scrape(t, server, "", "application/openmetrics-text;version=1.0.0,text/plain;q=0.5")
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "application/openmetrics-text; version=1.0.0") {
+		t.Errorf("Content-Type = %s", contentType)
+	}
+	expected := []string{
+		"# TYPE goat_track_hits counter\n",
+		"goat_track_hits_total{app=\"runtime\",version=\"1.0.0\",component=\"server\",id=\"2\",name=\"TRACK_ID_2\"} 1\n",
+		"# TYPE goat_build info\n",
+		"goat_build_info{app=\"runtime\",version=\"1.0.0\",commit=\"abc1234\",granularity=\"block\",data_type=\"count\",race=\"false\"} 1\n",
+	}
+	for _, line := range expected {
+		if !strings.Contains(metrics, line) {
+			t.Errorf("metrics do not contain %q:\n%s", line, metrics)
+		}
+	}
+	if !strings.HasSuffix(metrics, "# EOF\n") {
+		t.Errorf("metrics do not end with # EOF:\n%s", metrics)
+	}
+}
+`)
+}
+
+func TestRuntimeTimestamps(t *testing.T) {
+	for _, race := range []bool{false, true} {
+		t.Run(fmt.Sprintf("race=%v", race), func(t *testing.T) {
+			values := newRuntimeValues(race, 2)
+			values.Timestamps = true
+			// the counters are only safe for concurrent hits with race: true
+			workers := "1"
+			if race {
+				workers = "8"
+			}
+			runRuntimeTest(t, values, strings.ReplaceAll(`package goat
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func itemOf(t *testing.T, id int) Item {
+	t.Helper()
+	for _, item := range Snapshot().Results[0].Metrics.Items {
+		if item.ID == id {
+			return item
+		}
+	}
+	t.Fatalf("track ID %d not found", id)
+	return Item{}
+}
+
+func TestHitTimes(t *testing.T) {
+	if item := itemOf(t, TRACK_ID_1); item.FirstHit != 0 || item.LastHit != 0 {
+		t.Errorf("times of a track point not hit = %d, %d", item.FirstHit, item.LastHit)
+	}
+	before := time.Now().Unix()
+	var wg sync.WaitGroup
+	workers := WORKERS
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 800/workers; j++ {
+				Track(TRACK_ID_1)
+			}
+		}()
+	}
+	wg.Wait()
+	item := itemOf(t, TRACK_ID_1)
+	if item.FirstHit < before || item.LastHit < item.FirstHit || item.Count != 800 {
+		t.Errorf("item = %+v, want hit times since %d", item, before)
+	}
+
+	// the first-hit time is kept while the last-hit time follows the hits
+	trackIdFirstHit[TRACK_ID_1] = before - 100
+	trackIdLastHit[TRACK_ID_1] = before - 50
+	Track(TRACK_ID_1)
+	if item := itemOf(t, TRACK_ID_1); item.FirstHit != before-100 || item.LastHit < before {
+		t.Errorf("item = %+v, want first hit %d and last hit since %d", item, before-100, before)
+	}
+
+	Reset()
+	if item := itemOf(t, TRACK_ID_1); item.FirstHit != 0 || item.LastHit != 0 {
+		t.Errorf("times after reset = %d, %d", item.FirstHit, item.LastHit)
+	}
+}
+`, "WORKERS", workers), "-race")
+		})
+	}
+}
+
+func TestRuntimeAverage(t *testing.T) {
+	values := newRuntimeValues(true, 3)
+	values.SetTrackIdDataType(3, 1)
+	runRuntimeTest(t, values, `package goat
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func work(d time.Duration) {
+	defer TrackTime(TRACK_ID_1)()
+	time.Sleep(d)
+}
+
+func TestTrackTime(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			work(10 * time.Millisecond)
+		}()
+	}
+	wg.Wait()
+	Track(TRACK_ID_3)
+
+	items := Snapshot().Results[0].Metrics.Items
+	var item Item
+	for _, it := range items {
+		if it.ID == TRACK_ID_1 {
+			item = it
+		}
+	}
+	if item.Count != 3 || item.Duration < uint64(30*time.Millisecond) || item.AvgDuration < uint64(10*time.Millisecond) {
+		t.Errorf("item = %+v, want 3 calls of at least 10ms", item)
+	}
+
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+	resp, err := server.Client().Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	metrics := string(data)
+	expected := []string{
+		"# TYPE goat_track_duration_seconds_total counter\n",
+		"goat_track_duration_seconds_total{app=\"runtime\",version=\"1.0.0\",component=\"server\",id=\"1\",name=\"TRACK_ID_1\"} 0.0",
+		"goat_track_avg_duration_seconds{app=\"runtime\",version=\"1.0.0\",component=\"server\",id=\"1\",name=\"TRACK_ID_1\"} 0.0",
+		"goat_track_hits_total{app=\"runtime\",version=\"1.0.0\",component=\"server\",id=\"1\",name=\"TRACK_ID_1\"} 3\n",
+		"data_type=\"average\"",
+	}
+	for _, line := range expected {
+		if !strings.Contains(metrics, line) {
+			t.Errorf("metrics do not contain %q:\n%s", line, metrics)
+		}
+	}
+	// track ID 3 is overridden to bool, so it is neither timed nor counted
+	if strings.Contains(metrics, "id=\"3\"") {
+		t.Errorf("unexpected metrics of track ID 3:\n%s", metrics)
+	}
+
+	Reset()
+	if item := Snapshot().Results[0].Metrics.Items[0]; item.Count != 0 || item.Duration != 0 {
+		t.Errorf("item after reset = %+v", item)
+	}
+}
+`, "-race")
+}
+
+// counterLayoutCases is the counter layouts of the runtime tests and benchmarks
+var counterLayoutCases = []struct {
+	layout string
+	bits   int
+}{
+	{"packed", 32},
+	{"packed", 64},
+	{"padded", 32},
+	{"padded", 64},
+	{"sharded", 32},
+	{"sharded", 64},
+}
+
+func TestRuntimeCounterLayouts(t *testing.T) {
+	for _, tc := range counterLayoutCases {
+		for _, race := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/%d/race=%v", tc.layout, tc.bits, race), func(t *testing.T) {
+				values := newRuntimeValues(race, 2)
+				values.CounterLayout = tc.layout
+				values.CounterBits = tc.bits
+				values.SetTrackIdDataType(3, 1)
+				// the counters are only safe for concurrent hits with race: true
+				workers := "1"
+				if race {
+					workers = "8"
+				}
+				source := strings.NewReplacer("WORKERS", workers, "BITS", fmt.Sprint(tc.bits)).Replace(`package goat
+
+import (
+	"sync"
+	"testing"
+)
+
+// the counters of 64 bits hold counts over 32 bits
+var _ = uint64(trackCounter(1) << (BITS - 1))
+
+func TestCounters(t *testing.T) {
+	var wg sync.WaitGroup
+	workers := WORKERS
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000/workers; j++ {
+				Track(TRACK_ID_1)
+				Track(TRACK_ID_3)
+			}
+		}()
+	}
+	wg.Wait()
+	Track(TRACK_ID_2)
+	if got := trackCount(TRACK_ID_1); got != 1000 {
+		t.Errorf("count of track ID 1 = %d, want 1000", got)
+	}
+	if got := trackCount(TRACK_ID_2); got != 1 {
+		t.Errorf("count of track ID 2 = %d, want 1", got)
+	}
+	// track ID 3 is a bool track ID
+	if got := trackCount(TRACK_ID_3); got != 1 {
+		t.Errorf("count of track ID 3 = %d, want 1", got)
+	}
+	Reset()
+	for id := TRACK_ID_1; id < TRACK_ID_END; id++ {
+		if got := trackCount(id); got != 0 {
+			t.Errorf("count of track ID %d after reset = %d", id, got)
+		}
+	}
+}
+`)
+				runRuntimeTest(t, values, source, "-race")
+			})
+		}
+	}
+}
+
+// BenchmarkRuntimeCounterLayouts runs the track benchmarks of the generated runtime for each counter layout,
+// e.g. "go test -run ^$ -bench RuntimeCounterLayouts ./pkg/tracking/increment", the ns/op of the generated
+// benchmarks are reported as adjacent-ns/op, goroutines hitting adjacent track IDs, and hot-ns/op,
+// goroutines hitting the same track ID
+func BenchmarkRuntimeCounterLayouts(b *testing.B) {
+	source := `package goat
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func BenchmarkAdjacent(b *testing.B) {
+	var next int32
+	b.RunParallel(func(pb *testing.PB) {
+		id := int(atomic.AddInt32(&next, 1))%(TRACK_ID_END-1) + 1
+		for pb.Next() {
+			Track(id)
+		}
+	})
+}
+
+func BenchmarkHot(b *testing.B) {
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			Track(TRACK_ID_1)
+		}
+	})
+}
+`
+	nsPerOp := regexp.MustCompile(`(?m)^Benchmark(Adjacent|Hot)\S*\s+\d+\s+([\d.]+) ns/op`)
+	for _, dataType := range []int{1, 2} {
+		for _, tc := range counterLayoutCases {
+			b.Run(fmt.Sprintf("%s/%s/%d", map[int]string{1: "bool", 2: "count"}[dataType], tc.layout, tc.bits), func(b *testing.B) {
+				values := newRuntimeValues(true, dataType)
+				values.TrackIds = []int{1, 2, 3, 4, 5, 6, 7, 8}
+				values.CounterLayout = tc.layout
+				values.CounterBits = tc.bits
+				output := runRuntimeTest(b, values, source, "-run", "^$", "-bench", ".")
+				for _, match := range nsPerOp.FindAllStringSubmatch(output, -1) {
+					value, _ := strconv.ParseFloat(match[2], 64)
+					b.ReportMetric(value, strings.ToLower(match[1])+"-ns/op")
+				}
+				b.ReportMetric(0, "ns/op")
+			})
+		}
+	}
+}
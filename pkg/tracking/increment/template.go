@@ -7,23 +7,39 @@ import (
 	"github.com/monshunter/goat/pkg/config"
 )
 
-const Template = `// +goat: generated by increment tracking package, do not edit
+const Template = `{{ if .BuildTag }}//go:build {{ .BuildTag }}
+
+{{ end }}// +goat: generated by increment tracking package, do not edit
 package {{.PackageName}}
 
 import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"sync"
 	{{ if .Race -}}
 	"sync/atomic"
 	{{ end -}}
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 	"crypto/md5"
 	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"path"
+	{{- if eq .Layout "sharded" }}
+	"runtime"
+	"unsafe"
+	{{- end }}
 )
 
 // application version
@@ -45,53 +61,257 @@ const (
 // track ID names
 var TrackIdNames [TRACK_ID_END]string
 
-// track ID status record - use slice instead of map to improve performance
-var trackIdStatus [TRACK_ID_END]uint32
+// track ID tags
+var TrackIdTags [TRACK_ID_END][]string
 
-// track metrics
-const (
-	TRACK_COVERAGE_RATIO = "goat_track_coverage_ratio"
-	TRACK_TOTAL          = "goat_track_total"
-	TRACK_COVERED        = "goat_track_covered"
-)
+// track IDs named by annotations
+var trackIdNamed [TRACK_ID_END]bool
 
-// track metrics description
-const (
-	TRACK_COVERAGE_RATIO_DESC = "Goat track coverage ratio"
-	TRACK_TOTAL_DESC          = "Goat track total"
-	TRACK_COVERED_DESC        = "Goat track covered"
+// all tags of the track IDs
+var trackTags = []string{ {{- range .Tags}}
+	"{{.}}",{{end}}
+}
+
+// track ID source positions
+var (
+	// trackIdFiles is the files of the track IDs
+	trackIdFiles = [TRACK_ID_END]string{ {{- range $id, $pos := .TrackIdPositions}}
+		TRACK_ID_{{$id}}: {{printf "%q" $pos.File}},{{end}}
+	}
+	// trackIdPackages is the packages of the track IDs
+	trackIdPackages = [TRACK_ID_END]string{ {{- range $id, $pos := .TrackIdPositions}}
+		TRACK_ID_{{$id}}: {{printf "%q" $pos.Package}},{{end}}
+	}
+	// trackIdFuncs is the enclosing functions of the track IDs
+	trackIdFuncs = [TRACK_ID_END]string{ {{- range $id, $pos := .TrackIdPositions}}{{if $pos.Func}}
+		TRACK_ID_{{$id}}: {{printf "%q" $pos.Func}},{{end}}{{end}}
+	}
+	// trackIdLines is the lines of the track IDs
+	trackIdLines = [TRACK_ID_END]int{ {{- range $id, $pos := .TrackIdPositions}}
+		TRACK_ID_{{$id}}: {{$pos.Line}},{{end}}
+	}
 )
 
-// current component
+// trackCounter is the counter of a track ID
+type trackCounter = uint{{ .Bits }}
+{{ if eq .Layout "padded" }}
+// trackCell is a counter padded to a cache line, so that hot track IDs do not share cache lines
+type trackCell struct {
+	n trackCounter
+	_ [{{ if eq .Bits 64 }}56{{ else }}60{{ end }}]byte
+}
+
+// track ID status record, one cache line per track ID
+var trackIdStatus [TRACK_ID_END]trackCell
+{{ else if eq .Layout "sharded" }}
+// track ID status record, one shard per group of goroutines merged at read time,
+// the bool track IDs are only recorded in the first shard
+var trackIdStatus = make([]trackShard, trackShards())
+
+// trackShard is the counters of a shard, padded so that shards do not share cache lines
+type trackShard struct {
+	counts [TRACK_ID_END]trackCounter
+	_      [64]byte
+}
+
+// trackShards returns the number of shards, GOMAXPROCS rounded up to a power of two
+func trackShards() int {
+	shards := 1
+	for shards < runtime.GOMAXPROCS(0) && shards < 64 {
+		shards *= 2
+	}
+	return shards
+}
+
+// trackShardOf returns the shard of the calling goroutine, picked from the address of its stack
+// so that goroutines running in parallel mostly hit different shards
+func trackShardOf() int {
+	var marker byte
+	h := uint32(uintptr(unsafe.Pointer(&marker)) >> 12)
+	h *= 0x9E3779B9
+	return int(h>>16) & (len(trackIdStatus) - 1)
+}
+{{ else }}
+// track ID status record - use slice instead of map to improve performance
+var trackIdStatus [TRACK_ID_END]trackCounter
+{{ end }}
+` + constantsTemplate + `
+// current component overriding the scope, set by GOAT_CURRENT_COMPONENT
 var currentComponent string
 
+// metrics by package, file and function
+var (
+	// metricsDetail is the default levels of the detail metrics, set by GOAT_METRICS_DETAIL
+	metricsDetail []string
+	// metricsMaxSeries is the max number of series of each detail level, set by GOAT_METRICS_MAX_SERIES
+	metricsMaxSeries = 1000
+)
+
+// detailLevels is the valid levels of the detail metrics
+var detailLevels = []string{"package", "file", "func"}
+
+// scope of /metrics and /track
+var (
+	// scopeMutex protects scopeComponents
+	scopeMutex sync.RWMutex
+	// scopeComponents is the components of the main entry, all components if empty
+	scopeComponents []Component
+)
+
 // initialize track IDs
 func init() {
 	for i := 1; i < TRACK_ID_END; i++ {
 		TrackIdNames[i] = fmt.Sprintf("TRACK_ID_%d", i)
 	}
+	{{- range $id, $attrs := .TrackIdAttrs }}
+	{{- if $attrs.Name }}
+	TrackIdNames[TRACK_ID_{{$id}}] = "{{$attrs.Name}}"
+	trackIdNamed[TRACK_ID_{{$id}}] = true
+	{{- end }}
+	{{- if $attrs.Tags }}
+	TrackIdTags[TRACK_ID_{{$id}}] = []string{ {{- range $i, $tag := $attrs.Tags}}{{if $i}}, {{end}}"{{$tag}}"{{end -}} }
+	{{- end }}
+	{{- end }}
 	currentComponent = os.Getenv("GOAT_CURRENT_COMPONENT")
+	metricsDetail = splitQuery(os.Getenv("GOAT_METRICS_DETAIL"))
+	if maxSeries, err := strconv.Atoi(os.Getenv("GOAT_METRICS_MAX_SERIES")); err == nil && maxSeries >= 0 {
+		metricsMaxSeries = maxSeries
+	}
+}
+
+{{ if .TrackIdDataTypes -}}
+// track ID data types overridden by path, zero means the default data type
+var trackIdDataTypes = [TRACK_ID_END]uint8{ {{- range $id, $dataType := .TrackIdDataTypes}}
+	TRACK_ID_{{$id}}: {{$dataType}},{{end}}
 }
 
+{{ end -}}
+{{ if .Timestamps -}}
+// first-hit and last-hit Unix times of the track IDs, zero if not hit
+var (
+	trackIdFirstHit [TRACK_ID_END]int64
+	trackIdLastHit  [TRACK_ID_END]int64
+)
+
+{{ end -}}
 // Track track function
 func Track(id trackId) {
 	if id > 0 && id < TRACK_ID_END {
+		{{ if .TrackIdDataTypes -}}
+		if trackIdDataTypes[id] {{ if eq .DataType 1 }}>= 2{{ else }}!= 1{{ end }} {
+			{{ template "trackCount" . }}
+		} else {
+			{{ template "trackBool" . }}
+		}
+		{{- else if eq .DataType 1 -}}
+		{{ template "trackBool" . }}
+		{{- else -}}
+		{{ template "trackCount" . }}
+		{{- end }}
+		{{- if .Timestamps }}
+		{{ template "trackTime" . }}
+		{{- end }}
+	}
+}
+
+// TrackCond tracks an operand of a condition, the true track ID if it is true and the false one otherwise,
+// it returns the operand unchanged and is inserted as "goat.TrackCond(goat.TRACK_ID_n, goat.TRACK_ID_m, operand)"
+func TrackCond[T ~bool](trueId, falseId trackId, v T) T {
+	if v {
+		Track(trueId)
+	} else {
+		Track(falseId)
+	}
+	return v
+}
+{{ if .HasAverage }}
+// cumulative durations of the timed track IDs in nanoseconds
+var trackIdDurations [TRACK_ID_END]uint64
+
+// TrackTime counts the call of the timed track ID and returns the function recording the duration
+// of the call, it is inserted as "defer goat.TrackTime(goat.TRACK_ID_n)()" at the top of the function
+func TrackTime(id trackId) func() {
+	if id <= 0 || id >= TRACK_ID_END {
+		return func() {}
+	}
+	Track(id)
+	start := time.Now()
+	return func() {
 		{{ if .Race -}}
-		   {{ if eq .DataType 1 -}}
-			atomic.StoreUint32(&trackIdStatus[id], 1)
-			{{- else -}}
-			atomic.AddUint32(&trackIdStatus[id], 1)
-			{{- end -}}
+		atomic.AddUint64(&trackIdDurations[id], uint64(time.Since(start)))
 		{{- else -}}
-			{{ if eq .DataType 1 -}}
-			trackIdStatus[id] = 1
-			{{- else -}}
-			trackIdStatus[id]++
-			{{- end -}}
+		trackIdDurations[id] += uint64(time.Since(start))
 		{{- end }}
 	}
 }
 
+// timedTrackId checks if the track ID records the duration of the calls
+func timedTrackId(id trackId) bool {
+	{{ if .TrackIdDataTypes -}}
+	if trackIdDataTypes[id] != 0 {
+		return trackIdDataTypes[id] == 3
+	}
+	{{ end -}}
+	return {{ if eq .DataType 3 }}true{{ else }}false{{ end }}
+}
+
+// trackDuration returns the cumulative duration of the track ID
+func trackDuration(id trackId) uint64 {
+	{{ if .Race -}}
+	return atomic.LoadUint64(&trackIdDurations[id])
+	{{- else -}}
+	return trackIdDurations[id]
+	{{- end }}
+}
+{{ end }}
+// user track points, identified by name and registered at runtime
+var (
+	// userTrackMutex protects userTrackStatus and userTrackNames
+	userTrackMutex sync.RWMutex
+	// userTrackStatus is the status of the user track points by name
+	userTrackStatus = make(map[string]*uint32)
+	// userTrackNames is the names of the user track points in registration order
+	userTrackNames []string
+)
+
+// Register registers a user track point by name, it is reported as uncovered until tracked
+func Register(name string) {
+	userTrack(name)
+}
+
+// TrackNamed tracks a user track point by name, the point is registered on first use
+func TrackNamed(name string) {
+	status := userTrack(name)
+	{{ if and .Race (eq .DataType 1) -}}
+	atomic.StoreUint32(status, 1)
+	{{- else if .Race -}}
+	atomic.AddUint32(status, 1)
+	{{- else if eq .DataType 1 -}}
+	*status = 1
+	{{- else -}}
+	*status++
+	{{- end }}
+}
+
+// userTrack returns the status of the user track point, registering it if needed
+func userTrack(name string) *uint32 {
+	userTrackMutex.RLock()
+	status, ok := userTrackStatus[name]
+	userTrackMutex.RUnlock()
+	if ok {
+		return status
+	}
+	userTrackMutex.Lock()
+	defer userTrackMutex.Unlock()
+	if status, ok := userTrackStatus[name]; ok {
+		return status
+	}
+	status = new(uint32)
+	userTrackStatus[name] = status
+	userTrackNames = append(userTrackNames, name)
+	return status
+}
+
 // Component type
 type Component = int
 
@@ -121,36 +341,104 @@ var componentNamesMap = map[string]Component{ {{- range .Components}}
 func GetComponentName(component Component) string {
 	return componentNames[component]
 }
-{{range .Components}}
-// Track IDs for component {{ .ID }}
-var COMPONENT_{{ .ID }}_TRACK_IDS = []trackId{ {{- range .TrackIds}}
-	TRACK_ID_{{.}},{{end}}
-	// ...
-}{{end}}
+` + componentTrackIdsTemplate + `
+` + typesTemplate + `
+// TagMetrics returns the metrics of the items by tag
+func (it Items) TagMetrics() map[string]TagMetrics {
+	var result map[string]TagMetrics
+	for _, item := range it {
+		for _, tag := range item.Tags {
+			if result == nil {
+				result = make(map[string]TagMetrics)
+			}
+			metrics := result[tag]
+			metrics.Total++
+			if item.Count > 0 {
+				metrics.Covered++
+			}
+			result[tag] = metrics
+		}
+	}
+	return result
+}
 
-// Component to track ID mapping
-var COMPONENT_TRACK_IDS = map[Component][]trackId{ {{- range .Components}}
-	COMPONENT_{{ .ID }}: COMPONENT_{{ .ID }}_TRACK_IDS,{{end}}
-	// ...
+// componentItems returns the track items of the component with the counts,
+// which are copied once by trackCounts so that all components of a request are consistent
+func componentItems(component Component, counts *[TRACK_ID_END]uint64) Items {
+	componentTrackIds := COMPONENT_TRACK_IDS[component]
+	items := make(Items, 0, len(componentTrackIds))
+	for _, id := range componentTrackIds {
+		item := newItem(id, counts[id])
+		{{- if .HasAverage }}
+		if timedTrackId(id) {
+			item.Duration = trackDuration(id)
+			if item.Count > 0 {
+				item.AvgDuration = item.Duration / uint64(item.Count)
+			}
+		}
+		{{- end }}
+		items = append(items, item)
+	}
+	return items
 }
 
-// Item struct
-type Item struct {
-	// track ID
-	ID int ` + "`json:\"id\"`" + `
-	// track name
-	Name string ` + "`json:\"name\"`" + `
-	// track count
-	Count uint32 ` + "`json:\"count\"`" + `
+// newItem returns the item of the track ID with the count
+func newItem(id trackId, count uint64) Item {
+	return Item{
+		ID:      id,
+		Name:    TrackIdNames[id],
+		Tags:    TrackIdTags[id],
+		File:    trackIdFiles[id],
+		Package: trackIdPackages[id],
+		Func:    trackIdFuncs[id],
+		Line:    trackIdLines[id],
+		Count:   count,
+		{{- if .Timestamps }}
+		FirstHit: trackHitTime(&trackIdFirstHit[id]),
+		LastHit:  trackHitTime(&trackIdLastHit[id]),
+		{{- end }}
+	}
+}
+{{ if .Timestamps }}
+// trackHitTime returns the hit time stored at addr
+func trackHitTime(addr *int64) int64 {
+	{{ if .Race -}}
+	return atomic.LoadInt64(addr)
+	{{- else -}}
+	return *addr
+	{{- end }}
 }
+{{ end }}
 
-// Items slice
-type Items []Item
+// trackCount returns the count of the track ID
+func trackCount(id trackId) uint64 {
+	{{ if eq .Layout "sharded" -}}
+	var count uint64
+	for shard := range trackIdStatus {
+		{{ if .Race -}}
+		count += uint64(atomic.LoadUint{{ .Bits }}(&trackIdStatus[shard].counts[id]))
+		{{- else -}}
+		count += uint64(trackIdStatus[shard].counts[id])
+		{{- end }}
+	}
+	return count
+	{{- else if .Race -}}
+	return uint64(atomic.LoadUint{{ .Bits }}(&{{ template "counterRef" . }}))
+	{{- else -}}
+	return uint64({{ template "counterRef" . }})
+	{{- end }}
+}
 
-// localHash is a hash of the items
-var localHash = md5.New()
+// trackCounts returns the counts of all track IDs
+func trackCounts() *[TRACK_ID_END]uint64 {
+	var counts [TRACK_ID_END]uint64
+	for id := range counts {
+		counts[id] = trackCount(id)
+	}
+	return &counts
+}
 
-// Version returns the version of the items
+// Version returns the version of the items, the items are sorted by ID
 func (it Items) Version() string {
 	sort.Slice(it, func(i, j int) bool {
 		return it[i].ID < it[j].ID
@@ -159,226 +447,2092 @@ func (it Items) Version() string {
 	for _, item := range it {
 		buf.WriteString(fmt.Sprintf("#%d=%d", item.ID, item.Count))
 	}
-	localHash.Reset()
-	localHash.Write(buf.Bytes())
-	return fmt.Sprintf("%x", localHash.Sum(nil))
+	return fmt.Sprintf("%x", md5.Sum(buf.Bytes()))
 }
-	
-// Metrics struct
-type Metrics struct {
-	// version
-	Version string ` + "`json:\"version\"`" + `
-	// total track count
-	Total       int    ` + "`json:\"total\"`" + `
-	// covered track count
-	Covered     int    ` + "`json:\"covered\"`" + `
-	// covered rate
-	CoveredRate int    ` + "`json:\"coveredRate\"`" + `
-	// track items
-	Items       Items  ` + "`json:\"items\"`" + `
+
+// userMetrics returns the metrics of the user track points sorted by order, nil if none is registered
+func userMetrics(order int) *UserMetrics {
+	userTrackMutex.RLock()
+	items := make([]UserItem, 0, len(userTrackNames))
+	for _, name := range userTrackNames {
+		{{ if .Race -}}
+		count := atomic.LoadUint32(userTrackStatus[name])
+		{{- else -}}
+		count := *userTrackStatus[name]
+		{{- end }}
+		items = append(items, UserItem{Name: name, Count: count})
+	}
+	userTrackMutex.RUnlock()
+	if len(items) == 0 {
+		return nil
+	}
+	switch order {
+	case 0:
+		sort.SliceStable(items, func(i, j int) bool {
+			return items[i].Count < items[j].Count
+		})
+	case 1:
+		sort.SliceStable(items, func(i, j int) bool {
+			return items[i].Count > items[j].Count
+		})
+	case 2:
+		sort.Slice(items, func(i, j int) bool {
+			return items[i].Name < items[j].Name
+		})
+	case 3:
+		sort.Slice(items, func(i, j int) bool {
+			return items[i].Name > items[j].Name
+		})
+	}
+	covered := 0
+	for _, item := range items {
+		if item.Count > 0 {
+			covered++
+		}
+	}
+	return &UserMetrics{
+		Total:       len(items),
+		Covered:     covered,
+		CoveredRate: covered * 100 / len(items),
+		Items:       items,
+	}
 }
 
-// ComponentResult struct
-type ComponentResult struct {
-	// component
-	ID Component ` + "`json:\"id\"`" + `
-	// component name
-	Name string ` + "`json:\"name\"`" + `
-	// metrics
-	Metrics   Metrics   ` + "`json:\"metrics\"`" + `
+// test sessions, which record the track points hit between their start and stop
+var (
+	// sessionMutex protects sessions
+	sessionMutex sync.Mutex
+	// sessions is the test sessions by name
+	sessions = make(map[string]*session)
+)
+
+// session is a test session
+type session struct {
+	startedAt time.Time
+	stoppedAt time.Time
+	// baseline is the counts of the track IDs when the session started
+	baseline *[TRACK_ID_END]uint64
+	// delta is the counts of the track IDs hit in the session, nil while running
+	delta *[TRACK_ID_END]uint64
 }
-// Results struct
-type Results struct {
-	// name
-	Name string ` + "`json:\"name\"`" + `
-	// version
-	Version string ` + "`json:\"version\"`" + `
-	// results
-	Results []ComponentResult ` + "`json:\"results\"`" + `
+
+// StartSession starts the named test session, a session with the same name is restarted
+func StartSession(name string) error {
+	if name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("invalid session name %q", name)
+	}
+	sessionMutex.Lock()
+	defer sessionMutex.Unlock()
+	sessions[name] = &session{startedAt: time.Now(), baseline: trackCounts()}
+	return nil
 }
-// ServeHTTP start HTTP service
-func ServeHTTP(component Component) {
-	go func() {
-		system := http.NewServeMux()
-		system.HandleFunc("/metrics", metricsHandler)
-		system.HandleFunc("/track", trackHandler)
-  		// DEAD in hexadecimal is 57005 in decimal
-		port := "57005"
-		if os.Getenv("GOAT_PORT") != "" {
-			port = os.Getenv("GOAT_PORT")
-		}
-		expose := os.Getenv("GOAT_METRICS_IP")
-		if expose == "" {
-			expose = "127.0.0.1"
-		}
-		addr := fmt.Sprintf("%s:%s", expose, port)
-		log.Printf("Goat track service started: http://%s\n", addr)
-		log.Fatal(http.ListenAndServe(addr, system))
-	}()
+
+// StopSession stops the named test session and keeps the track points hit in it
+func StopSession(name string) error {
+	sessionMutex.Lock()
+	defer sessionMutex.Unlock()
+	s, ok := sessions[name]
+	if !ok {
+		return fmt.Errorf("session %q not found", name)
+	}
+	if s.delta != nil {
+		return fmt.Errorf("session %q already stopped", name)
+	}
+	s.stoppedAt = time.Now()
+	s.delta = sessionDelta(s.baseline, trackCounts())
+	return nil
 }
 
-// trackHandler track ID status processing function
-func trackHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	// invalid order:
-	// order=0: count asc (default)
-	// order=1: count desc
-	// order=2: id asc
-	// order=3: id desc
-	orderStr := r.URL.Query().Get("order")
-	order, err := strconv.Atoi(orderStr)
-	if err != nil || order < 0 || order > 3 {
-		order = 0
+// Sessions returns the sorted names of the test sessions
+func Sessions() []string {
+	sessionMutex.Lock()
+	defer sessionMutex.Unlock()
+	names := make([]string, 0, len(sessions))
+	for name := range sessions {
+		names = append(names, name)
 	}
-	cms := components
-	componentStr := r.URL.Query().Get("component")
-	if componentStr != "" {
-		componentSlice := strings.Split(componentStr, ",")
-		cms = make([]Component, 0, len(componentSlice))
-		for _, componentStr := range componentSlice {
-			componentIdx, ok := componentNamesMap[componentStr]
-			if !ok {
-				// check if it is a number
-				componentIdx, err := strconv.Atoi(componentStr)
-				if err != nil || componentIdx < 0 || componentIdx >= len(components) {
-					http.Error(w, "invalid component", http.StatusBadRequest)
-					return
-				}
-				cms = append(cms, Component(componentIdx))
-				continue
-			}
-			cms = append(cms, componentIdx)
-		}
+	sort.Strings(names)
+	return names
+}
+
+// SessionSnapshot returns the track points hit in the named test session,
+// the points hit so far are returned if the session is running
+func SessionSnapshot(name string) (Session, error) {
+	sessionMutex.Lock()
+	s, ok := sessions[name]
+	if !ok {
+		sessionMutex.Unlock()
+		return Session{}, fmt.Errorf("session %q not found", name)
 	}
+	result := Session{Name: name, Component: componentsName(components), Running: s.delta == nil, StartedAt: s.startedAt}
+	delta := s.delta
+	if delta == nil {
+		delta = sessionDelta(s.baseline, trackCounts())
+	} else {
+		stoppedAt := s.stoppedAt
+		result.StoppedAt = &stoppedAt
+	}
+	sessionMutex.Unlock()
 
-	results := make([]ComponentResult, 0, len(cms))
-	for _, component := range cms {
-		covered := 0
+	result.Results = make([]ComponentResult, 0, len(components))
+	for _, component := range components {
 		componentTrackIds := COMPONENT_TRACK_IDS[component]
-		items := make(Items, 0, len(componentTrackIds))
+		items := make(Items, 0)
 		for _, id := range componentTrackIds {
-			{{ if .Race -}}
-			count := atomic.LoadUint32(&trackIdStatus[id])
-			{{- else -}}
-			count := trackIdStatus[id]
-			{{- end }}
-			items = append(items, Item{ID: id, Name: TrackIdNames[id], Count: count})
-			if count > 0 {
-				covered++
+			if delta[id] > 0 {
+				items = append(items, newItem(id, delta[id]))
 			}
 		}
-		version := items.Version()
-		switch order {
-		case 0:
-			sort.Slice(items, func(i, j int) bool {
-				return items[i].Count < items[j].Count
-			})
-		case 1:
-			sort.Slice(items, func(i, j int) bool {
-				return items[i].Count > items[j].Count
-			})
-		case 2:
-			sort.Slice(items, func(i, j int) bool {
-				return items[i].ID < items[j].ID
-			})
-		case 3:
-			sort.Slice(items, func(i, j int) bool {
-				return items[i].ID > items[j].ID
-			})
-		}
 		coveredRate := 0
 		if len(componentTrackIds) > 0 {
-			coveredRate = covered * 100 / len(componentTrackIds)
+			coveredRate = len(items) * 100 / len(componentTrackIds)
 		}
-		results = append(results, ComponentResult{
+		result.Results = append(result.Results, ComponentResult{
 			ID:   component,
 			Name: GetComponentName(component),
 			Metrics: Metrics{
-				Version: version,
-				Total:   len(componentTrackIds),
-				Covered: covered,
+				Version:     items.Version(),
+				Total:       len(componentTrackIds),
+				Covered:     len(items),
 				CoveredRate: coveredRate,
-				Items:     items,
+				Items:       items,
+				Tags:        items.TagMetrics(),
 			},
 		})
 	}
-	// output JSON
-	jsonData, _ := json.Marshal(Results{Name: NAME, Version: VERSION, Results: results})
-	w.Write(jsonData)
+	return result, nil
 }
 
-// metricsHandler metrics handler
-func metricsHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-
-	indicators := [][]string{
-		{TRACK_TOTAL, TRACK_TOTAL_DESC},
-		{TRACK_COVERED, TRACK_COVERED_DESC},
-		{TRACK_COVERAGE_RATIO, TRACK_COVERAGE_RATIO_DESC},
-	}
-
-	targetComponents := components
-	if currentComponent != "" {
-		componentIdx, ok := componentNamesMap[currentComponent]
-		if !ok {
-			http.Error(w, "invalid component", http.StatusBadRequest)
-			return
+// sessionDelta returns the counts of the track IDs increased from the baseline,
+// a count lower than the baseline has been reset and counts from zero
+func sessionDelta(baseline, current *[TRACK_ID_END]uint64) *[TRACK_ID_END]uint64 {
+	var delta [TRACK_ID_END]uint64
+	for id := range delta {
+		if current[id] < baseline[id] {
+			delta[id] = current[id]
+			continue
 		}
-		targetComponents = []Component{componentIdx}
+		delta[id] = current[id] - baseline[id]
 	}
+	return &delta
+}
 
-	coverages := make([]int, len(targetComponents))
-	counts := make([]int, len(targetComponents))
-
-	for _, component := range targetComponents {
-		covered := 0
-		componentTrackIds := COMPONENT_TRACK_IDS[component]
-		for _, id := range componentTrackIds {
-			count := trackIdStatus[id]
-			if count > 0 {
-				covered++
-			}
+// Reset zeroes the counts of the components, all track points including
+// the user track points are zeroed if no component is given.
+// A track ID shared by several components is zeroed for all of them
+func Reset(cms ...Component) {
+	now := time.Now()
+	resetMutex.Lock()
+	defer resetMutex.Unlock()
+	if len(cms) == 0 {
+		for id := 0; id < TRACK_ID_END; id++ {
+			{{ template "trackReset" . }}
+			resetTimes[id] = now
+		}
+		userTrackMutex.RLock()
+		for _, status := range userTrackStatus {
+			{{ if .Race -}}
+			atomic.StoreUint32(status, 0)
+			{{- else -}}
+			*status = 0
+			{{- end }}
 		}
-		coverages[component] = covered
-		counts[component] = len(componentTrackIds)
+		userTrackMutex.RUnlock()
+		return
 	}
-
-	for _, indicator := range indicators {
-		w.Write([]byte(formatHelp(indicator[0], indicator[1])))
-		for i := 0; i < len(targetComponents); i++ {
-			component := targetComponents[i]
-			switch indicator[0] {
-			case TRACK_COVERAGE_RATIO:
-				ratio := coverages[component] * 100 / counts[component]
-				w.Write([]byte(formatMetric(indicator[0], NAME, VERSION, componentNames[component], ratio)))
-			case TRACK_TOTAL:
-				w.Write([]byte(formatMetric(indicator[0], NAME, VERSION, componentNames[component], counts[component])))
-			case TRACK_COVERED:
-				w.Write([]byte(formatMetric(indicator[0], NAME, VERSION, componentNames[component], coverages[component])))
-			}
+	for _, component := range cms {
+		for _, id := range COMPONENT_TRACK_IDS[component] {
+			{{ template "trackReset" . }}
+			resetTimes[id] = now
 		}
 	}
 }
 
-// formatHelp format help and type
-func formatHelp(name string, help string) string {
-	return fmt.Sprintf("# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
-}
+var (
+	// resetMutex protects resetTimes
+	resetMutex sync.Mutex
+	// resetTimes is the last reset time of the track IDs, zero if never reset
+	resetTimes [TRACK_ID_END]time.Time
+)
 
-// formatMetric format metric
-func formatMetric(name, app string, version string, component string, value int) string {
-	return fmt.Sprintf("%s{app=\"%s\",version=\"%s\",component=\"%s\"} %d\n", name, app, version, component, value)
+// startTimeOf returns the start time of the cumulative count of the track ID, its last reset time if reset
+func startTimeOf(id trackId) time.Time {
+	resetMutex.Lock()
+	defer resetMutex.Unlock()
+	if resetTimes[id].IsZero() {
+		return startTime
+	}
+	return resetTimes[id]
 }
 
-`
+// versions of the component items, kept for ?since= requests
+var (
+	// versionMutex protects versionCounts and versionKeys
+	versionMutex sync.Mutex
+	// versionCounts is the counts of the component items sorted by ID, by component and version
+	versionCounts = make(map[string][]uint64)
+	// versionKeys is the keys of versionCounts in insertion order, the oldest is evicted first
+	versionKeys []string
+)
 
-const TrackImportPathPlaceHolder = `github.com/monshunter/goat/goat`
-const TrackStmtPlaceHolder = `goat.Track(TRACK_ID)`
+// maxVersions is the max number of versions kept for ?since= requests
+const maxVersions = 128
 
-func GetMainEntryInsertData(ident string, componentID int) []string {
-	return []string{
+// versionKey returns the key of the component version
+func versionKey(component Component, version string) string {
+	return strconv.Itoa(component) + "/" + version
+}
+
+// rememberVersion keeps the counts of the component items sorted by ID under the version
+func rememberVersion(component Component, version string, items Items) {
+	key := versionKey(component, version)
+	versionMutex.Lock()
+	defer versionMutex.Unlock()
+	if _, ok := versionCounts[key]; ok {
+		return
+	}
+	counts := make([]uint64, len(items))
+	for i, item := range items {
+		counts[i] = item.Count
+	}
+	versionCounts[key] = counts
+	versionKeys = append(versionKeys, key)
+	if len(versionKeys) > maxVersions {
+		delete(versionCounts, versionKeys[0])
+		versionKeys = versionKeys[1:]
+	}
+}
+
+// changedItems returns the items sorted by ID whose counts changed since one of the versions,
+// and the version found, ok is false if none of the versions is known for the component
+func changedItems(component Component, items Items, since []string) (Items, string, bool) {
+	versionMutex.Lock()
+	defer versionMutex.Unlock()
+	for _, version := range since {
+		counts, ok := versionCounts[versionKey(component, version)]
+		if !ok || len(counts) != len(items) {
+			continue
+		}
+		changed := make(Items, 0)
+		for i, item := range items {
+			if item.Count != counts[i] {
+				changed = append(changed, item)
+			}
+		}
+		return changed, version, true
+	}
+	return items, "", false
+}
+
+// tracking service
+var (
+	// serverMutex protects started, server and serverAddr
+	serverMutex sync.Mutex
+	// started is set by Start, even when listening is disabled, and cleared by Shutdown
+	started bool
+	// server is the running tracking service, nil if not started
+	server *http.Server
+	// serverAddr is the address the tracking service listens on
+	serverAddr string
+)
+
+// DefaultOptions returns the options of the tracking service from the environment variables:
+// GOAT_METRICS_IP and GOAT_PORT for the address, GOAT_PORT_FALLBACK for the number of
+// following ports tried when the port is in use, GOAT_UNIX_SOCKET for the unix socket path,
+// GOAT_TLS_CERT, GOAT_TLS_KEY and GOAT_TLS_CLIENT_CA for TLS, GOAT_TOKEN for the bearer token,
+// GOAT_ALLOW_RESET to allow POST /reset without a token, GOAT_OTLP_ENDPOINT, GOAT_OTLP_HEADERS,
+// GOAT_OTLP_INTERVAL and GOAT_OTLP_TRACK_POINTS for the OTLP exporter, GOAT_DISABLE_LISTEN to disable listening
+func DefaultOptions() Options {
+	// DEAD in hexadecimal is 57005 in decimal
+	port := "57005"
+	if os.Getenv("GOAT_PORT") != "" {
+		port = os.Getenv("GOAT_PORT")
+	}
+	expose := os.Getenv("GOAT_METRICS_IP")
+	if expose == "" {
+		expose = "127.0.0.1"
+	}
+	fallback, err := strconv.Atoi(os.Getenv("GOAT_PORT_FALLBACK"))
+	if err != nil || fallback < 0 {
+		fallback = 0
+	}
+	allowReset, _ := strconv.ParseBool(os.Getenv("GOAT_ALLOW_RESET"))
+	otlpInterval, err := time.ParseDuration(os.Getenv("GOAT_OTLP_INTERVAL"))
+	if err != nil || otlpInterval <= 0 {
+		otlpInterval = time.Minute
+	}
+	otlpTrackPoints, _ := strconv.ParseBool(os.Getenv("GOAT_OTLP_TRACK_POINTS"))
+	disabled, _ := strconv.ParseBool(os.Getenv("GOAT_DISABLE_LISTEN"))
+	return Options{
+		Addr:            net.JoinHostPort(expose, port),
+		PortFallback:    fallback,
+		UnixSocket:      os.Getenv("GOAT_UNIX_SOCKET"),
+		TLSCertFile:     os.Getenv("GOAT_TLS_CERT"),
+		TLSKeyFile:      os.Getenv("GOAT_TLS_KEY"),
+		TLSClientCAFile: os.Getenv("GOAT_TLS_CLIENT_CA"),
+		Token:           os.Getenv("GOAT_TOKEN"),
+		AllowReset:      allowReset,
+		OTLPEndpoint:    os.Getenv("GOAT_OTLP_ENDPOINT"),
+		OTLPHeaders:     parseHeaders(os.Getenv("GOAT_OTLP_HEADERS")),
+		OTLPInterval:    otlpInterval,
+		OTLPTrackPoints: otlpTrackPoints,
+		Disabled:        disabled,
+	}
+}
+
+// Handler returns the handler serving /metrics, /track and /session, which can be mounted on an existing server
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", metricsHandler)
+	mux.HandleFunc("/track", trackHandler)
+	mux.HandleFunc("/session/", sessionHandler)
+	return mux
+}
+
+// handler returns the handler of the tracking service, /reset is served if allowReset is set
+func handler(allowReset bool) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/", Handler())
+	mux.HandleFunc("/reset", func(w http.ResponseWriter, r *http.Request) {
+		if !allowReset {
+			http.Error(w, "reset is only allowed with a token or GOAT_ALLOW_RESET", http.StatusForbidden)
+			return
+		}
+		resetHandler(w, r)
+	})
+	return mux
+}
+
+// Start starts the tracking service in the background, it returns an error
+// instead of exiting the process if the service can not listen.
+// It can only be called again after Shutdown, even when listening is disabled
+func Start(opts Options) error {
+	serverMutex.Lock()
+	defer serverMutex.Unlock()
+	if started {
+		if serverAddr == "" {
+			return fmt.Errorf("goat track service already started with listening disabled")
+		}
+		return fmt.Errorf("goat track service already started: %s", serverAddr)
+	}
+	scopeMutex.Lock()
+	scopeComponents = opts.Components
+	scopeMutex.Unlock()
+	if opts.Disabled {
+		log.Printf("Goat track service disabled\n")
+		started = true
+		startExporter(opts)
+		return nil
+	}
+	var listener net.Listener
+	var err error
+	scheme := "http"
+	if opts.UnixSocket != "" {
+		scheme = "unix"
+		listener, err = listenUnix(opts.UnixSocket)
+	} else {
+		listener, err = listen(opts.Addr, opts.PortFallback)
+	}
+	if err != nil {
+		return err
+	}
+	if opts.TLSCertFile != "" || opts.TLSKeyFile != "" {
+		tlsConfig, err := tlsConfigOf(opts)
+		if err != nil {
+			listener.Close()
+			return err
+		}
+		listener = tls.NewListener(listener, tlsConfig)
+		scheme += "s"
+	}
+	srv := &http.Server{Handler: withToken(opts.Token, handler(opts.Token != "" || opts.AllowReset))}
+	started = true
+	server = srv
+	serverAddr = listener.Addr().String()
+	log.Printf("Goat track service started: %s://%s\n", scheme, serverAddr)
+	go func() {
+		if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("Goat track service stopped: %v\n", err)
+		}
+	}()
+	startExporter(opts)
+	return nil
+}
+
+// Shutdown gracefully shuts down the tracking service started by Start
+// and the OTLP exporter, which exports the metrics a last time
+func Shutdown(ctx context.Context) error {
+	serverMutex.Lock()
+	started = false
+	srv := server
+	server = nil
+	serverAddr = ""
+	exp := exporter
+	exporter = nil
+	serverMutex.Unlock()
+	if exp != nil {
+		exp.stop(ctx)
+	}
+	if srv == nil {
+		return nil
+	}
+	return srv.Shutdown(ctx)
+}
+
+// parseHeaders parses the comma separated "key=value" headers
+func parseHeaders(s string) map[string]string {
+	var headers map[string]string
+	for _, field := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			continue
+		}
+		if headers == nil {
+			headers = make(map[string]string)
+		}
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return headers
+}
+
+// OTLP metrics exporter, which pushes the metrics over HTTP/JSON
+var (
+	// exporter is the running OTLP exporter, nil if not started, protected by serverMutex
+	exporter *otlpExporter
+	// startTime is the start time of the cumulative counters which are never reset
+	startTime = time.Now()
+)
+
+// otlpExporter is the OTLP metrics exporter
+type otlpExporter struct {
+	opts   Options
+	client *http.Client
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// startExporter starts the OTLP exporter if the endpoint is set, serverMutex must be held
+func startExporter(opts Options) {
+	if opts.OTLPEndpoint == "" {
+		return
+	}
+	if !strings.Contains(strings.TrimPrefix(strings.TrimPrefix(opts.OTLPEndpoint, "https://"), "http://"), "/") {
+		opts.OTLPEndpoint = strings.TrimSuffix(opts.OTLPEndpoint, "/") + "/v1/metrics"
+	}
+	if opts.OTLPInterval <= 0 {
+		opts.OTLPInterval = time.Minute
+	}
+	exp := &otlpExporter{
+		opts:   opts,
+		client: &http.Client{Timeout: 10 * time.Second},
+		done:   make(chan struct{}),
+	}
+	exp.wg.Add(1)
+	go exp.run()
+	exporter = exp
+	log.Printf("Goat OTLP exporter started: %s every %s\n", opts.OTLPEndpoint, opts.OTLPInterval)
+}
+
+// run exports the metrics every interval until stopped
+func (e *otlpExporter) run() {
+	defer e.wg.Done()
+	ticker := time.NewTicker(e.opts.OTLPInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := e.export(context.Background()); err != nil {
+				log.Printf("Goat OTLP export failed: %v\n", err)
+			}
+		case <-e.done:
+			return
+		}
+	}
+}
+
+// stop stops the exporter and exports the metrics a last time
+func (e *otlpExporter) stop(ctx context.Context) {
+	close(e.done)
+	e.wg.Wait()
+	if err := e.export(ctx); err != nil {
+		log.Printf("Goat OTLP export failed: %v\n", err)
+	}
+}
+
+// export posts the metrics of the scope to the endpoint
+func (e *otlpExporter) export(ctx context.Context) error {
+	cms, err := scope()
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(otlpRequestOf(cms, e.opts.OTLPTrackPoints, time.Now()))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.opts.OTLPEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.opts.OTLPHeaders {
+		req.Header.Set(key, value)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s from %s", resp.Status, e.opts.OTLPEndpoint)
+	}
+	return nil
+}
+
+// OTLP/JSON types of the metrics export request, see opentelemetry-proto
+type (
+	otlpRequest struct {
+		ResourceMetrics []otlpResourceMetrics ` + "`json:\"resourceMetrics\"`" + `
+	}
+	otlpResourceMetrics struct {
+		Resource     otlpResource       ` + "`json:\"resource\"`" + `
+		ScopeMetrics []otlpScopeMetrics ` + "`json:\"scopeMetrics\"`" + `
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute ` + "`json:\"attributes\"`" + `
+	}
+	otlpScopeMetrics struct {
+		Scope   otlpScope    ` + "`json:\"scope\"`" + `
+		Metrics []otlpMetric ` + "`json:\"metrics\"`" + `
+	}
+	otlpScope struct {
+		Name string ` + "`json:\"name\"`" + `
+	}
+	otlpMetric struct {
+		Name        string     ` + "`json:\"name\"`" + `
+		Description string     ` + "`json:\"description,omitempty\"`" + `
+		Unit        string     ` + "`json:\"unit,omitempty\"`" + `
+		Gauge       *otlpGauge ` + "`json:\"gauge,omitempty\"`" + `
+		Sum         *otlpSum   ` + "`json:\"sum,omitempty\"`" + `
+	}
+	otlpGauge struct {
+		DataPoints []otlpDataPoint ` + "`json:\"dataPoints\"`" + `
+	}
+	otlpSum struct {
+		DataPoints             []otlpDataPoint ` + "`json:\"dataPoints\"`" + `
+		AggregationTemporality int             ` + "`json:\"aggregationTemporality\"`" + `
+		IsMonotonic            bool            ` + "`json:\"isMonotonic\"`" + `
+	}
+	otlpDataPoint struct {
+		Attributes        []otlpAttribute ` + "`json:\"attributes\"`" + `
+		StartTimeUnixNano string          ` + "`json:\"startTimeUnixNano,omitempty\"`" + `
+		TimeUnixNano      string          ` + "`json:\"timeUnixNano\"`" + `
+		AsInt             *string         ` + "`json:\"asInt,omitempty\"`" + `
+		AsDouble          *float64        ` + "`json:\"asDouble,omitempty\"`" + `
+	}
+	otlpAttribute struct {
+		Key   string         ` + "`json:\"key\"`" + `
+		Value otlpAnyValue   ` + "`json:\"value\"`" + `
+	}
+	otlpAnyValue struct {
+		StringValue string ` + "`json:\"stringValue\"`" + `
+	}
+)
+
+// otlpAttributes returns the string attributes of the key value pairs
+func otlpAttributes(kvs ...string) []otlpAttribute {
+	attributes := make([]otlpAttribute, 0, len(kvs)/2)
+	for i := 0; i+1 < len(kvs); i += 2 {
+		attributes = append(attributes, otlpAttribute{Key: kvs[i], Value: otlpAnyValue{StringValue: kvs[i+1]}})
+	}
+	return attributes
+}
+
+// otlpInt returns the OTLP/JSON representation of an int value
+func otlpInt(value int) *string {
+	s := strconv.Itoa(value)
+	return &s
+}
+
+// otlpRequestOf returns the export request of the total, covered and ratio of the components,
+// with the hits of each track point if trackPoints is set
+func otlpRequestOf(cms []Component, trackPoints bool, now time.Time) otlpRequest {
+	timestamp := strconv.FormatInt(now.UnixNano(), 10)
+	total := otlpMetric{Name: TRACK_TOTAL, Description: TRACK_TOTAL_DESC, Gauge: &otlpGauge{}}
+	covered := otlpMetric{Name: TRACK_COVERED, Description: TRACK_COVERED_DESC, Gauge: &otlpGauge{}}
+	ratio := otlpMetric{Name: TRACK_COVERAGE_RATIO, Description: TRACK_COVERAGE_RATIO_DESC, Unit: "%", Gauge: &otlpGauge{}}
+	hits := otlpMetric{Name: TRACK_HITS, Description: TRACK_HITS_DESC,
+		Sum: &otlpSum{AggregationTemporality: 2, IsMonotonic: true}}
+	counts := trackCounts()
+	for _, component := range cms {
+		items := componentItems(component, counts)
+		coveredCount := 0
+		for _, item := range items {
+			if item.Count > 0 {
+				coveredCount++
+			}
+			if trackPoints {
+				hits.Sum.DataPoints = append(hits.Sum.DataPoints, otlpDataPoint{
+					Attributes: otlpAttributes("component", GetComponentName(component),
+						"id", strconv.Itoa(item.ID), "name", item.Name),
+					StartTimeUnixNano: strconv.FormatInt(startTimeOf(item.ID).UnixNano(), 10),
+					TimeUnixNano:      timestamp,
+					AsInt:             otlpInt(int(item.Count)),
+				})
+			}
+		}
+		attributes := otlpAttributes("component", GetComponentName(component))
+		coverage := 0.0
+		if len(items) > 0 {
+			coverage = float64(coveredCount) * 100 / float64(len(items))
+		}
+		total.Gauge.DataPoints = append(total.Gauge.DataPoints,
+			otlpDataPoint{Attributes: attributes, TimeUnixNano: timestamp, AsInt: otlpInt(len(items))})
+		covered.Gauge.DataPoints = append(covered.Gauge.DataPoints,
+			otlpDataPoint{Attributes: attributes, TimeUnixNano: timestamp, AsInt: otlpInt(coveredCount)})
+		ratio.Gauge.DataPoints = append(ratio.Gauge.DataPoints,
+			otlpDataPoint{Attributes: attributes, TimeUnixNano: timestamp, AsDouble: &coverage})
+	}
+	metrics := []otlpMetric{total, covered, ratio}
+	if trackPoints {
+		metrics = append(metrics, hits)
+	}
+	scopeMetrics := otlpScopeMetrics{Scope: otlpScope{Name: "goat"}, Metrics: metrics}
+	resourceMetrics := otlpResourceMetrics{
+		Resource:     otlpResource{Attributes: otlpAttributes("service.name", NAME, "service.version", VERSION)},
+		ScopeMetrics: []otlpScopeMetrics{scopeMetrics},
+	}
+	return otlpRequest{ResourceMetrics: []otlpResourceMetrics{resourceMetrics}}
+}
+
+// Addr returns the address the tracking service listens on, empty if not started
+func Addr() string {
+	serverMutex.Lock()
+	defer serverMutex.Unlock()
+	return serverAddr
+}
+
+// listen listens on the address, trying the following fallback ports if the port is in use
+func listen(addr string, fallback int) (net.Listener, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid goat track service address %s: %w", addr, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid goat track service port %s: %w", portStr, err)
+	}
+	var lastErr error
+	for i := 0; i <= fallback; i++ {
+		listener, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port+i)))
+		if err == nil {
+			return listener, nil
+		}
+		lastErr = err
+		// a random port never clashes
+		if port == 0 {
+			break
+		}
+	}
+	return nil, fmt.Errorf("failed to listen goat track service on %s: %w", addr, lastErr)
+}
+
+// listenUnix listens on the unix socket path, a stale socket file is removed first,
+// a socket that still accepts connections belongs to a live service and is kept
+func listenUnix(path string) (net.Listener, error) {
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		conn, err := net.DialTimeout("unix", path, time.Second)
+		if err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to listen goat track service on unix socket %s: in use by another service", path)
+		}
+		os.Remove(path)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen goat track service on unix socket %s: %w", path, err)
+	}
+	return listener, nil
+}
+
+// tlsConfigOf returns the TLS config of the options, client certificates are
+// required and verified if the client CA file is set
+func tlsConfigOf(opts Options) (*tls.Config, error) {
+	if opts.TLSCertFile == "" || opts.TLSKeyFile == "" {
+		return nil, fmt.Errorf("both TLS certificate and key files are required")
+	}
+	cert, err := tls.LoadX509KeyPair(opts.TLSCertFile, opts.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if opts.TLSClientCAFile != "" {
+		data, err := os.ReadFile(opts.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TLS client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificate found in TLS client CA file %s", opts.TLSClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// withToken requires the bearer token for the handler, an empty token disables the check
+func withToken(token string, handler http.Handler) http.Handler {
+	if token == "" {
+		return handler
+	}
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// ServeHTTP start HTTP service scoped to the component of the main entry,
+// a failure to listen is logged without exiting the process
+func ServeHTTP(component Component) {
+	opts := DefaultOptions()
+	opts.Components = []Component{component}
+	if err := Start(opts); err != nil {
+		log.Printf("Goat track service not started: %v\n", err)
+	}
+}
+
+// Snapshot returns the coverage of all components, with the items sorted by track ID
+func Snapshot() Results {
+	return snapshot(components, &trackQuery{order: 2})
+}
+
+// scope returns the default components of /metrics and /track: the component named by
+// GOAT_CURRENT_COMPONENT if set, else the components of the main entry, else all components
+func scope() ([]Component, error) {
+	if currentComponent != "" {
+		componentIdx, ok := componentNamesMap[currentComponent]
+		if !ok {
+			return nil, fmt.Errorf("invalid component %s", currentComponent)
+		}
+		return []Component{componentIdx}, nil
+	}
+	scopeMutex.RLock()
+	defer scopeMutex.RUnlock()
+	if len(scopeComponents) == 0 {
+		return components, nil
+	}
+	return scopeComponents, nil
+}
+
+// componentsName returns the comma separated names of the components, empty if all components are given
+func componentsName(cms []Component) string {
+	if len(cms) == len(components) {
+		return ""
+	}
+	names := make([]string, 0, len(cms))
+	for _, component := range cms {
+		names = append(names, GetComponentName(component))
+	}
+	return strings.Join(names, ",")
+}
+
+// trackQuery is the query of the track items
+type trackQuery struct {
+	// order of the items
+	order int
+	// since returns only the items changed since one of the versions
+	since []string
+	// files, packages and funcs are the globs the track points must match
+	files    []string
+	packages []string
+	funcs    []string
+	// tags returns only the track points with one of the tags
+	tags []string
+	// state returns only the "covered" or "uncovered" items
+	state string
+}
+
+// matchPoint checks if the track point of the item matches the file, package, function and tag filters
+func (q *trackQuery) matchPoint(item Item) bool {
+	if !matchFileGlobs(q.files, item.File) || !matchGlobs(q.packages, item.Package) || !matchGlobs(q.funcs, item.Func) {
+		return false
+	}
+	if len(q.tags) == 0 {
+		return true
+	}
+	for _, tag := range q.tags {
+		for _, itemTag := range item.Tags {
+			if tag == itemTag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchState checks if the item matches the state filter
+func (q *trackQuery) matchState(item Item) bool {
+	switch q.state {
+	case "covered":
+		return item.Count > 0
+	case "uncovered":
+		return item.Count == 0
+	}
+	return true
+}
+
+// matchGlobs checks if s matches one of the globs, an empty globs matches everything.
+// The globs are doublestar globs, as the includes and excludes of goat.yaml:
+// a "**" segment matches zero or more segments, the other segments follow path.Match
+func matchGlobs(globs []string, s string) bool {
+	if len(globs) == 0 {
+		return true
+	}
+	names := splitGlob(s)
+	for _, glob := range globs {
+		if matchGlobSegments(splitGlob(glob), names) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchFileGlobs is matchGlobs for a file, which also matches the globs of its parent directories,
+// so that a glob matching a directory matches every file under it, as in goat.yaml
+func matchFileGlobs(globs []string, file string) bool {
+	if len(globs) == 0 {
+		return true
+	}
+	names := splitGlob(file)
+	for _, glob := range globs {
+		patterns := splitGlob(glob)
+		for i := len(names); i > 0; i-- {
+			if matchGlobSegments(patterns, names[:i]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// splitGlob splits the glob or name into slash separated segments
+func splitGlob(s string) []string {
+	s = strings.Trim(path.Clean(s), "/")
+	if s == "" || s == "." {
+		return nil
+	}
+	return strings.Split(s, "/")
+}
+
+// matchGlobSegments matches the name segments against the glob segments
+func matchGlobSegments(patterns []string, names []string) bool {
+	for len(patterns) > 0 {
+		if patterns[0] == "**" {
+			// collapse consecutive "**" segments
+			for len(patterns) > 0 && patterns[0] == "**" {
+				patterns = patterns[1:]
+			}
+			if len(patterns) == 0 {
+				return true
+			}
+			for i := 0; i <= len(names); i++ {
+				if matchGlobSegments(patterns, names[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(names) == 0 {
+			return false
+		}
+		if matched, err := path.Match(patterns[0], names[0]); err != nil || !matched {
+			return false
+		}
+		patterns = patterns[1:]
+		names = names[1:]
+	}
+	return len(names) == 0
+}
+
+// filterItems returns the items matching the filter
+func filterItems(items Items, match func(Item) bool) Items {
+	filtered := make(Items, 0, len(items))
+	for _, item := range items {
+		if match(item) {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
+
+// snapshot returns the coverage of the components matching the query.
+// The metrics cover the track points matching the file, package, function and tag filters,
+// the items are further filtered by state and by the since versions, and sorted by order
+func snapshot(cms []Component, q *trackQuery) Results {
+	counts := trackCounts()
+	results := make([]ComponentResult, 0, len(cms))
+	for _, component := range cms {
+		items := componentItems(component, counts)
+		version := items.Version()
+		rememberVersion(component, version, items)
+		points := filterItems(items, q.matchPoint)
+		covered := 0
+		for _, item := range points {
+			if item.Count > 0 {
+				covered++
+			}
+		}
+		sinceVersion := ""
+		if len(q.since) > 0 {
+			items, sinceVersion, _ = changedItems(component, items, q.since)
+		}
+		items = filterItems(items, func(item Item) bool {
+			return q.matchPoint(item) && q.matchState(item)
+		})
+		switch q.order {
+		case 0:
+			sort.Slice(items, func(i, j int) bool {
+				return items[i].Count < items[j].Count
+			})
+		case 1:
+			sort.Slice(items, func(i, j int) bool {
+				return items[i].Count > items[j].Count
+			})
+		case 2:
+			sort.Slice(items, func(i, j int) bool {
+				return items[i].ID < items[j].ID
+			})
+		case 3:
+			sort.Slice(items, func(i, j int) bool {
+				return items[i].ID > items[j].ID
+			})
+		}
+		coveredRate := 0
+		if len(points) > 0 {
+			coveredRate = covered * 100 / len(points)
+		}
+		results = append(results, ComponentResult{
+			ID:   component,
+			Name: GetComponentName(component),
+			Metrics: Metrics{
+				Version: version,
+				Since:   sinceVersion,
+				Total:   len(points),
+				Covered: covered,
+				CoveredRate: coveredRate,
+				Items:     items,
+				Tags:      points.TagMetrics(),
+			},
+		})
+	}
+	return Results{Name: NAME, Version: VERSION, Component: componentsName(cms), Results: results, User: userMetrics(q.order)}
+}
+
+// trackQueryOf returns the track query of the request
+func trackQueryOf(r *http.Request) (*trackQuery, error) {
+	query := r.URL.Query()
+	// invalid order:
+	// order=0: count asc (default)
+	// order=1: count desc
+	// order=2: id asc
+	// order=3: id desc
+	order, err := strconv.Atoi(query.Get("order"))
+	if err != nil || order < 0 || order > 3 {
+		order = 0
+	}
+	q := &trackQuery{
+		order:    order,
+		since:    splitQuery(query.Get("since")),
+		files:    splitQuery(query.Get("file")),
+		packages: splitQuery(query.Get("package")),
+		funcs:    splitQuery(query.Get("func")),
+		tags:     splitQuery(query.Get("tag")),
+		state:    query.Get("state"),
+	}
+	for _, globs := range [][]string{q.files, q.packages, q.funcs} {
+		for _, glob := range globs {
+			if _, err := path.Match(glob, ""); err != nil {
+				return nil, fmt.Errorf("invalid glob %q", glob)
+			}
+		}
+	}
+	if q.state != "" && q.state != "covered" && q.state != "uncovered" {
+		return nil, fmt.Errorf("invalid state %q, expected covered or uncovered", q.state)
+	}
+	return q, nil
+}
+
+// splitQuery splits the comma separated query value, nil if empty
+func splitQuery(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// pageCursor is the last item of a page, the component and the track ID, the items are sorted by ID
+// while paginating, so that the next page follows it whatever the counts changed in between
+type pageCursor struct {
+	component Component
+	id        int
+}
+
+// String returns the opaque form of the cursor
+func (c pageCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(c.component) + ":" + strconv.Itoa(c.id)))
+}
+
+// parsePageCursor parses the opaque form of the cursor
+func parsePageCursor(s string) (pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return pageCursor{}, err
+	}
+	componentStr, idStr, ok := strings.Cut(string(data), ":")
+	if !ok {
+		return pageCursor{}, fmt.Errorf("invalid cursor %q", s)
+	}
+	component, err := strconv.Atoi(componentStr)
+	if err != nil {
+		return pageCursor{}, err
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return pageCursor{}, err
+	}
+	return pageCursor{component: component, id: id}, nil
+}
+
+// paginate keeps at most limit items of the results following the cursor, from the first item if nil,
+// the items of each component being sorted by ID, descending if desc,
+// and returns the cursor of the next page, nil if none
+func paginate(results *Results, cursor *pageCursor, limit int, desc bool) *pageCursor {
+	var next *pageCursor
+	var last pageCursor
+	passed := cursor == nil
+	count := 0
+	for i := range results.Results {
+		result := &results.Results[i]
+		page := make(Items, 0)
+		for _, item := range result.Metrics.Items {
+			if next != nil {
+				break
+			}
+			if !passed {
+				if result.ID != cursor.component || (!desc && item.ID <= cursor.id) || (desc && item.ID >= cursor.id) {
+					continue
+				}
+				passed = true
+			}
+			if count == limit {
+				next = &last
+				break
+			}
+			page = append(page, item)
+			last = pageCursor{component: result.ID, id: item.ID}
+			count++
+		}
+		if !passed && cursor != nil && result.ID == cursor.component {
+			// the cursor was the last item of its component
+			passed = true
+		}
+		result.Metrics.Items = page
+	}
+	return next
+}
+
+// selectFields reduces the items of the JSON results to the fields,
+// the numbers are decoded as json.Number so that 64-bit counts keep their precision
+func selectFields(jsonData []byte, fields []string) []byte {
+	var tree map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.UseNumber()
+	if err := decoder.Decode(&tree); err != nil {
+		return jsonData
+	}
+	results, _ := tree["results"].([]interface{})
+	for _, result := range results {
+		component, _ := result.(map[string]interface{})
+		metrics, _ := component["metrics"].(map[string]interface{})
+		items, _ := metrics["items"].([]interface{})
+		for _, item := range items {
+			values, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for key := range values {
+				keep := false
+				for _, field := range fields {
+					keep = keep || key == field
+				}
+				if !keep {
+					delete(values, key)
+				}
+			}
+		}
+	}
+	data, err := json.Marshal(tree)
+	if err != nil {
+		return jsonData
+	}
+	return data
+}
+
+// trackHandler track ID status processing function
+func trackHandler(w http.ResponseWriter, r *http.Request) {
+	q, err := trackQueryOf(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cms, err := scope()
+	if componentStr := r.URL.Query().Get("component"); componentStr != "" {
+		cms, err = componentsOf(componentStr)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// paginate by ?limit= and the opaque ?cursor= of the previous page, sorting the items by ID
+	// so that the pages don't skip or repeat items as the counts change
+	limitStr := r.URL.Query().Get("limit")
+	if limitStr != "" && q.order != 3 {
+		q.order = 2
+	}
+	results := snapshot(cms, q)
+	if limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		var cursor *pageCursor
+		if cursorStr := r.URL.Query().Get("cursor"); cursorStr != "" {
+			c, err := parsePageCursor(cursorStr)
+			if err != nil {
+				http.Error(w, "invalid cursor", http.StatusBadRequest)
+				return
+			}
+			cursor = &c
+		}
+		if next := paginate(&results, cursor, limit, q.order == 3); next != nil {
+			results.NextCursor = next.String()
+		}
+	}
+
+	// output JSON, a client holding the same content gets 304
+	jsonData, _ := json.Marshal(results)
+	if fields := splitQuery(r.URL.Query().Get("fields")); len(fields) > 0 {
+		jsonData = selectFields(jsonData, fields)
+	}
+	gzipped := strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
+	etag := fmt.Sprintf("\"%x\"", md5.Sum(jsonData))
+	if gzipped {
+		etag = fmt.Sprintf("\"%x-gzip\"", md5.Sum(jsonData))
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Vary", "Accept-Encoding")
+	if etagMatch(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !gzipped {
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonData)
+		return
+	}
+	w.Header().Set("Content-Encoding", "gzip")
+	w.WriteHeader(http.StatusOK)
+	gz := gzip.NewWriter(w)
+	gz.Write(jsonData)
+	gz.Close()
+}
+
+// etagMatch checks if the If-None-Match header matches the etag
+func etagMatch(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// componentsOf returns the components of the comma separated names or IDs, all components if empty
+func componentsOf(componentStr string) ([]Component, error) {
+	if componentStr == "" {
+		return components, nil
+	}
+	componentSlice := strings.Split(componentStr, ",")
+	cms := make([]Component, 0, len(componentSlice))
+	for _, componentStr := range componentSlice {
+		componentIdx, ok := componentNamesMap[componentStr]
+		if !ok {
+			// check if it is a number
+			componentIdx, err := strconv.Atoi(componentStr)
+			if err != nil || componentIdx < 0 || componentIdx >= len(components) {
+				return nil, fmt.Errorf("invalid component")
+			}
+			cms = append(cms, Component(componentIdx))
+			continue
+		}
+		cms = append(cms, componentIdx)
+	}
+	return cms, nil
+}
+
+// resetHandler zeroes the counts of the components given by ?component=, all counts if not given
+func resetHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	componentStr := r.URL.Query().Get("component")
+	if componentStr == "" {
+		Reset()
+	} else {
+		cms, err := componentsOf(componentStr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		Reset(cms...)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sessionHandler test session processing function:
+// GET /session/ lists the sessions, GET /session/{name} returns the points hit in the session,
+// POST /session/{name}/start and POST /session/{name}/stop start and stop the session
+func sessionHandler(w http.ResponseWriter, r *http.Request) {
+	name, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/session/"), "/")
+	method := http.MethodGet
+	if action != "" {
+		method = http.MethodPost
+	}
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var err error
+	switch {
+	case name == "" && action == "":
+		writeJSON(w, Sessions())
+		return
+	case action == "":
+		result, err := SessionSnapshot(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, result)
+		return
+	case action == "start":
+		err = StartSession(name)
+	case action == "stop":
+		err = StopSession(name)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeJSON writes the value as JSON
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	jsonData, _ := json.Marshal(v)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonData)
+}
+
+// metricsHandler metrics handler, the OpenMetrics format is served if accepted
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	indicators := [][]string{
+		{TRACK_TOTAL, TRACK_TOTAL_DESC},
+		{TRACK_COVERED, TRACK_COVERED_DESC},
+		{TRACK_COVERAGE_RATIO, TRACK_COVERAGE_RATIO_DESC},
+	}
+
+	targetComponents, err := scope()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	detail := metricsDetail
+	if detailStr := r.URL.Query().Get("detail"); detailStr != "" {
+		detail = splitQuery(detailStr)
+	}
+	for _, level := range detail {
+		valid := false
+		for _, detailLevel := range detailLevels {
+			valid = valid || level == detailLevel
+		}
+		if !valid {
+			http.Error(w, fmt.Sprintf("invalid detail %q, expected package, file or func", level), http.StatusBadRequest)
+			return
+		}
+	}
+	openMetrics := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+	if openMetrics {
+		w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	w.WriteHeader(http.StatusOK)
+
+	// the counts are copied once, the metrics of all components are taken from the same copy
+	counts := trackCounts()
+	componentsItems := make([]Items, len(targetComponents))
+	coverages := make([]int, len(targetComponents))
+	totals := make([]int, len(targetComponents))
+	for i, component := range targetComponents {
+		componentsItems[i] = componentItems(component, counts)
+		for _, item := range componentsItems[i] {
+			if item.Count > 0 {
+				coverages[i]++
+			}
+		}
+		totals[i] = len(componentsItems[i])
+	}
+
+	for _, indicator := range indicators {
+		w.Write([]byte(formatHelp(indicator[0], indicator[1])))
+		for i, component := range targetComponents {
+			switch indicator[0] {
+			case TRACK_COVERAGE_RATIO:
+				ratio := 0.0
+				if totals[i] > 0 {
+					ratio = float64(coverages[i]) * 100 / float64(totals[i])
+				}
+				w.Write([]byte(formatFloatMetric(indicator[0], NAME, VERSION, componentNames[component], ratio)))
+			case TRACK_TOTAL:
+				w.Write([]byte(formatMetric(indicator[0], NAME, VERSION, componentNames[component], totals[i])))
+			case TRACK_COVERED:
+				w.Write([]byte(formatMetric(indicator[0], NAME, VERSION, componentNames[component], coverages[i])))
+			}
+		}
+	}
+
+	// metrics of the tagged and named track IDs
+	if len(trackTags) > 0 {
+		tagMetrics := make([]map[string]TagMetrics, len(targetComponents))
+		for i := range targetComponents {
+			tagMetrics[i] = componentsItems[i].TagMetrics()
+		}
+		tagIndicators := [][]string{
+			{TRACK_TAG_TOTAL, TRACK_TAG_TOTAL_DESC},
+			{TRACK_TAG_COVERED, TRACK_TAG_COVERED_DESC},
+		}
+		for _, indicator := range tagIndicators {
+			w.Write([]byte(formatHelp(indicator[0], indicator[1])))
+			for i, component := range targetComponents {
+				for _, tag := range trackTags {
+					metrics, ok := tagMetrics[i][tag]
+					if !ok {
+						continue
+					}
+					value := metrics.Total
+					if indicator[0] == TRACK_TAG_COVERED {
+						value = metrics.Covered
+					}
+					labels := fmt.Sprintf("tag=\"%s\"", escapeLabel(tag))
+					w.Write([]byte(formatLabeledMetric(indicator[0], NAME, VERSION, componentNames[component], labels, value)))
+				}
+			}
+		}
+	}
+	helped := false
+	for i, component := range targetComponents {
+		for _, item := range componentsItems[i] {
+			if !trackIdNamed[item.ID] {
+				continue
+			}
+			if !helped {
+				w.Write([]byte(formatHelp(TRACK_NAMED_COVERED, TRACK_NAMED_COVERED_DESC)))
+				helped = true
+			}
+			covered := 0
+			if item.Count > 0 {
+				covered = 1
+			}
+			labels := fmt.Sprintf("name=\"%s\",id=\"%d\",tags=\"%s\"", escapeLabel(item.Name), item.ID,
+				escapeLabel(strings.Join(item.Tags, ",")))
+			w.Write([]byte(formatLabeledMetric(TRACK_NAMED_COVERED, NAME, VERSION, componentNames[component], labels, covered)))
+		}
+	}
+
+	// metrics of the user track points, which do not belong to a component
+	if user := userMetrics(2); user != nil {
+		w.Write([]byte(formatHelp(TRACK_USER_TOTAL, TRACK_USER_TOTAL_DESC)))
+		w.Write([]byte(formatUserMetric(TRACK_USER_TOTAL, NAME, VERSION, "", user.Total)))
+		w.Write([]byte(formatHelp(TRACK_USER_COVERED, TRACK_USER_COVERED_DESC)))
+		w.Write([]byte(formatUserMetric(TRACK_USER_COVERED, NAME, VERSION, "", user.Covered)))
+		w.Write([]byte(formatHelp(TRACK_USER_COUNT, TRACK_USER_COUNT_DESC)))
+		for _, item := range user.Items {
+			w.Write([]byte(formatUserMetric(TRACK_USER_COUNT, NAME, VERSION, item.Name, int(item.Count))))
+		}
+	}
+
+	// metrics by package, file and function, capped to metricsMaxSeries series per level
+	for _, level := range detail {
+		series := detailSeriesOf(level, targetComponents, componentsItems)
+		dropped := 0
+		if len(series) > metricsMaxSeries {
+			dropped = len(series) - metricsMaxSeries
+			series = series[:metricsMaxSeries]
+		}
+		totalName := fmt.Sprintf("goat_track_%s_total", level)
+		coveredName := fmt.Sprintf("goat_track_%s_covered", level)
+		w.Write([]byte(formatHelp(totalName, "Goat track total by "+level)))
+		for _, s := range series {
+			w.Write([]byte(formatLabeledMetric(totalName, NAME, VERSION, componentNames[s.component], s.labels, s.metrics.Total)))
+		}
+		w.Write([]byte(formatHelp(coveredName, "Goat track covered by "+level)))
+		for _, s := range series {
+			w.Write([]byte(formatLabeledMetric(coveredName, NAME, VERSION, componentNames[s.component], s.labels, s.metrics.Covered)))
+		}
+		w.Write([]byte(formatHelp(TRACK_DETAIL_DROPPED+"_"+level, TRACK_DETAIL_DROPPED_DESC)))
+		w.Write([]byte(formatUserMetric(TRACK_DETAIL_DROPPED+"_"+level, NAME, VERSION, "", dropped)))
+	}
+
+	// hits of the track points counting hits
+	helped = false
+	for i, component := range targetComponents {
+		for _, item := range componentsItems[i] {
+			if !countedTrackId(item.ID) {
+				continue
+			}
+			if !helped {
+				w.Write([]byte(formatHelpType(TRACK_HITS, TRACK_HITS_DESC, "counter", openMetrics)))
+				helped = true
+			}
+			labels := fmt.Sprintf("id=\"%d\",name=\"%s\"", item.ID, escapeLabel(item.Name))
+			w.Write([]byte(formatLabeledMetric(TRACK_HITS+"_total", NAME, VERSION, componentNames[component], labels, int(item.Count))))
+		}
+	}
+	{{- if .HasAverage }}
+
+	// durations of the timed track points
+	durationIndicators := [][]string{
+		{TRACK_DURATION_SECONDS, TRACK_DURATION_SECONDS_DESC},
+		{TRACK_AVG_DURATION_SECONDS, TRACK_AVG_DURATION_SECONDS_DESC},
+	}
+	for _, indicator := range durationIndicators {
+		helped = false
+		for i, component := range targetComponents {
+			for _, item := range componentsItems[i] {
+				if !timedTrackId(item.ID) {
+					continue
+				}
+				name, duration := indicator[0], item.AvgDuration
+				if name == TRACK_DURATION_SECONDS {
+					name, duration = name+"_total", item.Duration
+				}
+				if !helped {
+					if indicator[0] == TRACK_DURATION_SECONDS {
+						w.Write([]byte(formatHelpType(indicator[0], indicator[1], "counter", openMetrics)))
+					} else {
+						w.Write([]byte(formatHelp(indicator[0], indicator[1])))
+					}
+					helped = true
+				}
+				labels := fmt.Sprintf("id=\"%d\",name=\"%s\"", item.ID, escapeLabel(item.Name))
+				w.Write([]byte(formatLabeledFloatMetric(name, NAME, VERSION, componentNames[component], labels,
+					time.Duration(duration).Seconds())))
+			}
+		}
+	}
+	{{- end }}
+
+	// build information
+	w.Write([]byte(formatHelpType(BUILD_INFO, BUILD_INFO_DESC, "info", openMetrics)))
+	w.Write([]byte(fmt.Sprintf("%s_info{app=\"%s\",version=\"%s\",commit=\"%s\",granularity=\"%s\",data_type=\"%s\",race=\"%t\"} 1\n",
+		BUILD_INFO, escapeLabel(NAME), escapeLabel(VERSION), escapeLabel(BUILD_COMMIT), BUILD_GRANULARITY, BUILD_DATA_TYPE, BUILD_RACE)))
+
+	if openMetrics {
+		w.Write([]byte("# EOF\n"))
+	}
+}
+
+// detailSeries is a series of the detail metrics
+type detailSeries struct {
+	component Component
+	labels    string
+	metrics   TagMetrics
+}
+
+// detailSeriesOf returns the series of the components at the level sorted by component and labels,
+// the track points without a known position are skipped
+func detailSeriesOf(level string, cms []Component, componentsItems []Items) []detailSeries {
+	result := make([]detailSeries, 0)
+	for i, component := range cms {
+		groups := make(map[string]*TagMetrics)
+		for _, item := range componentsItems[i] {
+			labels := detailLabels(level, item)
+			if labels == "" {
+				continue
+			}
+			metrics, ok := groups[labels]
+			if !ok {
+				metrics = &TagMetrics{}
+				groups[labels] = metrics
+			}
+			metrics.Total++
+			if item.Count > 0 {
+				metrics.Covered++
+			}
+		}
+		start := len(result)
+		for labels, metrics := range groups {
+			result = append(result, detailSeries{component: component, labels: labels, metrics: *metrics})
+		}
+		sort.Slice(result[start:], func(i, j int) bool {
+			return result[start+i].labels < result[start+j].labels
+		})
+	}
+	return result
+}
+
+// detailLabels returns the labels of the item at the level, empty if the position is unknown
+func detailLabels(level string, item Item) string {
+	switch level {
+	case "package":
+		if item.Package != "" {
+			return fmt.Sprintf("package=\"%s\"", escapeLabel(item.Package))
+		}
+	case "file":
+		if item.File != "" {
+			return fmt.Sprintf("file=\"%s\"", escapeLabel(item.File))
+		}
+	case "func":
+		if item.Func != "" {
+			return fmt.Sprintf("file=\"%s\",func=\"%s\"", escapeLabel(item.File), escapeLabel(item.Func))
+		}
+	}
+	return ""
+}
+
+// labelEscaper escapes the label values
+var labelEscaper = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n")
+
+// escapeLabel escapes the label value
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+// countedTrackId checks if the track ID counts hits instead of recording whether it is covered
+func countedTrackId(id trackId) bool {
+	{{ if .TrackIdDataTypes -}}
+	if trackIdDataTypes[id] != 0 {
+		return trackIdDataTypes[id] >= 2
+	}
+	{{ end -}}
+	return {{ if ne .DataType 1 }}true{{ else }}false{{ end }}
+}
+
+// formatHelp format help and type
+func formatHelp(name string, help string) string {
+	return fmt.Sprintf("# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+}
+
+// formatHelpType format help and type of a counter or info family, the family name of the
+// Prometheus text format has the suffix of the samples while the OpenMetrics one has not
+func formatHelpType(name string, help string, typ string, openMetrics bool) string {
+	if openMetrics {
+		return fmt.Sprintf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+	suffix, textType := "_total", "counter"
+	if typ == "info" {
+		suffix, textType = "_info", "gauge"
+	}
+	return fmt.Sprintf("# HELP %s%s %s\n# TYPE %s%s %s\n", name, suffix, help, name, suffix, textType)
+}
+
+// formatMetric format metric
+func formatMetric(name, app string, version string, component string, value int) string {
+	return fmt.Sprintf("%s{app=\"%s\",version=\"%s\",component=\"%s\"} %d\n", name, escapeLabel(app), escapeLabel(version),
+		escapeLabel(component), value)
+}
+
+// formatFloatMetric format metric with a float value
+func formatFloatMetric(name, app string, version string, component string, value float64) string {
+	return fmt.Sprintf("%s{app=\"%s\",version=\"%s\",component=\"%s\"} %s\n", name, escapeLabel(app), escapeLabel(version),
+		escapeLabel(component), strconv.FormatFloat(value, 'f', -1, 64))
+}
+
+// formatUserMetric format user track metric, the name label is omitted if empty
+func formatUserMetric(name, app string, version string, userName string, value int) string {
+	if userName == "" {
+		return fmt.Sprintf("%s{app=\"%s\",version=\"%s\"} %d\n", name, escapeLabel(app), escapeLabel(version), value)
+	}
+	return fmt.Sprintf("%s{app=\"%s\",version=\"%s\",name=\"%s\"} %d\n", name, escapeLabel(app), escapeLabel(version),
+		escapeLabel(userName), value)
+}
+
+// formatLabeledFloatMetric format metric with extra labels and a float value
+func formatLabeledFloatMetric(name, app string, version string, component string, labels string, value float64) string {
+	return fmt.Sprintf("%s{app=\"%s\",version=\"%s\",component=\"%s\",%s} %s\n", name, escapeLabel(app), escapeLabel(version),
+		escapeLabel(component), labels, strconv.FormatFloat(value, 'f', -1, 64))
+}
+
+// formatLabeledMetric format metric with extra labels
+func formatLabeledMetric(name, app string, version string, component string, labels string, value int) string {
+	return fmt.Sprintf("%s{app=\"%s\",version=\"%s\",component=\"%s\",%s} %d\n", name, escapeLabel(app), escapeLabel(version),
+		escapeLabel(component), labels, value)
+}
+
+{{ define "counterRef" -}}
+	{{ if eq .Layout "padded" }}trackIdStatus[id].n{{ else if eq .Layout "sharded" }}trackIdStatus[trackShardOf()].counts[id]{{ else }}trackIdStatus[id]{{ end }}
+{{- end -}}
+{{- define "boolRef" -}}
+	{{ if eq .Layout "sharded" }}trackIdStatus[0].counts[id]{{ else }}{{ template "counterRef" . }}{{ end }}
+{{- end -}}
+{{- define "trackBool" -}}
+	// the store is skipped once hit, so that the cache line stays shared between the cores
+	{{ if .Race -}}
+	if atomic.LoadUint{{ .Bits }}(&{{ template "boolRef" . }}) == 0 {
+		atomic.StoreUint{{ .Bits }}(&{{ template "boolRef" . }}, 1)
+	}
+	{{- else -}}
+	if {{ template "boolRef" . }} == 0 {
+		{{ template "boolRef" . }} = 1
+	}
+	{{- end -}}
+{{- end -}}
+{{- define "trackReset" -}}
+	{{ if eq .Layout "sharded" -}}
+	for shard := range trackIdStatus {
+		{{ if .Race -}}
+		atomic.StoreUint{{ .Bits }}(&trackIdStatus[shard].counts[id], 0)
+		{{- else -}}
+		trackIdStatus[shard].counts[id] = 0
+		{{- end }}
+	}
+	{{- else if .Race -}}
+	atomic.StoreUint{{ .Bits }}(&{{ template "counterRef" . }}, 0)
+	{{- else -}}
+	{{ template "counterRef" . }} = 0
+	{{- end }}
+	{{- if .Race -}}
+	{{- if .HasAverage }}
+	atomic.StoreUint64(&trackIdDurations[id], 0)
+	{{- end }}
+	{{- if .Timestamps }}
+	atomic.StoreInt64(&trackIdFirstHit[id], 0)
+	atomic.StoreInt64(&trackIdLastHit[id], 0)
+	{{- end }}
+	{{- else -}}
+	{{- if .HasAverage }}
+	trackIdDurations[id] = 0
+	{{- end }}
+	{{- if .Timestamps }}
+	trackIdFirstHit[id] = 0
+	trackIdLastHit[id] = 0
+	{{- end }}
+	{{- end -}}
+{{- end -}}
+{{- define "trackTime" -}}
+	// the last-hit time is written at most once a second to keep hot track points cheap
+	now := time.Now().Unix()
+	{{ if .Race -}}
+	atomic.CompareAndSwapInt64(&trackIdFirstHit[id], 0, now)
+	if atomic.LoadInt64(&trackIdLastHit[id]) != now {
+		atomic.StoreInt64(&trackIdLastHit[id], now)
+	}
+	{{- else -}}
+	if trackIdFirstHit[id] == 0 {
+		trackIdFirstHit[id] = now
+	}
+	if trackIdLastHit[id] != now {
+		trackIdLastHit[id] = now
+	}
+	{{- end -}}
+{{- end -}}
+{{- define "trackCount" -}}
+	{{ if .Race -}}
+	atomic.AddUint{{ .Bits }}(&{{ template "counterRef" . }}, 1)
+	{{- else -}}
+	{{ template "counterRef" . }}++
+	{{- end -}}
+{{- end -}}
+
+`
+
+// constantsTemplate is the exported metric names and build information shared by Template and StubTemplate
+const constantsTemplate = `// track metrics
+const (
+	TRACK_COVERAGE_RATIO = "goat_track_coverage_ratio"
+	TRACK_TOTAL          = "goat_track_total"
+	TRACK_COVERED        = "goat_track_covered"
+	TRACK_TAG_TOTAL      = "goat_track_tag_total"
+	TRACK_TAG_COVERED    = "goat_track_tag_covered"
+	TRACK_NAMED_COVERED  = "goat_track_named_covered"
+	TRACK_USER_TOTAL     = "goat_track_user_total"
+	TRACK_USER_COVERED   = "goat_track_user_covered"
+	TRACK_USER_COUNT     = "goat_track_user_count"
+	TRACK_HITS           = "goat_track_hits"
+	TRACK_DETAIL_DROPPED = "goat_track_detail_dropped"
+	TRACK_DURATION_SECONDS     = "goat_track_duration_seconds"
+	TRACK_AVG_DURATION_SECONDS = "goat_track_avg_duration_seconds"
+	BUILD_INFO           = "goat_build"
+)
+
+// track metrics description
+const (
+	TRACK_COVERAGE_RATIO_DESC = "Goat track coverage ratio in percent"
+	TRACK_TOTAL_DESC          = "Goat track total"
+	TRACK_COVERED_DESC        = "Goat track covered"
+	TRACK_TAG_TOTAL_DESC      = "Goat track total by tag"
+	TRACK_TAG_COVERED_DESC    = "Goat track covered by tag"
+	TRACK_NAMED_COVERED_DESC  = "Goat named track covered"
+	TRACK_USER_TOTAL_DESC     = "Goat user track total"
+	TRACK_USER_COVERED_DESC   = "Goat user track covered"
+	TRACK_USER_COUNT_DESC     = "Goat user track count"
+	TRACK_HITS_DESC           = "Goat track hits"
+	TRACK_DETAIL_DROPPED_DESC = "Goat detail series dropped by the cardinality cap"
+	TRACK_DURATION_SECONDS_DESC     = "Goat cumulative duration of the timed track calls"
+	TRACK_AVG_DURATION_SECONDS_DESC = "Goat average duration of the timed track calls"
+	BUILD_INFO_DESC           = "Goat build information"
+)
+
+// build information
+const (
+	BUILD_COMMIT      = "{{.Commit}}"
+	BUILD_GRANULARITY = "{{.Granularity}}"
+	BUILD_DATA_TYPE   = "{{ if eq .DataType 3 }}average{{ else if eq .DataType 2 }}count{{ else }}bool{{ end }}"
+	BUILD_RACE        = {{.Race}}
+)
+`
+
+// componentTrackIdsTemplate is the exported track IDs of the components shared by Template and StubTemplate
+const componentTrackIdsTemplate = `{{range .Components}}
+// Track IDs for component {{ .ID }}
+var COMPONENT_{{ .ID }}_TRACK_IDS = []trackId{ {{- range .TrackIds}}
+	TRACK_ID_{{.}},{{end}}
+	// ...
+}{{end}}
+
+// Component to track ID mapping
+var COMPONENT_TRACK_IDS = map[Component][]trackId{ {{- range .Components}}
+	COMPONENT_{{ .ID }}: COMPONENT_{{ .ID }}_TRACK_IDS,{{end}}
+	// ...
+}
+`
+
+// typesTemplate is the exported types shared by Template and StubTemplate
+const typesTemplate = `// Item struct
+type Item struct {
+	// track ID
+	ID int ` + "`json:\"id\"`" + `
+	// track name
+	Name string ` + "`json:\"name\"`" + `
+	// track tags
+	Tags []string ` + "`json:\"tags,omitempty\"`" + `
+	// source file
+	File string ` + "`json:\"file,omitempty\"`" + `
+	// package import path
+	Package string ` + "`json:\"package,omitempty\"`" + `
+	// enclosing function
+	Func string ` + "`json:\"func,omitempty\"`" + `
+	// source line
+	Line int ` + "`json:\"line,omitempty\"`" + `
+	// track count
+	Count uint64 ` + "`json:\"count\"`" + `
+	// first-hit Unix time, set if timestamps are recorded
+	FirstHit int64 ` + "`json:\"firstHit,omitempty\"`" + `
+	// last-hit Unix time, set if timestamps are recorded
+	LastHit int64 ` + "`json:\"lastHit,omitempty\"`" + `
+	// cumulative duration of the calls in nanoseconds, set for the average data type
+	Duration uint64 ` + "`json:\"duration,omitempty\"`" + `
+	// average duration of the calls in nanoseconds, set for the average data type
+	AvgDuration uint64 ` + "`json:\"avgDuration,omitempty\"`" + `
+}
+
+// Items slice
+type Items []Item
+
+// TagMetrics struct
+type TagMetrics struct {
+	// total track count of the tag
+	Total int ` + "`json:\"total\"`" + `
+	// covered track count of the tag
+	Covered int ` + "`json:\"covered\"`" + `
+}
+
+// Metrics struct
+type Metrics struct {
+	// version
+	Version string ` + "`json:\"version\"`" + `
+	// version the items changed since, empty if all items are returned
+	Since string ` + "`json:\"since,omitempty\"`" + `
+	// total track count
+	Total       int    ` + "`json:\"total\"`" + `
+	// covered track count
+	Covered     int    ` + "`json:\"covered\"`" + `
+	// covered rate
+	CoveredRate int    ` + "`json:\"coveredRate\"`" + `
+	// track items
+	Items       Items  ` + "`json:\"items\"`" + `
+	// metrics by tag
+	Tags map[string]TagMetrics ` + "`json:\"tags,omitempty\"`" + `
+}
+
+// ComponentResult struct
+type ComponentResult struct {
+	// component
+	ID Component ` + "`json:\"id\"`" + `
+	// component name
+	Name string ` + "`json:\"name\"`" + `
+	// metrics
+	Metrics   Metrics   ` + "`json:\"metrics\"`" + `
+}
+
+// UserItem struct
+type UserItem struct {
+	// user track name
+	Name string ` + "`json:\"name\"`" + `
+	// user track count
+	Count uint32 ` + "`json:\"count\"`" + `
+}
+
+// UserMetrics struct
+type UserMetrics struct {
+	// total user track count
+	Total int ` + "`json:\"total\"`" + `
+	// covered user track count
+	Covered int ` + "`json:\"covered\"`" + `
+	// covered rate
+	CoveredRate int ` + "`json:\"coveredRate\"`" + `
+	// user track items
+	Items []UserItem ` + "`json:\"items\"`" + `
+}
+
+// Results struct
+type Results struct {
+	// name
+	Name string ` + "`json:\"name\"`" + `
+	// version
+	Version string ` + "`json:\"version\"`" + `
+	// component names of the results, empty if they cover all components
+	Component string ` + "`json:\"component,omitempty\"`" + `
+	// results
+	Results []ComponentResult ` + "`json:\"results\"`" + `
+	// user track points
+	User *UserMetrics ` + "`json:\"user,omitempty\"`" + `
+	// cursor of the next page, empty on the last page
+	NextCursor string ` + "`json:\"nextCursor,omitempty\"`" + `
+}
+
+// Session struct
+type Session struct {
+	// session name
+	Name string ` + "`json:\"name\"`" + `
+	// component names of the results, empty if they cover all components
+	Component string ` + "`json:\"component,omitempty\"`" + `
+	// whether the session is running
+	Running bool ` + "`json:\"running\"`" + `
+	// start time
+	StartedAt time.Time ` + "`json:\"startedAt\"`" + `
+	// stop time, nil while running
+	StoppedAt *time.Time ` + "`json:\"stoppedAt,omitempty\"`" + `
+	// track points hit in the session by component, the count is the hits in the session
+	Results []ComponentResult ` + "`json:\"results\"`" + `
+}
+
+// Options is the options of the tracking service
+type Options struct {
+	// Addr is the address to listen on, e.g. "127.0.0.1:57005"
+	Addr string
+	// PortFallback is the number of following ports tried when the port is in use
+	PortFallback int
+	// Components is the default scope of /metrics and /track, all components if empty,
+	// GOAT_CURRENT_COMPONENT overrides it
+	Components []Component
+	// UnixSocket is the path of the unix socket to listen on instead of Addr
+	UnixSocket string
+	// TLSCertFile and TLSKeyFile enable TLS with the certificate and key files
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSClientCAFile requires client certificates signed by the CA file
+	TLSClientCAFile string
+	// Token requires the "Authorization: Bearer <token>" header
+	Token string
+	// AllowReset allows POST /reset without a token
+	AllowReset bool
+	// OTLPEndpoint enables the OTLP exporter, "/v1/metrics" is appended if it has no path
+	OTLPEndpoint string
+	// OTLPHeaders is the headers of the export requests, e.g. authorization
+	OTLPHeaders map[string]string
+	// OTLPInterval is the export interval, one minute by default
+	OTLPInterval time.Duration
+	// OTLPTrackPoints exports the hits of each track point as well
+	OTLPTrackPoints bool
+	// Disabled disables listening, the tracking points are still recorded
+	Disabled bool
+}
+`
+
+// StubTemplate is the no-op stub of the tracking runtime,
+// which is compiled in place of Template when the build tag is not set
+const StubTemplate = `//go:build !{{ .BuildTag }}
+
+// +goat: generated by increment tracking package, do not edit
+// no-op stub of the tracking runtime, build with "-tags {{ .BuildTag }}" to enable tracking
+package {{.PackageName}}
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// application version
+const VERSION = "{{.Version}}"
+// application name
+const NAME = "{{.Name}}"
+// track ID type
+type trackId = int
+// track ID values
+const (
+	// track ID start value
+	TRACK_ID_START = iota{{range .TrackIds}}
+	TRACK_ID_{{.}}{{end}}
+	// ...
+	// track ID end value
+	TRACK_ID_END
+)
+
+// track ID names
+var TrackIdNames [TRACK_ID_END]string
+
+// track ID tags
+var TrackIdTags [TRACK_ID_END][]string
+
+` + constantsTemplate + `
+// Track track function, a no-op without the build tag
+func Track(id trackId) {}
+
+// TrackCond returns the operand of a condition unchanged without the build tag
+func TrackCond[T ~bool](trueId, falseId trackId, v T) T {
+	return v
+}
+{{ if .HasAverage }}
+// TrackTime times a track function call, a no-op without the build tag
+func TrackTime(id trackId) func() {
+	return func() {}
+}
+{{ end }}
+// Register registers a user track point, a no-op without the build tag
+func Register(name string) {}
+
+// TrackNamed tracks a user track point, a no-op without the build tag
+func TrackNamed(name string) {}
+
+// Component type
+type Component = int
+
+// Component IDs
+const (
+	_           = iota - 1 {{range .Components}}
+	COMPONENT_{{ .ID }} // {{ .ID }}{{end}}
+	// ...
+)
+
+// Component names
+var componentNames = []string{ {{- range .Components}}
+	COMPONENT_{{ .ID }}: "{{ .Name }}",{{end}}
+	// ...
+}
+
+// GetComponentName get component name
+func GetComponentName(component Component) string {
+	return componentNames[component]
+}
+` + componentTrackIdsTemplate + `
+` + typesTemplate + `
+// DefaultOptions returns the options of the tracking service, a no-op without the build tag
+func DefaultOptions() Options {
+	return Options{Disabled: true}
+}
+
+// Handler returns the handler of the tracking service, which serves nothing without the build tag
+func Handler() http.Handler {
+	return http.NotFoundHandler()
+}
+
+// Start starts the tracking service, a no-op that can be called repeatedly without the build tag
+func Start(opts Options) error {
+	return nil
+}
+
+// Shutdown shuts down the tracking service, a no-op without the build tag
+func Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// Addr returns the address the tracking service listens on, always empty without the build tag
+func Addr() string {
+	return ""
+}
+
+// ServeHTTP start HTTP service, a no-op without the build tag
+func ServeHTTP(component Component) {}
+
+// Snapshot returns the coverage of all components, always empty without the build tag
+func Snapshot() Results {
+	return Results{Name: NAME, Version: VERSION}
+}
+
+// Reset zeroes the counts of the components, a no-op without the build tag
+func Reset(cms ...Component) {}
+
+// StartSession starts the named test session, a no-op without the build tag
+func StartSession(name string) error {
+	return nil
+}
+
+// StopSession stops the named test session, a no-op without the build tag
+func StopSession(name string) error {
+	return nil
+}
+
+// Sessions returns the names of the test sessions, always empty without the build tag
+func Sessions() []string {
+	return nil
+}
+
+// SessionSnapshot returns the track points hit in the named test session, always an error without the build tag
+func SessionSnapshot(name string) (Session, error) {
+	return Session{}, fmt.Errorf("session %q not found", name)
+}
+`
+
+const TrackImportPathPlaceHolder = `github.com/monshunter/goat/goat`
+const TrackStmtPlaceHolder = `goat.Track(TRACK_ID)`
+
+// TrackCondPlaceHolder is the prefix of the call wrapping a condition operand, the operand and ")" follow
+const TrackCondPlaceHolder = `goat.TrackCond(TRACK_ID, TRACK_ID,`
+
+func GetMainEntryInsertData(ident string, componentID int) []string {
+	return []string{
 		config.TrackMainEntryComment,
 		config.TrackTipsComment,
 		fmt.Sprintf("%s.ServeHTTP(%s.COMPONENT_%d)", ident, ident, componentID),
@@ -412,6 +2566,25 @@ func IncreamentReplaceStmt(ident string, start int) func(older string) (newer st
 	}
 }
 
+// IncreamentReplaceCond numbers the true and false track IDs of the wrapped condition operands from start
+func IncreamentReplaceCond(ident string, start int) func(older string) (newer string) {
+	return func(older string) (newer string) {
+		newer = fmt.Sprintf(`%s.TrackCond(%s.TRACK_ID_%d, %s.TRACK_ID_%d,`, ident, ident, start, ident, start+1)
+		start += 2
+		return
+	}
+}
+
+// IncreamentReplaceTimedStmt is IncreamentReplaceStmt for the average data type,
+// the deferred call records the duration of the enclosing function
+func IncreamentReplaceTimedStmt(ident string, start int) func(older string) (newer string) {
+	return func(older string) (newer string) {
+		newer = fmt.Sprintf(`defer %s.TrackTime(%s.TRACK_ID_%d)()`, ident, ident, start)
+		start++
+		return
+	}
+}
+
 func IncreamentReplaceImport(alias string, importPath string) func(older string) (newer string) {
 	return func(older string) (newer string) {
 		newer = fmt.Sprintf(`%s "%s"`, alias, importPath)
@@ -415,3 +415,221 @@ func TestTemplateSyntax(t *testing.T) {
 		t.Errorf("Template has unbalanced parentheses: %d open vs %d close", openParens, closeParens)
 	}
 }
+
+func TestTemplateDataTypeOverrides(t *testing.T) {
+	values := &Values{
+		PackageName: "testtrack",
+		Version:     "1.0.0",
+		Name:        "TestApp",
+		TrackIds:    []int{1, 2, 3},
+		Race:        true,
+		DataType:    1,
+	}
+	values.SetTrackIdDataType(2, 2)
+	values.SetTrackIdDataType(3, 1)
+
+	result, err := values.Render()
+	if err != nil {
+		t.Fatalf("Failed to render template: %v", err)
+	}
+	renderedCode := string(result)
+
+	expected := []string{
+		"var trackIdDataTypes = [TRACK_ID_END]uint8{",
+		"TRACK_ID_2: 2,",
+		"if trackIdDataTypes[id] >= 2 {",
+		"atomic.AddUint32(&trackIdStatus[id], 1)",
+		"atomic.StoreUint32(&trackIdStatus[id], 1)",
+	}
+	for _, e := range expected {
+		if !strings.Contains(renderedCode, e) {
+			t.Errorf("Expected rendered code to contain %q", e)
+		}
+	}
+	// track ID 3 uses the default data type, so it must not be listed
+	if strings.Contains(renderedCode, "TRACK_ID_3: ") {
+		t.Errorf("Track ID with the default data type should not be overridden")
+	}
+
+	// without overrides the data type table is not generated
+	values.TrackIdDataTypes = nil
+	result, err = values.Render()
+	if err != nil {
+		t.Fatalf("Failed to render template: %v", err)
+	}
+	if strings.Contains(string(result), "trackIdDataTypes") {
+		t.Errorf("Expected no data type table without overrides")
+	}
+}
+
+func TestTemplateAverage(t *testing.T) {
+	values := &Values{
+		PackageName: "testtrack",
+		Version:     "1.0.0",
+		Name:        "TestApp",
+		TrackIds:    []int{1, 2},
+		DataType:    1,
+	}
+	result, err := values.Render()
+	if err != nil {
+		t.Fatalf("Failed to render template: %v", err)
+	}
+	if strings.Contains(string(result), "TrackTime") {
+		t.Errorf("TrackTime should not be generated without the average data type")
+	}
+
+	values.SetTrackIdDataType(2, 3)
+	values.BuildTag = "goat"
+	for _, render := range []func() ([]byte, error){values.Render, values.RenderStub} {
+		result, err := render()
+		if err != nil {
+			t.Fatalf("Failed to render template: %v", err)
+		}
+		if !strings.Contains(string(result), "func TrackTime(id trackId) func() {") {
+			t.Errorf("Expected rendered code to contain TrackTime:\n%s", result)
+		}
+	}
+	if got := IncreamentReplaceTimedStmt("goat", 5)(TrackStmtPlaceHolder); got != "defer goat.TrackTime(goat.TRACK_ID_5)()" {
+		t.Errorf("IncreamentReplaceTimedStmt() = %s", got)
+	}
+}
+
+func TestTemplateTrackAttrs(t *testing.T) {
+	values := &Values{
+		PackageName: "testtrack",
+		Version:     "1.0.0",
+		Name:        "TestApp",
+		TrackIds:    []int{1, 2, 3},
+		DataType:    1,
+	}
+	values.SetTrackIdAttrs(1, Attrs{Name: "refund-flow", Tags: []string{"payments", "critical"}})
+	values.SetTrackIdAttrs(3, Attrs{Tags: []string{"critical"}})
+
+	result, err := values.Render()
+	if err != nil {
+		t.Fatalf("Failed to render template: %v", err)
+	}
+	renderedCode := string(result)
+
+	expected := []string{
+		`TrackIdNames[TRACK_ID_1] = "refund-flow"`,
+		`trackIdNamed[TRACK_ID_1] = true`,
+		`TrackIdTags[TRACK_ID_1] = []string{"payments", "critical"}`,
+		`TrackIdTags[TRACK_ID_3] = []string{"critical"}`,
+		"var trackTags = []string{\n\t\"critical\",\n\t\"payments\",\n}",
+		`TRACK_TAG_COVERED    = "goat_track_tag_covered"`,
+	}
+	for _, e := range expected {
+		if !strings.Contains(renderedCode, e) {
+			t.Errorf("Expected rendered code to contain %q", e)
+		}
+	}
+	if strings.Contains(renderedCode, "TrackIdNames[TRACK_ID_3]") {
+		t.Errorf("Track ID without a name should keep the default name")
+	}
+}
+
+func TestTemplateUserTracks(t *testing.T) {
+	testCases := []struct {
+		name     string
+		race     bool
+		dataType int
+		expected []string
+	}{
+		{name: "bool race", race: true, dataType: 1, expected: []string{"atomic.StoreUint32(status, 1)", "atomic.LoadUint32(userTrackStatus[name])"}},
+		{name: "count race", race: true, dataType: 2, expected: []string{"atomic.AddUint32(status, 1)"}},
+		{name: "bool", race: false, dataType: 1, expected: []string{"*status = 1", "count := *userTrackStatus[name]"}},
+		{name: "count", race: false, dataType: 2, expected: []string{"*status++"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			values := &Values{
+				PackageName: "testtrack",
+				Version:     "1.0.0",
+				Name:        "TestApp",
+				TrackIds:    []int{1},
+				Race:        tc.race,
+				DataType:    tc.dataType,
+			}
+			result, err := values.Render()
+			if err != nil {
+				t.Fatalf("Failed to render template: %v", err)
+			}
+			renderedCode := string(result)
+			expected := append([]string{
+				"func Register(name string) {",
+				"func TrackNamed(name string) {",
+				"User *UserMetrics `json:\"user,omitempty\"`",
+				"User: userMetrics(q.order)",
+				`TRACK_USER_COUNT     = "goat_track_user_count"`,
+			}, tc.expected...)
+			for _, e := range expected {
+				if !strings.Contains(renderedCode, e) {
+					t.Errorf("Expected rendered code to contain %q", e)
+				}
+			}
+			if !tc.race && strings.Contains(renderedCode, "atomic.") {
+				t.Errorf("Expected no atomic operations when Race=false")
+			}
+		})
+	}
+}
+
+func TestTemplateBuildTag(t *testing.T) {
+	values := &Values{
+		PackageName: "testtrack",
+		Version:     "1.0.0",
+		Name:        "TestApp",
+		TrackIds:    []int{1, 2},
+		Components:  []Component{{ID: 0, Name: "app", TrackIds: []int{1, 2}}},
+		DataType:    1,
+	}
+
+	// without a build tag the runtime is always compiled in and there is no stub
+	result, err := values.Render()
+	if err != nil {
+		t.Fatalf("Failed to render template: %v", err)
+	}
+	if !strings.HasPrefix(string(result), "// +goat: generated") {
+		t.Errorf("Expected no build constraint without a build tag")
+	}
+	if _, err := values.RenderStub(); err == nil {
+		t.Errorf("Expected RenderStub to fail without a build tag")
+	}
+
+	values.BuildTag = "goat"
+	result, err = values.Render()
+	if err != nil {
+		t.Fatalf("Failed to render template: %v", err)
+	}
+	if !strings.HasPrefix(string(result), "//go:build goat\n\n// +goat: generated") {
+		t.Errorf("Expected the runtime to be guarded by the build tag")
+	}
+
+	stub, err := values.RenderStub()
+	if err != nil {
+		t.Fatalf("Failed to render stub: %v", err)
+	}
+	stubCode := string(stub)
+	expected := []string{
+		"//go:build !goat\n\n",
+		"package testtrack",
+		"TRACK_ID_1\n\tTRACK_ID_2",
+		"COMPONENT_0 // 0",
+		"func Track(id trackId) {}",
+		"func Register(name string) {}",
+		"func TrackNamed(name string) {}",
+		"func ServeHTTP(component Component) {}",
+		"func GetComponentName(component Component) string {",
+	}
+	for _, e := range expected {
+		if !strings.Contains(stubCode, e) {
+			t.Errorf("Expected stub to contain %q", e)
+		}
+	}
+	for _, pkg := range []string{`"sync`, `"encoding/json"`, `"os"`} {
+		if strings.Contains(stubCode, pkg) {
+			t.Errorf("Expected stub not to import %s", pkg)
+		}
+	}
+}
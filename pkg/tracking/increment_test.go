@@ -0,0 +1,1093 @@
+package tracking
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/monshunter/goat/pkg/config"
+	"github.com/monshunter/goat/pkg/diff"
+	increament "github.com/monshunter/goat/pkg/tracking/increment"
+)
+
+// trackSource tracks the source as if every line of it has changed
+// and returns the number of tracking points and the tracked content
+func trackSource(t *testing.T, source string, granularity config.Granularity) (int, string) {
+	t.Helper()
+	lines := strings.Count(source, "\n") + 1
+	return trackSourceChanges(t, source, granularity, diff.LineChanges{{Start: 1, Lines: lines}})
+}
+
+// trackSourceChanges tracks the source with the given line changes
+// and returns the number of tracking points and the tracked content
+func trackSourceChanges(t *testing.T, source string, granularity config.Granularity,
+	lineChanges diff.LineChanges) (int, string) {
+	t.Helper()
+	return trackSourceWith(t, source, granularity, lineChanges, nil)
+}
+
+// trackSourceWith tracks the source with the given line changes and the tracker set up by setup
+// and returns the number of tracking points and the tracked content
+func trackSourceWith(t *testing.T, source string, granularity config.Granularity,
+	lineChanges diff.LineChanges, setup func(tracker *IncrementalTrack)) (int, string) {
+	t.Helper()
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(fileName, []byte(source), 0644); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+	tracker, err := NewIncrementalTrack(dir, &diff.FileChange{Path: "main.go", LineChanges: lineChanges},
+		increament.TrackImportPathPlaceHolder, increament.GetPackageInsertStmts(), granularity, nil)
+	if err != nil {
+		t.Fatalf("Failed to create tracker: %v", err)
+	}
+	if setup != nil {
+		setup(tracker)
+	}
+	count, err := tracker.Track()
+	if err != nil {
+		t.Fatalf("Failed to track: %v", err)
+	}
+	return count, string(tracker.Content())
+}
+
+// trackedLinesOf returns the source lines directly following each inserted tracking block
+func trackedLinesOf(content string) []string {
+	lines := strings.Split(content, "\n")
+	tracked := []string{}
+	for i, line := range lines {
+		if strings.TrimSpace(line) == config.TrackEndComment && i+1 < len(lines) {
+			tracked = append(tracked, strings.TrimSpace(lines[i+1]))
+		}
+	}
+	return tracked
+}
+
+func TestIncrementalTrackIgnoreDirectives(t *testing.T) {
+	testCases := []struct {
+		name    string
+		source  string
+		want    int
+		tracked []string
+	}{
+		{
+			name: "ignored function",
+			source: `package main
+
+//goat:ignore hot path
+func hot() {
+	a := 1
+	_ = a
+}
+
+func cold() {
+	b := 2
+	_ = b
+}
+`,
+			want:    1,
+			tracked: []string{"b := 2"},
+		},
+		{
+			name: "ignored block",
+			source: `package main
+
+func f(items []int) int {
+	sum := 0
+	//goat:ignore-start
+	for _, item := range items {
+		sum += item
+	}
+	//goat:ignore-end
+	return sum
+}
+`,
+			want:    2,
+			tracked: []string{"sum := 0", "return sum"},
+		},
+		{
+			name: "ignored file",
+			source: `//goat:ignore-file tooling
+package main
+
+func f() {
+	a := 1
+	_ = a
+}
+`,
+			want: 0,
+		},
+		{
+			name: "directive after the first declaration does not ignore the file",
+			source: `package main
+
+func f() {
+	a := 1
+	_ = a
+}
+
+//goat:ignore-file
+`,
+			want:    1,
+			tracked: []string{"a := 1"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			count, content := trackSource(t, tc.source, config.GranularityPatch)
+			if count != tc.want {
+				t.Fatalf("Track() count = %d, want %d\n%s", count, tc.want, content)
+			}
+			tracked := trackedLinesOf(content)
+			if strings.Join(tracked, "|") != strings.Join(tc.tracked, "|") {
+				t.Errorf("tracked lines = %q, want %q\n%s", tracked, tc.tracked, content)
+			}
+			for _, directive := range []string{config.IgnoreDirective, config.IgnoreStartDirective,
+				config.IgnoreEndDirective, config.IgnoreFileDirective} {
+				if strings.Contains(tc.source, directive+"\n") && !strings.Contains(content, directive) {
+					t.Errorf("directive %s was removed from the tracked content", directive)
+				}
+			}
+		})
+	}
+}
+
+func TestIncrementalTrackNameDirective(t *testing.T) {
+	source := `package main
+
+func refund(amount int) int {
+	if amount < 0 {
+		return 0
+	}
+	//goat:name refund-flow tags=payments,critical
+	// the fee is charged once
+	fee := amount / 10
+
+	//goat:name unused
+
+	return amount - fee
+}
+`
+	count, content := trackSource(t, source, config.GranularityLine)
+	if count == 0 {
+		t.Fatalf("Track() count = 0, want tracking points\n%s", content)
+	}
+	generated := config.TrackGenerateComment + " name=refund-flow tags=payments,critical"
+	if got := strings.Count(content, generated); got != 1 {
+		t.Errorf("named generate comment count = %d, want 1\n%s", got, content)
+	}
+	// the directive separated by a blank line does not apply
+	if strings.Contains(content, "name=unused") {
+		t.Errorf("directive separated by a blank line should not name the point\n%s", content)
+	}
+	// the named point is inserted right before the annotated statement
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == generated {
+			for i < len(lines) && strings.TrimSpace(lines[i]) != config.TrackEndComment {
+				i++
+			}
+			if i+1 >= len(lines) || strings.TrimSpace(lines[i+1]) != "fee := amount / 10" {
+				t.Errorf("named point is not placed before the annotated statement\n%s", content)
+			}
+		}
+	}
+}
+
+func TestIncrementalTrackBudget(t *testing.T) {
+	source := `package main
+
+func hot(items []int) int {
+	sum := 0
+	for _, item := range items {
+		sum += item
+		if item > 10 {
+			sum--
+		}
+	}
+	return sum
+}
+
+func cold() int {
+	a := 1
+	b := 2
+	return a + b
+}
+`
+	lines := diff.LineChanges{{Start: 1, Lines: strings.Count(source, "\n") + 1}}
+	testCases := []struct {
+		name    string
+		budget  trackBudget
+		want    int
+		tracked []string
+	}{
+		{
+			name:    "unlimited",
+			budget:  trackBudget{},
+			want:    7,
+			tracked: []string{"sum := 0", "sum += item", "sum--", "return sum", "a := 1", "b := 2", "return a + b"},
+		},
+		{
+			name:    "max points in loops",
+			budget:  trackBudget{maxPointsInLoops: 1},
+			want:    4,
+			tracked: []string{"sum := 0", "a := 1", "b := 2", "return a + b"},
+		},
+		{
+			name:    "max points per func",
+			budget:  trackBudget{maxPointsPerFunc: 2},
+			want:    2,
+			tracked: []string{"sum := 0", "a := 1"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			count, content := trackSourceWith(t, source, config.GranularityLine, lines, func(tracker *IncrementalTrack) {
+				tracker.SetBudget(tc.budget.maxPointsPerFunc, tc.budget.maxPointsInLoops)
+			})
+			if count != tc.want {
+				t.Fatalf("Track() count = %d, want %d\n%s", count, tc.want, content)
+			}
+			tracked := trackedLinesOf(content)
+			if strings.Join(tracked, "|") != strings.Join(tc.tracked, "|") {
+				t.Errorf("tracked lines = %q, want %q\n%s", tracked, tc.tracked, content)
+			}
+		})
+	}
+}
+
+func TestIncrementalTrackHoistLoops(t *testing.T) {
+	testCases := []struct {
+		name    string
+		source  string
+		hoist   bool
+		tracked []string
+		hoisted int
+	}{
+		{
+			name: "branch-free loop",
+			source: `package main
+
+func sum(items []int) int {
+	total := 0
+	for _, item := range items {
+		total += item
+		total *= 2
+	}
+	return total
+}
+`,
+			hoist:   true,
+			tracked: []string{"total := 0", "for _, item := range items {", "return total"},
+			hoisted: 1,
+		},
+		{
+			name: "branch-free loop kept in body",
+			source: `package main
+
+func sum(items []int) int {
+	total := 0
+	for _, item := range items {
+		total += item
+		total *= 2
+	}
+	return total
+}
+`,
+			hoist:   false,
+			tracked: []string{"total := 0", "total += item", "total *= 2", "return total"},
+		},
+		{
+			name: "loop with branching",
+			source: `package main
+
+func sum(items []int) int {
+	total := 0
+	for _, item := range items {
+		if item < 0 {
+			continue
+		}
+		total += item
+	}
+	return total
+}
+`,
+			hoist:   true,
+			tracked: []string{"total := 0", "if item < 0 {", "continue", "total += item", "return total"},
+		},
+		{
+			name: "labeled loop",
+			source: `package main
+
+func sum(n int) int {
+	total := 0
+outer:
+	for i := 0; i < n; i++ {
+		total += i
+	}
+	return total
+}
+`,
+			hoist:   true,
+			tracked: []string{"total := 0", "outer:", "return total"},
+			hoisted: 1,
+		},
+		{
+			name: "function literal in loop",
+			source: `package main
+
+func sum(items []int) int {
+	total := 0
+	for _, item := range items {
+		add := func() {
+			if item > 0 {
+				total += item
+			}
+		}
+		add()
+	}
+	return total
+}
+`,
+			hoist:   true,
+			tracked: []string{"total := 0", "for _, item := range items {", "total += item", "return total"},
+			hoisted: 1,
+		},
+		{
+			name: "loop sharing its line",
+			source: `package main
+
+func sum(items []int, ok bool) int {
+	total := 0
+	if ok { for _, item := range items {
+		total += item
+	} }
+	return total
+}
+`,
+			hoist:   true,
+			tracked: []string{"total := 0", "total += item", "return total"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lines := diff.LineChanges{{Start: 1, Lines: strings.Count(tc.source, "\n") + 1}}
+			var tracker *IncrementalTrack
+			count, content := trackSourceWith(t, tc.source, config.GranularityLine, lines, func(it *IncrementalTrack) {
+				it.SetHoistLoops(tc.hoist)
+				tracker = it
+			})
+			tracked := trackedLinesOf(content)
+			if count != len(tc.tracked) || strings.Join(tracked, "|") != strings.Join(tc.tracked, "|") {
+				t.Fatalf("tracked lines = %q (count=%d), want %q\n%s", tracked, count, tc.tracked, content)
+			}
+			if tracker.Hoisted() != tc.hoisted {
+				t.Errorf("Hoisted() = %d, want %d", tracker.Hoisted(), tc.hoisted)
+			}
+			if got := strings.Count(content, "tags="+config.HoistedTag); got != tc.hoisted {
+				t.Errorf("hoisted tags = %d, want %d\n%s", got, tc.hoisted, content)
+			}
+		})
+	}
+}
+
+func TestIncrementalTrackMultiLineStatements(t *testing.T) {
+	source := `package main
+
+func run(a, b int) int {
+	total := add(a,
+		b)
+	if total > 0 &&
+		b > 1 {
+		total++
+	}
+	go func() {
+		println(total)
+	}()
+	return total
+}
+
+func add(a, b int) int {
+	sum := max(a,
+		b,
+		0)
+	next := sum + 1
+	return next
+}
+`
+	testCases := []struct {
+		name        string
+		granularity config.Granularity
+		changes     diff.LineChanges
+		tracked     []string
+	}{
+		{
+			name:        "changed argument line",
+			granularity: config.GranularityLine,
+			changes:     diff.LineChanges{{Start: 5, Lines: 1}},
+			tracked:     []string{"total := add(a,"},
+		},
+		{
+			name:        "changed condition line",
+			granularity: config.GranularityLine,
+			changes:     diff.LineChanges{{Start: 7, Lines: 1}},
+			tracked:     []string{"total++"},
+		},
+		{
+			name:        "changed function literal body",
+			granularity: config.GranularityLine,
+			changes:     diff.LineChanges{{Start: 11, Lines: 1}},
+			tracked:     []string{"println(total)"},
+		},
+		{
+			name:        "patch continued by a multi-line statement",
+			granularity: config.GranularityPatch,
+			changes:     diff.LineChanges{{Start: 19, Lines: 2}},
+			tracked:     []string{"sum := max(a,"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			count, content := trackSourceChanges(t, source, tc.granularity, tc.changes)
+			tracked := trackedLinesOf(content)
+			if count != len(tc.tracked) || strings.Join(tracked, "|") != strings.Join(tc.tracked, "|") {
+				t.Errorf("tracked lines = %q (count=%d), want %q\n%s", tracked, count, tc.tracked, content)
+			}
+		})
+	}
+}
+
+func TestIncrementalTrackCommentAndStringLines(t *testing.T) {
+	source := `package main
+
+func run(a int) string {
+	query := ` + "`" + `
+// select all rows
+select * from t
+` + "`" + `
+	/* a
+	b := a */
+	b := a + 1 // trailing
+	/* lead */ c := b
+	return query + string(rune(c))
+}
+`
+	testCases := []struct {
+		name    string
+		changes diff.LineChanges
+		tracked []string
+	}{
+		{
+			name:    "changed block comment",
+			changes: diff.LineChanges{{Start: 8, Lines: 2}},
+			tracked: nil,
+		},
+		{
+			name:    "changed raw string lines",
+			changes: diff.LineChanges{{Start: 5, Lines: 2}},
+			tracked: nil,
+		},
+		{
+			name:    "changed line with a trailing comment",
+			changes: diff.LineChanges{{Start: 10, Lines: 1}},
+			tracked: []string{"b := a + 1 // trailing"},
+		},
+		{
+			name:    "changed line with a leading comment",
+			changes: diff.LineChanges{{Start: 11, Lines: 1}},
+			tracked: []string{"/* lead */"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			count, content := trackSourceChanges(t, source, config.GranularityLine, tc.changes)
+			tracked := trackedLinesOf(content)
+			if count != len(tc.tracked) || strings.Join(tracked, "|") != strings.Join(tc.tracked, "|") {
+				t.Errorf("tracked lines = %q (count=%d), want %q\n%s", tracked, count, tc.tracked, content)
+			}
+		})
+	}
+}
+
+func TestIncrementalTrackFunctionLiterals(t *testing.T) {
+	source := `package main
+
+type worker struct{}
+
+func (w worker) run(f func()) { f() }
+
+func use(w worker, ch chan func() int, m map[int]int) {
+	if func() bool {
+		return len(m) > 0
+	}() {
+		println("if")
+	}
+	switch func() int {
+		return len(m)
+	}() {
+	case 0:
+		println("zero")
+	}
+	ch <- func() int {
+		return 1
+	}
+	total := 1 + func() int {
+		return 2
+	}()
+	m[func() int {
+		return 3
+	}()] = total
+	var opts = struct {
+		hook func() int
+	}{
+		hook: func() int {
+			return 4
+		},
+	}
+	go w.run(func() {
+		println("go")
+	})
+	for i := range func() []int {
+		return nil
+	}() {
+		println(i)
+	}
+	_ = opts
+}
+`
+	testCases := []struct {
+		name    string
+		changes diff.LineChanges
+		tracked []string
+	}{
+		{
+			name:    "if condition",
+			changes: diff.LineChanges{{Start: 9, Lines: 1}},
+			tracked: []string{"return len(m) > 0"},
+		},
+		{
+			name:    "switch tag",
+			changes: diff.LineChanges{{Start: 14, Lines: 1}},
+			tracked: []string{"return len(m)"},
+		},
+		{
+			name:    "channel send",
+			changes: diff.LineChanges{{Start: 20, Lines: 1}},
+			tracked: []string{"return 1"},
+		},
+		{
+			name:    "binary expression",
+			changes: diff.LineChanges{{Start: 23, Lines: 1}},
+			tracked: []string{"return 2"},
+		},
+		{
+			name:    "index expression",
+			changes: diff.LineChanges{{Start: 26, Lines: 1}},
+			tracked: []string{"return 3"},
+		},
+		{
+			name:    "struct field default in var block",
+			changes: diff.LineChanges{{Start: 32, Lines: 1}},
+			tracked: []string{"return 4"},
+		},
+		{
+			name:    "goroutine with method value",
+			changes: diff.LineChanges{{Start: 36, Lines: 1}},
+			tracked: []string{"println(\"go\")"},
+		},
+		{
+			name:    "range expression",
+			changes: diff.LineChanges{{Start: 39, Lines: 1}},
+			tracked: []string{"return nil"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			count, content := trackSourceChanges(t, source, config.GranularityLine, tc.changes)
+			tracked := trackedLinesOf(content)
+			if count != len(tc.tracked) || strings.Join(tracked, "|") != strings.Join(tc.tracked, "|") {
+				t.Errorf("tracked lines = %q (count=%d), want %q\n%s", tracked, count, tc.tracked, content)
+			}
+		})
+	}
+}
+
+func TestIncrementalTrackConditions(t *testing.T) {
+	source := `package main
+
+func check(a, b, c bool, n int) int {
+	if a && (b || c) {
+		n++
+	}
+	for i := 0; i < n && !a; i++ {
+		n--
+	}
+	switch {
+	case a || b:
+		n++
+	case c:
+		n--
+	}
+	if a && b {
+		n++
+	}
+	if c && func() bool { return b }() {
+		n++
+	}
+	return n
+}
+`
+	testCases := []struct {
+		name        string
+		granularity config.Granularity
+		changes     diff.LineChanges
+		want        int
+		wrapped     []string
+	}{
+		{
+			name:        "changed if condition",
+			granularity: config.GranularityCondition,
+			changes:     diff.LineChanges{{Start: 4, Lines: 1}},
+			want:        7,
+			wrapped: []string{"if goat.TrackCond(TRACK_ID, TRACK_ID, a) && (goat.TrackCond(TRACK_ID, TRACK_ID, b) || " +
+				"goat.TrackCond(TRACK_ID, TRACK_ID, c)) {"},
+		},
+		{
+			name:        "changed for condition",
+			granularity: config.GranularityCondition,
+			changes:     diff.LineChanges{{Start: 7, Lines: 1}},
+			want:        5,
+			wrapped: []string{"for i := 0; goat.TrackCond(TRACK_ID, TRACK_ID, i < n) && " +
+				"goat.TrackCond(TRACK_ID, TRACK_ID, !a); i++ {"},
+		},
+		{
+			name:        "changed case of a switch without tag",
+			granularity: config.GranularityCondition,
+			changes:     diff.LineChanges{{Start: 11, Lines: 1}},
+			want:        5,
+			wrapped:     []string{"case goat.TrackCond(TRACK_ID, TRACK_ID, a) || goat.TrackCond(TRACK_ID, TRACK_ID, b):"},
+		},
+		{
+			name:        "unchanged condition",
+			granularity: config.GranularityCondition,
+			changes:     diff.LineChanges{{Start: 17, Lines: 1}},
+			want:        1,
+			wrapped:     []string{},
+		},
+		{
+			name:        "line granularity",
+			granularity: config.GranularityLine,
+			changes:     diff.LineChanges{{Start: 4, Lines: 1}},
+			want:        1,
+			wrapped:     []string{},
+		},
+		{
+			name:        "single line function literal operand",
+			granularity: config.GranularityCondition,
+			changes:     diff.LineChanges{{Start: 19, Lines: 1}},
+			want:        6,
+			wrapped:     []string{"if goat.TrackCond(TRACK_ID, TRACK_ID, c) && goat.TrackCond(TRACK_ID, TRACK_ID, func() bool {"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			count, content := trackSourceChanges(t, source, tc.granularity, tc.changes)
+			wrapped := []string{}
+			for _, line := range strings.Split(content, "\n") {
+				if strings.Contains(line, "TrackCond") {
+					wrapped = append(wrapped, strings.TrimSpace(line))
+				}
+			}
+			if count != tc.want || strings.Join(wrapped, "|") != strings.Join(tc.wrapped, "|") {
+				t.Errorf("count = %d, wrapped = %q, want %d, %q\n%s", count, wrapped, tc.want, tc.wrapped, content)
+			}
+			typeCheckTracked(t, content)
+		})
+	}
+}
+
+// goatStubSource is the goat package with the signatures of the runtime used by the tracked code
+const goatStubSource = `package goat
+
+func Track(id int) {}
+
+func TrackCond[T ~bool](trueId, falseId int, v T) T { return v }
+`
+
+// importerFunc imports a package with a function
+type importerFunc func(path string) (*types.Package, error)
+
+// Import imports the package of the path
+func (f importerFunc) Import(path string) (*types.Package, error) {
+	return f(path)
+}
+
+// typeCheckTracked type checks the tracked content, the track IDs numbered as 0
+func typeCheckTracked(t *testing.T, content string) {
+	t.Helper()
+	fset := token.NewFileSet()
+	parse := func(name, source string) *ast.File {
+		f, err := parser.ParseFile(fset, name, source, 0)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v\n%s", name, err, source)
+		}
+		return f
+	}
+	goat, err := new(types.Config).Check(increament.TrackImportPathPlaceHolder, fset,
+		[]*ast.File{parse("goat.go", goatStubSource)}, nil)
+	if err != nil {
+		t.Fatalf("Failed to type check the goat package: %v", err)
+	}
+	conf := types.Config{Importer: importerFunc(func(path string) (*types.Package, error) {
+		if path == increament.TrackImportPathPlaceHolder {
+			return goat, nil
+		}
+		return importer.Default().Import(path)
+	})}
+	tracked := parse("main.go", strings.ReplaceAll(content, "TRACK_ID", "0"))
+	if _, err := conf.Check("main", fset, []*ast.File{tracked}, nil); err != nil {
+		t.Errorf("Tracked content does not type check: %v\n%s", err, content)
+	}
+}
+
+func TestIncrementalTrackConditionTypes(t *testing.T) {
+	source := `package main
+
+import "strings"
+
+type flag bool
+
+func check(a, b int, s string) int {
+	var ok, done flag = a > 0, b > 0
+	if ok && (b > 0 || a > b) {
+		a++
+	}
+	if ok || !done {
+		a--
+	}
+	if a > 0 && (b > 0 || strings.HasPrefix(s, "x")) {
+		b++
+	}
+	for i := 0; i < a && ok; i++ {
+		b--
+	}
+	return a + b
+}
+`
+	_, content := trackSource(t, source, config.GranularityCondition)
+	wrapped := []string{}
+	for _, line := range strings.Split(content, "\n") {
+		if strings.Contains(line, "TrackCond") {
+			wrapped = append(wrapped, strings.TrimSpace(line))
+		}
+	}
+	want := []string{
+		"if goat.TrackCond(TRACK_ID, TRACK_ID, ok) || goat.TrackCond(TRACK_ID, TRACK_ID, !done) {",
+		"if goat.TrackCond(TRACK_ID, TRACK_ID, a > 0) && (goat.TrackCond(TRACK_ID, TRACK_ID, b > 0) || " +
+			"goat.TrackCond(TRACK_ID, TRACK_ID, strings.HasPrefix(s, \"x\"))) {",
+	}
+	if strings.Join(wrapped, "|") != strings.Join(want, "|") {
+		t.Errorf("wrapped = %q, want only the conditions without untyped operands of a named type %q", wrapped, want)
+	}
+	typeCheckTracked(t, content)
+}
+
+func TestIncrementalTrackUntypedConditions(t *testing.T) {
+	source := `package main
+
+import "example.com/missing"
+
+func check(a int) int {
+	if missing.Ready() && a > 0 {
+		a++
+	}
+	if a > 1 || a < -1 {
+		a--
+	}
+	return a
+}
+`
+	var tracker *IncrementalTrack
+	_, content := trackSourceWith(t, source, config.GranularityCondition, diff.LineChanges{{Start: 1, Lines: 13}},
+		func(tr *IncrementalTrack) { tracker = tr })
+	if strings.Contains(content, "missing.Ready())") {
+		t.Errorf("operands of the condition of an unknown type are wrapped:\n%s", content)
+	}
+	if !strings.Contains(content, "goat.TrackCond(TRACK_ID, TRACK_ID, a > 1)") {
+		t.Errorf("operands of the condition of a known type are not wrapped:\n%s", content)
+	}
+	if got := slices.Sorted(maps.Keys(tracker.untypedConditions)); !slices.Equal(got, []int{6}) {
+		t.Errorf("untyped conditions = %v, want [6]", got)
+	}
+}
+
+func TestIncrementalTrackImplicitBranches(t *testing.T) {
+	source := `package main
+
+func check(a, b bool, n int, v any, ch chan int) int {
+	if a {
+		n++
+	}
+	if a {
+		n++
+	} else if b {
+		n--
+	}
+	if b {
+		n++
+	} else {
+		n--
+	}
+	switch n {
+	case 1:
+		n++
+	}
+	switch n {
+	case 2:
+		n++
+	default:
+		n--
+	}
+	switch v.(type) {
+	case int:
+		n++
+	}
+	if n > 9 {
+		return 0
+	} else if b {
+		panic(n)
+	}
+	if a {
+		return 1
+	} else if n < 0 {
+		n++
+	}
+	select {
+	case <-ch:
+		n++
+	}
+	return n
+}
+`
+	testCases := []struct {
+		name     string
+		implicit bool
+		changes  diff.LineChanges
+		want     int
+		tracked  []string
+		defaults int
+	}{
+		{
+			name:     "if without else that falls through",
+			implicit: true,
+			changes:  diff.LineChanges{{Start: 4, Lines: 1}},
+			want:     1,
+			tracked:  []string{},
+			defaults: 1,
+		},
+		{
+			name:     "else if chain without else that falls through",
+			implicit: true,
+			changes:  diff.LineChanges{{Start: 7, Lines: 1}},
+			want:     1,
+			tracked:  []string{},
+			defaults: 1,
+		},
+		{
+			name:     "terminating else if chain without else",
+			implicit: true,
+			changes:  diff.LineChanges{{Start: 31, Lines: 1}},
+			want:     2,
+			tracked:  []string{"if a {"},
+			defaults: 1,
+		},
+		{
+			name:     "else if chain with a branch that falls through",
+			implicit: true,
+			changes:  diff.LineChanges{{Start: 36, Lines: 1}},
+			want:     1,
+			tracked:  []string{},
+			defaults: 1,
+		},
+		{
+			// a default case would make the select non-blocking
+			name:     "select without default",
+			implicit: true,
+			changes:  diff.LineChanges{{Start: 41, Lines: 2}},
+			want:     1,
+			tracked:  []string{},
+			defaults: 1,
+		},
+		{
+			name:     "if with else",
+			implicit: true,
+			changes:  diff.LineChanges{{Start: 12, Lines: 1}},
+			want:     2,
+			tracked:  []string{},
+			defaults: 1,
+		},
+		{
+			name:     "switch without default",
+			implicit: true,
+			changes:  diff.LineChanges{{Start: 17, Lines: 1}},
+			want:     2,
+			tracked:  []string{"}"},
+			defaults: 2,
+		},
+		{
+			name:     "switch with default",
+			implicit: true,
+			changes:  diff.LineChanges{{Start: 21, Lines: 1}},
+			want:     2,
+			tracked:  []string{},
+			defaults: 1,
+		},
+		{
+			name:     "type switch without default",
+			implicit: true,
+			changes:  diff.LineChanges{{Start: 27, Lines: 1}},
+			want:     2,
+			tracked:  []string{"}"},
+			defaults: 2,
+		},
+		{
+			name:     "disabled",
+			implicit: false,
+			changes:  diff.LineChanges{{Start: 4, Lines: 1}, {Start: 17, Lines: 1}},
+			want:     2,
+			tracked:  []string{},
+			defaults: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			count, content := trackSourceWith(t, source, config.GranularityLine, tc.changes, func(tracker *IncrementalTrack) {
+				tracker.SetImplicitBranches(tc.implicit)
+			})
+			lines := strings.Split(content, "\n")
+			tracked := []string{}
+			for i, line := range lines {
+				if !strings.Contains(line, config.ImplicitBranchTag) {
+					continue
+				}
+				for j := i + 1; j < len(lines); j++ {
+					if strings.TrimSpace(lines[j]) == config.TrackEndComment {
+						tracked = append(tracked, strings.TrimSpace(lines[j+1]))
+						break
+					}
+				}
+			}
+			if count != tc.want || strings.Join(tracked, "|") != strings.Join(tc.tracked, "|") {
+				t.Errorf("count = %d, tracked = %q, want %d, %q\n%s", count, tracked, tc.want, tc.tracked, content)
+			}
+			if defaults := strings.Count(content, increament.ImplicitDefaultStmt); defaults != tc.defaults {
+				t.Errorf("default cases = %d, want %d\n%s", defaults, tc.defaults, content)
+			}
+		})
+	}
+}
+
+func TestIncrementalTrackInitHooks(t *testing.T) {
+	source := `package main
+
+const limit = 10
+
+var table = buildTable()
+
+var (
+	rates = map[string]int{
+		"a": 1,
+	}
+	count int
+)
+
+var handler = func() int {
+	return limit
+}
+
+func buildTable() []int {
+	return []int{limit}
+}
+`
+	testCases := []struct {
+		name    string
+		changes diff.LineChanges
+		want    int
+		hooked  []string
+	}{
+		{
+			name:    "changed initializer",
+			changes: diff.LineChanges{{Start: 5, Lines: 1}},
+			want:    1,
+			hooked:  []string{"var table = buildTable()"},
+		},
+		{
+			name:    "changed initializer in a declaration group",
+			changes: diff.LineChanges{{Start: 9, Lines: 1}},
+			want:    1,
+			hooked:  []string{")"},
+		},
+		{
+			name:    "variable without initializer",
+			changes: diff.LineChanges{{Start: 11, Lines: 1}},
+			want:    0,
+			hooked:  []string{},
+		},
+		{
+			name:    "constant",
+			changes: diff.LineChanges{{Start: 3, Lines: 1}},
+			want:    0,
+			hooked:  []string{},
+		},
+		{
+			name:    "function literal body",
+			changes: diff.LineChanges{{Start: 15, Lines: 1}},
+			want:    1,
+			hooked:  []string{},
+		},
+		{
+			name:    "function literal header",
+			changes: diff.LineChanges{{Start: 14, Lines: 1}},
+			want:    1,
+			hooked:  []string{"}"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			count, content := trackSourceChanges(t, source, config.GranularityLine, tc.changes)
+			lines := strings.Split(content, "\n")
+			hooked := []string{}
+			for i, line := range lines {
+				if strings.TrimSpace(line) != increament.InitHookStmt {
+					continue
+				}
+				// the last line of the declaration, above the generate comment and the blank line
+				j := i - 1
+				for j > 0 && (strings.HasPrefix(lines[j], "//") || strings.TrimSpace(lines[j]) == "") {
+					j--
+				}
+				hooked = append(hooked, strings.TrimSpace(lines[j]))
+			}
+			if count != tc.want || strings.Join(hooked, "|") != strings.Join(tc.hooked, "|") {
+				t.Errorf("count = %d, hooked = %q, want %d, %q\n%s", count, hooked, tc.want, tc.hooked, content)
+			}
+		})
+	}
+}
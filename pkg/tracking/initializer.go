@@ -0,0 +1,53 @@
+package tracking
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// InitHooks returns the number of init hooks tracking the changed package-level initializers
+func (t *IncrementalTrack) InitHooks() int {
+	return len(t.initHooks)
+}
+
+// markInitHook marks an init hook right after a package-level var declaration whose initializers are changed,
+// the hook is hit when the package is initialized, after the initializers are evaluated,
+// the bodies of the function literals of the initializers are tracked on their own
+func (t *IncrementalTrack) markInitHook(fset *token.FileSet, decl *ast.GenDecl) {
+	if decl.Tok != token.VAR || !t.isInitializerChanged(fset, decl) {
+		return
+	}
+	// the declaration must end its line, so the hook follows it
+	end := fset.Position(decl.End())
+	rest := strings.TrimSpace(t.source[end.Line-1][end.Column-1:])
+	if rest != "" && !strings.HasPrefix(rest, "//") {
+		return
+	}
+	line := end.Line + 1
+	if line > len(t.source) {
+		return
+	}
+	key := InsertPosition{line: line, column: 0}
+	if _, ok := t.visitedInsertedPositions[key]; ok {
+		return
+	}
+	t.insertedPositions.Insert(line, 0)
+	t.visitedInsertedPositions[key] = struct{}{}
+	t.initHooks[line] = struct{}{}
+	t.count++
+}
+
+// isInitializerChanged checks if any value spec of the declaration with initializers is changed
+func (t *IncrementalTrack) isInitializerChanged(fset *token.FileSet, decl *ast.GenDecl) bool {
+	for _, spec := range decl.Specs {
+		spec, ok := spec.(*ast.ValueSpec)
+		if !ok || len(spec.Values) == 0 {
+			continue
+		}
+		if t.isSpanChanged(fset, spec.Pos(), spec.End(), spec) {
+			return true
+		}
+	}
+	return false
+}
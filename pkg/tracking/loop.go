@@ -0,0 +1,140 @@
+package tracking
+
+import (
+	"go/ast"
+	"go/token"
+	"slices"
+	"strings"
+
+	"github.com/monshunter/goat/pkg/config"
+)
+
+// loopScope is the body of a for or range loop
+type loopScope struct {
+	BlockScope
+	// line is the line the tracking point of the loop is hoisted to,
+	// the line of the loop or of its label
+	line int
+	// hoistable is true if the loop body has no branching and the loop starts its line,
+	// so executing the body once executes all of it
+	hoistable bool
+}
+
+// loopScopes is a list of loop scopes sorted by start line
+type loopScopes []loopScope
+
+// search returns the index of the innermost loop containing the line, -1 if none
+func (l loopScopes) search(line int) int {
+	idx := -1
+	for i, loop := range l {
+		if loop.Contains(line) {
+			idx = i
+		}
+	}
+	return idx
+}
+
+// loopScopesOfAST returns the bodies of the for and range loops of the file
+func loopScopesOfAST(fset *token.FileSet, astFile *ast.File, source []string) loopScopes {
+	loops := loopScopes{}
+	labels := make(map[ast.Stmt]int)
+	ast.Inspect(astFile, func(n ast.Node) bool {
+		var body *ast.BlockStmt
+		switch n := n.(type) {
+		case *ast.LabeledStmt:
+			labels[n.Stmt] = fset.Position(n.Pos()).Line
+		case *ast.ForStmt:
+			body = n.Body
+		case *ast.RangeStmt:
+			body = n.Body
+		}
+		if body == nil {
+			return true
+		}
+		stmt := n.(ast.Stmt)
+		pos := fset.Position(stmt.Pos())
+		line, labeled := labels[stmt]
+		if !labeled {
+			line = pos.Line
+		}
+		loops = append(loops, loopScope{
+			BlockScope: BlockScope{
+				StartLine: fset.Position(body.Lbrace).Line,
+				EndLine:   fset.Position(body.Rbrace).Line,
+			},
+			line:      line,
+			hoistable: (labeled || startsLine(source, pos)) && isBranchFree(body),
+		})
+		return true
+	})
+	slices.SortFunc(loops, func(a, b loopScope) int {
+		if a.StartLine == b.StartLine {
+			return a.EndLine - b.EndLine
+		}
+		return a.StartLine - b.StartLine
+	})
+	return loops
+}
+
+// startsLine checks if the position is the first token of its line
+func startsLine(source []string, pos token.Position) bool {
+	if pos.Line < 1 || pos.Line > len(source) || pos.Column-1 > len(source[pos.Line-1]) {
+		return false
+	}
+	return strings.TrimSpace(source[pos.Line-1][:pos.Column-1]) == ""
+}
+
+// isBranchFree checks if the body has no conditional or nested loop and never leaves the loop early,
+// the function literals in the body are not taken into account
+func isBranchFree(body *ast.BlockStmt) bool {
+	free := true
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.IfStmt, *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt,
+			*ast.ForStmt, *ast.RangeStmt, *ast.BranchStmt, *ast.ReturnStmt:
+			free = false
+		}
+		return free
+	})
+	return free
+}
+
+// SetHoistLoops sets whether the tracking points in a branch-free loop body are hoisted
+// to a single point right before the loop, which tracks the loop being reached
+func (t *IncrementalTrack) SetHoistLoops(hoist bool) {
+	t.hoistLoops = hoist
+}
+
+// Hoisted returns the number of tracking points hoisted out of loop bodies
+func (t *IncrementalTrack) Hoisted() int {
+	return t.hoisted
+}
+
+// hoistedLine returns the line the tracking point of the line is hoisted to,
+// and false if the line is not in a hoistable loop body of its function
+func (t *IncrementalTrack) hoistedLine(line int) (int, bool) {
+	if !t.hoistLoops {
+		return line, false
+	}
+	idx := t.loopScopes.search(line)
+	if idx == -1 || !t.loopScopes[idx].hoistable {
+		return line, false
+	}
+	// the loops around a function literal do not hoist its tracking points
+	if t.loopScopes[idx].StartLine < t.functionScopes[t.functionScopes.Search(line)].StartLine {
+		return line, false
+	}
+	return t.loopScopes[idx].line, true
+}
+
+// markHoisted tags the tracking point of the line as hoisted out of a loop body
+func (t *IncrementalTrack) markHoisted(line int) {
+	attrs := t.insertedAttrs[line]
+	if !attrs.HasTag(config.HoistedTag) {
+		attrs.Tags = append(attrs.Tags, config.HoistedTag)
+	}
+	t.insertedAttrs[line] = attrs
+	t.hoisted++
+}
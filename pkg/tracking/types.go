@@ -20,6 +20,12 @@ type Tracker interface {
 	Target() string
 	// SetContent sets the content of the tracker
 	SetContent([]byte)
+	// Hoisted returns the number of tracking points hoisted out of loop bodies
+	Hoisted() int
+	// ImplicitBranches returns the number of tracking points of implicit branches
+	ImplicitBranches() int
+	// InitHooks returns the number of init hooks tracking the changed package-level initializers
+	InitHooks() int
 }
 
 // InsertPosition is the position of the tracking point
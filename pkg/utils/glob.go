@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// MatchGlob checks if the slash separated name matches the doublestar glob pattern.
+// Every segment of the pattern follows the path.Match syntax, and a "**" segment
+// matches zero or more path segments, e.g. "pkg/**/*.go" matches "pkg/a.go" and "pkg/a/b/c.go"
+func MatchGlob(pattern string, name string) bool {
+	return matchGlobSegments(splitGlob(pattern), splitGlob(name))
+}
+
+// MatchGlobOrParent checks if the name or any of its parent directories matches the pattern,
+// so that a pattern matching a directory also matches everything under it
+func MatchGlobOrParent(pattern string, name string) bool {
+	patterns := splitGlob(pattern)
+	names := splitGlob(name)
+	for i := len(names); i > 0; i-- {
+		if matchGlobSegments(patterns, names[:i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateGlob checks if the doublestar glob pattern is well formed
+func ValidateGlob(pattern string) error {
+	if strings.TrimSpace(pattern) == "" {
+		return fmt.Errorf("empty glob pattern")
+	}
+	for _, segment := range splitGlob(pattern) {
+		if segment == "**" {
+			continue
+		}
+		if _, err := path.Match(segment, ""); err != nil {
+			return fmt.Errorf("invalid glob pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// splitGlob splits the pattern or name into slash separated segments
+func splitGlob(s string) []string {
+	s = strings.Trim(filepath.ToSlash(filepath.Clean(s)), "/")
+	if s == "" || s == "." {
+		return nil
+	}
+	return strings.Split(s, "/")
+}
+
+// matchGlobSegments matches the name segments against the pattern segments
+func matchGlobSegments(patterns []string, names []string) bool {
+	for len(patterns) > 0 {
+		if patterns[0] == "**" {
+			// collapse consecutive "**" segments
+			for len(patterns) > 0 && patterns[0] == "**" {
+				patterns = patterns[1:]
+			}
+			if len(patterns) == 0 {
+				return true
+			}
+			for i := range len(names) + 1 {
+				if matchGlobSegments(patterns, names[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(names) == 0 {
+			return false
+		}
+		matched, err := path.Match(patterns[0], names[0])
+		if err != nil || !matched {
+			return false
+		}
+		patterns = patterns[1:]
+		names = names[1:]
+	}
+	return len(names) == 0
+}
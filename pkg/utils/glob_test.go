@@ -0,0 +1,78 @@
+package utils
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"api", "api", true},
+		{"api", "apiserver", false},
+		{"api/*.go", "api/handler.go", true},
+		{"api/*.go", "api/v1/handler.go", false},
+		{"pkg/**/*.go", "pkg/a.go", true},
+		{"pkg/**/*.go", "pkg/a/b/c.go", true},
+		{"pkg/**/*.go", "cmd/a.go", false},
+		{"**/generated.go", "generated.go", true},
+		{"**/generated.go", "pkg/x/generated.go", true},
+		{"internal/legacy/**", "internal/legacy", true},
+		{"internal/legacy/**", "internal/legacy/x/y.go", true},
+		{"internal/legacy/**", "internal/legacyx/y.go", false},
+		{"**", "anything/at/all.go", true},
+		{"./pkg/*", "pkg/a.go", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.pattern+"|"+tc.name, func(t *testing.T) {
+			if got := MatchGlob(tc.pattern, tc.name); got != tc.want {
+				t.Errorf("MatchGlob(%q, %q) = %v, want %v", tc.pattern, tc.name, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMatchGlobOrParent(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"api", "api/handler.go", true},
+		{"api", "apiserver/handler.go", false},
+		{"pkg/*/internal", "pkg/a/internal/x/y.go", true},
+		{"*.pb.go", "api.pb.go", true},
+		{"*.pb.go", "api/v1/api.pb.go", false},
+		{"**/*.pb.go", "api/v1/api.pb.go", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.pattern+"|"+tc.name, func(t *testing.T) {
+			if got := MatchGlobOrParent(tc.pattern, tc.name); got != tc.want {
+				t.Errorf("MatchGlobOrParent(%q, %q) = %v, want %v", tc.pattern, tc.name, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateGlob(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		wantErr bool
+	}{
+		{"pkg/**/*.go", false},
+		{"internal/[a-z]*", false},
+		{"internal/[a-z", true},
+		{"", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.pattern, func(t *testing.T) {
+			err := ValidateGlob(tc.pattern)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("ValidateGlob(%q) error = %v, wantErr %v", tc.pattern, err, tc.wantErr)
+			}
+		})
+	}
+}